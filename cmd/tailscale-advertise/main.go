@@ -22,47 +22,106 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/config"
 	"github.com/dhermes/tailsk8s/pkg/tailscale/command/advertise"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
 )
 
 func run() error {
 	ctx := context.Background()
 
+	settings, err := config.Load()
+	if err != nil {
+		return err
+	}
 	c, err := advertise.NewConfig()
 	if err != nil {
 		return err
 	}
 	debug := false
+	socket := local.DefaultSocket()
 	cmd := &cobra.Command{
 		Use:           "tailscale-advertise",
 		Short:         "Advertise to the Tailnet that the local node handles a given CIDR range",
 		SilenceErrors: true,
 		SilenceUsage:  true,
-		RunE: func(_ *cobra.Command, _ []string) error {
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := settings.Apply(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			err = config.Require(cmd.Flags(), "cidr")
+			if err != nil {
+				return err
+			}
+
 			ctx := cli.WithDebug(ctx, debug)
+			ctx = local.WithSocket(ctx, socket)
 			return advertise.AdvertiseAndAccept(ctx, c)
 		},
 	}
 
-	cmd.PersistentFlags().StringVar(
-		&c.APIConfig.Tailnet,
-		"tailnet",
-		c.APIConfig.Tailnet,
-		"The Tailnet where the device exists; a value will be inferred via the local 'tailscaled' API",
-	)
-	cmd.PersistentFlags().StringVar(
-		&c.APIConfig.APIKey,
-		"api-key",
-		c.APIConfig.APIKey,
-		("The Tailscale API key; if it beings with \"file:\", then it will " +
-			"be interpreted as a path to a file containing the Tailscale API key"),
-	)
+	c.APIConfig.AddFlags(cmd.PersistentFlags())
 	cmd.PersistentFlags().StringVar(
 		&c.IPv4CIDR,
 		"cidr",
 		c.IPv4CIDR,
 		"The (IPv4) CIDR to advertise",
 	)
+	cmd.PersistentFlags().BoolVar(
+		&c.AllowOverlap,
+		"allow-overlap",
+		c.AllowOverlap,
+		"Allow advertising a CIDR that overlaps a route enabled for another device",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.PodSubnet,
+		"pod-subnet",
+		c.PodSubnet,
+		"Only allow advertising CIDRs within this subnet, e.g. 10.100.0.0/16",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.Bridge,
+		"bridge",
+		c.Bridge,
+		"The CNI bridge, which is ignored when checking local interface addresses",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&c.AllowReserved,
+		"allow-reserved",
+		c.AllowReserved,
+		"Allow advertising a CIDR that overlaps the Tailscale CGNAT or ULA ranges",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&c.AllowDefaultRoute,
+		"allow-default-route",
+		c.AllowDefaultRoute,
+		"Allow advertising a default route",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&c.AllowLocalOverlap,
+		"allow-local-overlap",
+		c.AllowLocalOverlap,
+		"Allow advertising a CIDR that overlaps an address on a local network interface",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&c.AllowOutsidePodSubnet,
+		"allow-outside-pod-subnet",
+		c.AllowOutsidePodSubnet,
+		"Allow advertising a CIDR that is not within --pod-subnet",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&c.NoRollback,
+		"no-rollback",
+		c.NoRollback,
+		"Leave local preferences modified if enabling the route in the cloud API fails",
+	)
+	cmd.PersistentFlags().StringVar(
+		&socket,
+		"socket",
+		socket,
+		"The path to the 'tailscaled' socket used for local API calls",
+	)
 	cmd.PersistentFlags().BoolVar(
 		&debug,
 		"debug",
@@ -70,14 +129,6 @@ func run() error {
 		"Enable extra print debugging",
 	)
 
-	required := []string{"api-key", "cidr"}
-	for _, name := range required {
-		err := cobra.MarkFlagRequired(cmd.PersistentFlags(), name)
-		if err != nil {
-			return err
-		}
-	}
-
 	return cmd.Execute()
 }
 
@@ -22,47 +22,108 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/config"
 	"github.com/dhermes/tailsk8s/pkg/tailscale/command/authorize"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
 )
 
 func run() error {
 	ctx := context.Background()
 
+	settings, err := config.Load()
+	if err != nil {
+		return err
+	}
 	c, err := authorize.NewConfig()
 	if err != nil {
 		return err
 	}
 	debug := false
+	socket := local.DefaultSocket()
 	cmd := &cobra.Command{
 		Use:           "tailscale-authorize",
 		Short:         "Authorize a new device to join a Tailnet",
 		SilenceErrors: true,
 		SilenceUsage:  true,
-		RunE: func(_ *cobra.Command, _ []string) error {
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := settings.Apply(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
 			ctx := cli.WithDebug(ctx, debug)
+			ctx = local.WithSocket(ctx, socket)
 			return authorize.AuthorizeDevice(ctx, c)
 		},
 	}
 
-	cmd.PersistentFlags().StringVar(
-		&c.APIConfig.Tailnet,
-		"tailnet",
-		c.APIConfig.Tailnet,
-		"The Tailnet where the device exists; a value will be inferred via the local 'tailscaled' API",
-	)
-	cmd.PersistentFlags().StringVar(
-		&c.APIConfig.APIKey,
-		"api-key",
-		c.APIConfig.APIKey,
-		("The Tailscale API key; if it beings with \"file:\", then it will " +
-			"be interpreted as a path to a file containing the Tailscale API key"),
-	)
+	c.APIConfig.AddFlags(cmd.PersistentFlags())
 	cmd.PersistentFlags().StringVar(
 		&c.Hostname,
 		"hostname",
 		c.Hostname,
 		"The hostname of the device to authorize; if omitted the current device hostname will be used",
 	)
+	cmd.PersistentFlags().BoolVar(
+		&c.Wait,
+		"wait",
+		c.Wait,
+		"Wait for the device to be listed in the Tailnet (e.g. just after 'tailscale up') before authorizing it",
+	)
+	cmd.PersistentFlags().DurationVar(
+		&c.WaitTimeout,
+		"wait-timeout",
+		c.WaitTimeout,
+		"The maximum time to wait for the device when using --wait",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&c.AllPending,
+		"all-pending",
+		c.AllPending,
+		"Authorize all unauthorized devices (that match any selectors)",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.Selector.HostnameGlob,
+		"match-hostname",
+		c.Selector.HostnameGlob,
+		"Only authorize unauthorized devices with a hostname matching this glob, e.g. 'k8s-worker-*'",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.Selector.Tag,
+		"tag",
+		c.Selector.Tag,
+		"Only authorize unauthorized devices with this tag, e.g. tag:k8s-worker",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.Selector.OS,
+		"os",
+		c.Selector.OS,
+		"Only authorize unauthorized devices running this OS, e.g. linux",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.Selector.CreatedAfter,
+		"created-after",
+		c.Selector.CreatedAfter,
+		"Only authorize unauthorized devices created after this RFC 3339 time or within this duration (e.g. 2h)",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&c.Yes,
+		"yes",
+		c.Yes,
+		"Authorize devices in bulk without asking for confirmation",
+	)
+	cmd.PersistentFlags().IntVar(
+		&c.Parallelism,
+		"parallelism",
+		c.Parallelism,
+		"The maximum number of devices authorized concurrently in bulk",
+	)
+	cmd.PersistentFlags().StringVar(
+		&socket,
+		"socket",
+		socket,
+		"The path to the 'tailscaled' socket used for local API calls",
+	)
 	cmd.PersistentFlags().BoolVar(
 		&debug,
 		"debug",
@@ -70,14 +131,6 @@ func run() error {
 		"Enable extra print debugging",
 	)
 
-	required := []string{"api-key"}
-	for _, name := range required {
-		err := cobra.MarkFlagRequired(cmd.PersistentFlags(), name)
-		if err != nil {
-			return err
-		}
-	}
-
 	return cmd.Execute()
 }
 
@@ -0,0 +1,77 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/approver"
+)
+
+func newApproveRoutesCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := approver.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "approve-routes",
+		Short: "Enable advertised routes in the Tailnet that satisfy a policy",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, stop := signal.NotifyContext(rf.Context(ctx), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return approver.Run(ctx, c)
+		},
+	}
+
+	c.APIConfig.AddFlags(cmd.Flags())
+	cmd.Flags().StringVar(
+		&c.PolicyFile,
+		"policy",
+		c.PolicyFile,
+		"The policy file used to approve routes",
+	)
+	cmd.Flags().DurationVar(
+		&c.Interval,
+		"interval",
+		c.Interval,
+		"The time between approval passes",
+	)
+	cmd.Flags().StringVar(
+		&c.AlertWebhook,
+		"alert-webhook",
+		c.AlertWebhook,
+		"A webhook URL (e.g. Slack) that rejected advertisements are posted to",
+	)
+	cmd.Flags().BoolVar(
+		&c.Once,
+		"once",
+		c.Once,
+		"Run a single approval pass and exit",
+	)
+	cmd.Flags().BoolVar(
+		&c.DryRun,
+		"dry-run",
+		c.DryRun,
+		"Print decisions without enabling routes or sending alerts",
+	)
+
+	return cmd, nil
+}
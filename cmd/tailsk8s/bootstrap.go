@@ -0,0 +1,220 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/bootstrap"
+	"github.com/dhermes/tailsk8s/pkg/config"
+)
+
+func newBootstrapCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := bootstrap.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "bootstrap",
+		Short: "Inspect, verify and distribute the bootstrap directory",
+	}
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Show the state of each file in the bootstrap directory",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return bootstrap.Status(rf.Context(ctx), c)
+		},
+	}
+
+	verify := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify the bootstrap directory has everything needed for a role",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := config.Require(cmd.Flags(), "role")
+			if err != nil {
+				return err
+			}
+			return bootstrap.Verify(rf.Context(ctx), c)
+		},
+	}
+	verify.Flags().DurationVar(
+		&c.MinValidity,
+		"min-validity",
+		c.MinValidity,
+		"The minimum remaining lifetime required for the join token and certificate key",
+	)
+
+	initCmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create the bootstrap directory and fix the mode of any existing files",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return bootstrap.Init(rf.Context(ctx), c)
+		},
+	}
+
+	serve := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve join bundles to new nodes over HTTPS on the Tailnet",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, stop := signal.NotifyContext(rf.Context(ctx), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return bootstrap.Serve(ctx, c)
+		},
+	}
+	serve.Flags().StringVar(
+		&c.BindIP,
+		"bind-ip",
+		c.BindIP,
+		"The IP to serve on; defaults to the Tailscale IP of this device",
+	)
+	serve.Flags().IntVar(
+		&c.Port,
+		"port",
+		c.Port,
+		"The port to serve on",
+	)
+	serve.Flags().StringVar(
+		&c.TLSCertFile,
+		"tls-cert-file",
+		c.TLSCertFile,
+		"The TLS certificate to serve with; if unset, a certificate is requested from 'tailscaled'",
+	)
+	serve.Flags().StringVar(
+		&c.TLSKeyFile,
+		"tls-key-file",
+		c.TLSKeyFile,
+		"The private key for --tls-cert-file",
+	)
+	serve.Flags().StringSliceVar(
+		&c.AllowTags,
+		"allow-tag",
+		c.AllowTags,
+		"A Tailscale ACL tag (e.g. tag:k8s-worker) that is allowed to fetch bundles; can be repeated",
+	)
+	serve.Flags().StringSliceVar(
+		&c.AllowHostnames,
+		"allow-hostname",
+		c.AllowHostnames,
+		"A hostname pattern (e.g. 'worker-*') that is allowed to fetch bundles; can be repeated. Patterns match the hostname a device reports about itself (or its MagicDNS name), which any device in the Tailnet can choose, so prefer --allow-tag and avoid combining the two",
+	)
+	serve.Flags().BoolVar(
+		&c.AllowControlPlane,
+		"allow-control-plane",
+		c.AllowControlPlane,
+		"Allow bundles for joining control plane nodes (these include a certificate key)",
+	)
+	serve.Flags().BoolVar(
+		&c.ShareAPIKey,
+		"share-api-key",
+		c.ShareAPIKey,
+		"Include the Tailscale API key in bundles",
+	)
+	serve.Flags().DurationVar(
+		&c.IssueTokenTTL,
+		"issue-token-ttl",
+		c.IssueTokenTTL,
+		"The lifetime of the join token issued for each bundle",
+	)
+
+	fetch := &cobra.Command{
+		Use:   "fetch",
+		Short: "Fetch a join bundle from a control plane node and write it to the bootstrap directory",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := config.Require(cmd.Flags(), "role", "server")
+			if err != nil {
+				return err
+			}
+			return bootstrap.Fetch(rf.Context(ctx), c)
+		},
+	}
+	fetch.Flags().StringVar(
+		&c.Server,
+		"server",
+		c.Server,
+		"The URL of the 'bootstrap serve' instance, e.g. https://pedantic-yonath.tailnet-abcd.ts.net:8443",
+	)
+	fetch.Flags().StringVar(
+		&c.CAFile,
+		"ca-file",
+		c.CAFile,
+		"An optional CA bundle used to verify the server",
+	)
+
+	authKey := &cobra.Command{
+		Use:   "auth-key",
+		Short: "Resolve a Tailscale auth key via a secret provider and write it to a file for a new machine",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := config.Require(cmd.Flags(), "auth-key", "output")
+			if err != nil {
+				return err
+			}
+			return bootstrap.WriteAuthKey(rf.Context(ctx), c)
+		},
+	}
+	authKey.Flags().StringVar(
+		&c.AuthKey,
+		"auth-key",
+		c.AuthKey,
+		"The Tailscale auth key or a secret reference for it (e.g. vault:secret/tailscale#auth-key or env:TS_AUTHKEY)",
+	)
+	authKey.Flags().StringVar(
+		&c.AuthKeyFile,
+		"output",
+		c.AuthKeyFile,
+		"The file to write the auth key to (e.g. k8s-bootstrap-shared/tailscale-one-off-key-KC)",
+	)
+
+	cmd.PersistentFlags().StringVar(
+		&c.Dir,
+		"bootstrap-dir",
+		c.Dir,
+		"The directory containing bootstrap files (e.g. join-token.txt)",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.Role,
+		"role",
+		c.Role,
+		fmt.Sprintf("The role of this node, which determines the required files (one of %s)", strings.Join(bootstrap.Roles(), ", ")),
+	)
+	cmd.PersistentFlags().DurationVar(
+		&c.TokenTTL,
+		"token-ttl",
+		c.TokenTTL,
+		"The lifetime of the join token (from when join-token.txt was written)",
+	)
+	cmd.PersistentFlags().DurationVar(
+		&c.CertificateKeyTTL,
+		"certificate-key-ttl",
+		c.CertificateKeyTTL,
+		"The lifetime of the certificate key (from when certificate-key.txt was written)",
+	)
+
+	cmd.AddCommand(status, verify, initCmd, serve, fetch, authKey)
+	return cmd, nil
+}
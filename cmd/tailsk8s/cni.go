@@ -0,0 +1,95 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/cni"
+	"github.com/dhermes/tailsk8s/pkg/config"
+)
+
+func newCNICommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := cni.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "cni",
+		Short: "Manage CNI network configuration for the local node",
+	}
+
+	render := &cobra.Command{
+		Use:   "render",
+		Short: "Render CNI network configuration for the local node subnet",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := config.Require(cmd.Flags(), "subnet")
+			if err != nil {
+				return err
+			}
+			return cni.Render(rf.Context(ctx), c)
+		},
+	}
+	render.Flags().BoolVar(
+		&c.Stdout,
+		"stdout",
+		c.Stdout,
+		"Print the rendered configuration rather than writing it to the CNI configuration directory",
+	)
+
+	check := &cobra.Command{
+		Use:   "check",
+		Short: "Check an existing CNI configuration directory for drift",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := config.Require(cmd.Flags(), "subnet")
+			if err != nil {
+				return err
+			}
+			return cni.Check(rf.Context(ctx), c)
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(
+		&c.Subnet,
+		"subnet",
+		c.Subnet,
+		"The (IPv4) subnet exclusively owned by this node, e.g. 10.100.2.0/24",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.Dir,
+		"dir",
+		c.Dir,
+		"The CNI network configuration directory",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&c.ConfList,
+		"conflist",
+		c.ConfList,
+		"Render the bridge network as a network configuration list (.conflist)",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&c.SkipAdvertiseCheck,
+		"skip-advertise-check",
+		c.SkipAdvertiseCheck,
+		"Skip validating that the subnet is advertised by this node via 'tailscaled'",
+	)
+
+	cmd.AddCommand(render, check)
+	return cmd, nil
+}
@@ -0,0 +1,44 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/config"
+)
+
+func newConfigCommand(ctx context.Context, settings *config.Config) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the tailsk8s configuration",
+	}
+
+	view := &cobra.Command{
+		Use:   "view",
+		Short: "Show the effective configuration (with secrets redacted)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// NOTE: After parsing, `cmd.Flags()` also includes the persistent
+			//       flags inherited from the root command (e.g. `--socket`).
+			return config.View(ctx, settings, settings.Effective(cmd.Flags()))
+		},
+	}
+	cmd.AddCommand(view)
+
+	return cmd
+}
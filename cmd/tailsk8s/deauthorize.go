@@ -0,0 +1,59 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/config"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/deauthorize"
+)
+
+func newDeauthorizeCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := deauthorize.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "deauthorize",
+		Short: "Mark a device as unauthorized to quarantine it without deleting it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := config.Require(cmd.Flags(), "hostname")
+			if err != nil {
+				return err
+			}
+			return deauthorize.DeauthorizeDevice(rf.Context(ctx), c)
+		},
+	}
+
+	c.APIConfig.AddFlags(cmd.Flags())
+	cmd.Flags().StringVar(
+		&c.Hostname,
+		"hostname",
+		c.Hostname,
+		"The hostname of the device to deauthorize",
+	)
+	cmd.Flags().BoolVar(
+		&c.DisableRoutes,
+		"disable-routes",
+		c.DisableRoutes,
+		"Disable all enabled routes for the device before deauthorizing it",
+	)
+
+	return cmd, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/doctor"
+)
+
+func newDoctorCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := doctor.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the networking prerequisites for pod traffic on this node",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return doctor.Run(rf.Context(ctx), c)
+		},
+	}
+
+	c.APIConfig.AddFlags(cmd.Flags())
+	cmd.Flags().StringVar(
+		&c.Kubectl.Kubeconfig,
+		"kubeconfig",
+		c.Kubectl.Kubeconfig,
+		"The kubeconfig file used to read the node's advertise subnet label",
+	)
+	cmd.Flags().StringVar(
+		&c.Node,
+		"node",
+		c.Node,
+		"The node (and Tailscale device hostname) to check; defaults to the local hostname",
+	)
+	cmd.Flags().StringVar(
+		&c.CIDR,
+		"cidr",
+		c.CIDR,
+		"The (IPv4) CIDR for the node; defaults to the value of the node's advertise subnet label",
+	)
+	cmd.Flags().StringVar(
+		&c.CNIDir,
+		"cni-dir",
+		c.CNIDir,
+		"The CNI network configuration directory",
+	)
+	cmd.Flags().DurationVar(
+		&c.KeyExpiryWarning,
+		"key-expiry-warning",
+		c.KeyExpiryWarning,
+		"Warn if the device key expires within this duration",
+	)
+	cmd.Flags().BoolVar(
+		&c.SkipCloud,
+		"skip-cloud",
+		c.SkipCloud,
+		"Skip the checks that use the Tailscale cloud API",
+	)
+	cmd.Flags().BoolVar(
+		&c.JSON,
+		"json",
+		c.JSON,
+		"Print the report as JSON",
+	)
+
+	return cmd, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/exitnode"
+)
+
+func newExitNodeCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := exitnode.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "exit-node",
+		Short: "Advertise, withdraw or use a Tailscale exit node",
+	}
+
+	advertise := &cobra.Command{
+		Use:   "advertise",
+		Short: "Advertise this device as an exit node and enable both default routes",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return exitnode.AdvertiseAndEnable(rf.Context(ctx), c)
+		},
+	}
+	c.APIConfig.AddFlags(advertise.Flags())
+	advertise.Flags().BoolVar(
+		&c.NoRollback,
+		"no-rollback",
+		c.NoRollback,
+		"Leave local preferences modified if enabling the default routes in the cloud API fails",
+	)
+
+	withdraw := &cobra.Command{
+		Use:   "withdraw",
+		Short: "Stop advertising this device as an exit node and disable both default routes",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return exitnode.WithdrawAndDisable(rf.Context(ctx), c)
+		},
+	}
+	c.APIConfig.AddFlags(withdraw.Flags())
+	withdraw.Flags().BoolVar(
+		&c.NoRollback,
+		"no-rollback",
+		c.NoRollback,
+		"Leave local preferences modified if disabling the default routes in the cloud API fails",
+	)
+
+	use := &cobra.Command{
+		Use:   "use DEVICE",
+		Short: "Route Internet traffic from this device through an exit node (hostname, MagicDNS name, IP or node ID)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			c.Device = args[0]
+			return exitnode.Use(rf.Context(ctx), c)
+		},
+	}
+
+	cmd.AddCommand(advertise, withdraw, use)
+	return cmd, nil
+}
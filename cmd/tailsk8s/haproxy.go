@@ -0,0 +1,121 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/haproxy"
+)
+
+func newHAProxyCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := haproxy.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "haproxy",
+		Short: "Manage HAProxy configuration for the control plane load balancer",
+	}
+
+	generate := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate haproxy.cfg for the control plane devices in the Tailnet",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if len(c.Hostnames) == 0 && c.Tag == "" {
+				return errors.New("at least one of --control-plane or --tag is required")
+			}
+			if c.Watch && c.Stdout {
+				return errors.New("--watch and --stdout cannot be used together")
+			}
+
+			ctx, stop := signal.NotifyContext(rf.Context(ctx), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return haproxy.Generate(ctx, c)
+		},
+	}
+
+	c.APIConfig.AddFlags(generate.Flags())
+	generate.Flags().StringSliceVar(
+		&c.Hostnames,
+		"control-plane",
+		c.Hostnames,
+		"The exact hostname of a control plane device (can be repeated)",
+	)
+	generate.Flags().StringVar(
+		&c.Tag,
+		"tag",
+		c.Tag,
+		"Select all devices with this tag (e.g. tag:k8s-control-plane) as control plane devices",
+	)
+	generate.Flags().StringVar(
+		&c.BindIP,
+		"bind-ip",
+		c.BindIP,
+		"The IP the load balancer listens on; defaults to the Tailscale IPv4 address of this device",
+	)
+	generate.Flags().IntVar(
+		&c.Port,
+		"port",
+		c.Port,
+		"The Kubernetes API server port",
+	)
+	generate.Flags().StringVar(
+		&c.Output,
+		"output",
+		c.Output,
+		"The HAProxy configuration file",
+	)
+	generate.Flags().BoolVar(
+		&c.Stdout,
+		"stdout",
+		c.Stdout,
+		"Print the configuration rather than writing it",
+	)
+	generate.Flags().BoolVar(
+		&c.Reload,
+		"reload",
+		c.Reload,
+		"Reload HAProxy if the configuration changed (always done with --watch)",
+	)
+	generate.Flags().StringVar(
+		&c.ReloadCommand,
+		"reload-command",
+		c.ReloadCommand,
+		"The command used to reload HAProxy",
+	)
+	generate.Flags().BoolVar(
+		&c.Watch,
+		"watch",
+		c.Watch,
+		"Keep running, regenerating the configuration and reloading HAProxy when control plane devices change",
+	)
+	generate.Flags().DurationVar(
+		&c.Interval,
+		"interval",
+		c.Interval,
+		"The time between checks in --watch mode",
+	)
+
+	cmd.AddCommand(generate)
+	return cmd, nil
+}
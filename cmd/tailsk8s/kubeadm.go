@@ -0,0 +1,122 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/config"
+	"github.com/dhermes/tailsk8s/pkg/kubeadm"
+)
+
+func newKubeadmCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := kubeadm.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "kubeadm",
+		Short: "Manage kubeadm configuration for the local node",
+	}
+	render := &cobra.Command{
+		Use:   "render",
+		Short: "Render kubeadm configuration from the bootstrap directory",
+	}
+
+	initCmd := &cobra.Command{
+		Use:   kubeadm.KindInit,
+		Short: "Render configuration for `kubeadm init` on the first control plane node",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := config.Require(cmd.Flags(), "cluster-name", "pod-subnet", "service-subnet")
+			if err != nil {
+				return err
+			}
+			return kubeadm.Render(rf.Context(ctx), c, kubeadm.KindInit)
+		},
+	}
+	initCmd.Flags().StringVar(
+		&c.ClusterName,
+		"cluster-name",
+		c.ClusterName,
+		"The human readable name of the cluster",
+	)
+	initCmd.Flags().StringVar(
+		&c.PodSubnet,
+		"pod-subnet",
+		c.PodSubnet,
+		"The subnet used to allocate (virtual) IPs to pods in the cluster",
+	)
+	initCmd.Flags().StringVar(
+		&c.ServiceSubnet,
+		"service-subnet",
+		c.ServiceSubnet,
+		"The subnet used to allocate (virtual) IPs to services in the cluster",
+	)
+	initCmd.Flags().StringVar(
+		&c.KubernetesVersion,
+		"kubernetes-version",
+		c.KubernetesVersion,
+		"The Kubernetes version for the cluster",
+	)
+
+	joinControlPlane := &cobra.Command{
+		Use:   kubeadm.KindJoinControlPlane,
+		Short: "Render configuration for `kubeadm join` on a new control plane node",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return kubeadm.Render(rf.Context(ctx), c, kubeadm.KindJoinControlPlane)
+		},
+	}
+	joinWorker := &cobra.Command{
+		Use:   kubeadm.KindJoinWorker,
+		Short: "Render configuration for `kubeadm join` on a new worker node",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return kubeadm.Render(rf.Context(ctx), c, kubeadm.KindJoinWorker)
+		},
+	}
+
+	render.PersistentFlags().StringVar(
+		&c.BootstrapDir,
+		"bootstrap-dir",
+		c.BootstrapDir,
+		"The directory containing bootstrap files (e.g. join-token.txt)",
+	)
+	render.PersistentFlags().StringVar(
+		&c.HostIP,
+		"host-ip",
+		c.HostIP,
+		"The Tailscale IP of this node; inferred via the local 'tailscaled' API if unset",
+	)
+	render.PersistentFlags().StringVar(
+		&c.NodeName,
+		"node-name",
+		c.NodeName,
+		"The name of this node; inferred from the Tailscale device hostname if unset",
+	)
+	render.PersistentFlags().StringVar(
+		&c.Output,
+		"output",
+		c.Output,
+		"The file to write the configuration to (mode 0600); printed to STDOUT if unset",
+	)
+
+	render.AddCommand(initCmd, joinControlPlane, joinWorker)
+	cmd.AddCommand(render)
+	return cmd, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/lb"
+)
+
+func newLBCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := lb.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "lb",
+		Short: "Run a load balancer for the Kubernetes API servers",
+	}
+
+	serve := &cobra.Command{
+		Use:   "serve",
+		Short: "Proxy TCP connections on the Tailscale IP to healthy control plane nodes",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			ctx, stop := signal.NotifyContext(rf.Context(ctx), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return lb.Serve(ctx, c)
+		},
+	}
+
+	c.APIConfig.AddFlags(serve.Flags())
+	serve.Flags().StringVar(
+		&c.BindIP,
+		"bind-ip",
+		c.BindIP,
+		"The IP the load balancer listens on; defaults to the Tailscale IPv4 address of this device",
+	)
+	serve.Flags().IntVar(
+		&c.Port,
+		"port",
+		c.Port,
+		"The port the load balancer listens on",
+	)
+	serve.Flags().StringSliceVar(
+		&c.Backends,
+		"backend",
+		c.Backends,
+		"A static backend address (host:port); can be repeated",
+	)
+	serve.Flags().StringSliceVar(
+		&c.Hostnames,
+		"control-plane",
+		c.Hostnames,
+		"The exact hostname of a control plane device to discover from the Tailnet (can be repeated)",
+	)
+	serve.Flags().StringVar(
+		&c.Tag,
+		"tag",
+		c.Tag,
+		"Discover all devices with this tag (e.g. tag:k8s-control-plane) as backends",
+	)
+	serve.Flags().IntVar(
+		&c.BackendPort,
+		"backend-port",
+		c.BackendPort,
+		"The Kubernetes API server port on discovered backends",
+	)
+	serve.Flags().DurationVar(
+		&c.DiscoveryInterval,
+		"discovery-interval",
+		c.DiscoveryInterval,
+		"The time between discovering backends from the Tailnet",
+	)
+	serve.Flags().StringVar(
+		&c.StatusAddr,
+		"status-addr",
+		c.StatusAddr,
+		"The address of the status endpoint (set to \"\" to disable)",
+	)
+	serve.Flags().DurationVar(
+		&c.CheckInterval,
+		"check-interval",
+		c.CheckInterval,
+		"The time between backend health checks",
+	)
+	serve.Flags().DurationVar(
+		&c.CheckTimeout,
+		"check-timeout",
+		c.CheckTimeout,
+		"The timeout for a single backend health check",
+	)
+	serve.Flags().IntVar(
+		&c.Fall,
+		"fall",
+		c.Fall,
+		"Consecutive failed health checks before a backend is marked down",
+	)
+	serve.Flags().IntVar(
+		&c.Rise,
+		"rise",
+		c.Rise,
+		"Consecutive successful health checks before a backend is marked up",
+	)
+	serve.Flags().DurationVar(
+		&c.DialTimeout,
+		"dial-timeout",
+		c.DialTimeout,
+		"The timeout for connecting to a backend",
+	)
+	serve.Flags().DurationVar(
+		&c.DrainTimeout,
+		"drain-timeout",
+		c.DrainTimeout,
+		"How long active connections may continue after shutdown",
+	)
+
+	cmd.AddCommand(serve)
+	return cmd, nil
+}
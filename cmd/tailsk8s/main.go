@@ -0,0 +1,143 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/config"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+// rootFlags are flags that are shared by all subcommands.
+type rootFlags struct {
+	Debug  bool
+	Socket string
+}
+
+// Context adds values from the shared flags to a context.
+func (rf *rootFlags) Context(ctx context.Context) context.Context {
+	ctx = cli.WithDebug(ctx, rf.Debug)
+	return local.WithSocket(ctx, rf.Socket)
+}
+
+func run() error {
+	ctx := context.Background()
+
+	settings, err := config.Load()
+	if err != nil {
+		return err
+	}
+	rf := &rootFlags{Socket: local.DefaultSocket()}
+	cmd := &cobra.Command{
+		Use:           "tailsk8s",
+		Short:         "Manage a Kubernetes cluster networked via Tailscale",
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			return settings.Apply(cmd.Flags())
+		},
+	}
+
+	cmd.PersistentFlags().StringVar(
+		&rf.Socket,
+		"socket",
+		rf.Socket,
+		"The path to the 'tailscaled' socket used for local API calls",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&rf.Debug,
+		"debug",
+		rf.Debug,
+		"Enable extra print debugging",
+	)
+
+	cmd.AddCommand(newConfigCommand(ctx, settings))
+	cniCmd, err := newCNICommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(cniCmd)
+	subnetCmd, err := newSubnetCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(subnetCmd)
+	routeControllerCmd, err := newRouteControllerCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(routeControllerCmd)
+	approveRoutesCmd, err := newApproveRoutesCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(approveRoutesCmd)
+	kubeadmCmd, err := newKubeadmCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(kubeadmCmd)
+	haproxyCmd, err := newHAProxyCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(haproxyCmd)
+	lbCmd, err := newLBCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(lbCmd)
+	nodeCmd, err := newNodeCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(nodeCmd)
+	bootstrapCmd, err := newBootstrapCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(bootstrapCmd)
+	doctorCmd, err := newDoctorCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(doctorCmd)
+	exitNodeCmd, err := newExitNodeCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(exitNodeCmd)
+	deauthorizeCmd, err := newDeauthorizeCommand(ctx, rf)
+	if err != nil {
+		return err
+	}
+	cmd.AddCommand(deauthorizeCmd)
+
+	return cmd.Execute()
+}
+
+func main() {
+	err := run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/nodedown"
+)
+
+func newNodeCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := nodedown.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "node",
+		Short: "Manage the lifecycle of a Kubernetes node",
+	}
+
+	down := &cobra.Command{
+		Use:   "down",
+		Short: "Remove a node from the cluster and withdraw its route from the Tailnet",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return nodedown.Down(rf.Context(ctx), c)
+		},
+	}
+
+	c.APIConfig.AddFlags(down.Flags())
+	down.Flags().StringVar(
+		&c.Kubectl.Kubeconfig,
+		"kubeconfig",
+		c.Kubectl.Kubeconfig,
+		"The kubeconfig file used to drain and delete the node",
+	)
+	down.Flags().StringVar(
+		&c.Node,
+		"node",
+		c.Node,
+		"The node (and Tailscale device hostname) to take down; defaults to the local hostname",
+	)
+	down.Flags().StringVar(
+		&c.CIDR,
+		"cidr",
+		c.CIDR,
+		"The (IPv4) CIDR advertised by the node; defaults to the value of the node's advertise subnet label",
+	)
+	down.Flags().StringVar(
+		&c.StateFile,
+		"state-file",
+		c.StateFile,
+		"The file used to record completed steps",
+	)
+	down.Flags().StringVar(
+		&c.BootstrapDir,
+		"bootstrap-dir",
+		c.BootstrapDir,
+		"The bootstrap directory to clear",
+	)
+	down.Flags().StringVar(
+		&c.KubeDir,
+		"kube-dir",
+		c.KubeDir,
+		"The user's '.kube' directory to remove",
+	)
+	down.Flags().BoolVar(
+		&c.DeleteDevice,
+		"delete-device",
+		c.DeleteDevice,
+		"Also delete the Tailscale device from the Tailnet",
+	)
+	down.Flags().BoolVar(
+		&c.SkipDockerPrune,
+		"skip-docker-prune",
+		c.SkipDockerPrune,
+		"Skip cleaning up leftover Docker data",
+	)
+	down.Flags().BoolVar(
+		&c.DryRun,
+		"dry-run",
+		c.DryRun,
+		"Print the steps that would run without making any changes",
+	)
+
+	cmd.AddCommand(down)
+	return cmd, nil
+}
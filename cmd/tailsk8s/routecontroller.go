@@ -0,0 +1,101 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/config"
+	"github.com/dhermes/tailsk8s/pkg/routecontroller"
+)
+
+func newRouteControllerCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := routecontroller.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "route-controller",
+		Short: "Keep routes enabled in the Tailnet in sync with Kubernetes nodes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := config.Require(cmd.Flags(), "pod-subnet")
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(rf.Context(ctx), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return routecontroller.Run(ctx, c)
+		},
+	}
+
+	c.APIConfig.AddFlags(cmd.Flags())
+	cmd.Flags().StringVar(
+		&c.PodSubnet,
+		"pod-subnet",
+		c.PodSubnet,
+		"The cluster pod subnet; only routes within this subnet are managed",
+	)
+	cmd.Flags().StringVar(
+		&c.Kubectl.Kubeconfig,
+		"kubeconfig",
+		c.Kubectl.Kubeconfig,
+		"The kubeconfig file used to watch nodes",
+	)
+	cmd.Flags().StringVar(
+		&c.Source,
+		"source",
+		c.Source,
+		"Where node subnets are read from: \"auto\" (label, then spec.podCIDRs), \"label\" or \"pod-cidrs\"",
+	)
+	cmd.Flags().DurationVar(
+		&c.Interval,
+		"interval",
+		c.Interval,
+		"The time between reconcile passes",
+	)
+	cmd.Flags().BoolVar(
+		&c.Once,
+		"once",
+		c.Once,
+		"Run a single reconcile pass and exit",
+	)
+	cmd.Flags().BoolVar(
+		&c.DryRun,
+		"dry-run",
+		c.DryRun,
+		"Print route changes without making them",
+	)
+	cmd.Flags().StringVar(
+		&c.StateFile,
+		"state-file",
+		c.StateFile,
+		"The file that records managed nodes, so routes for nodes deleted while the controller is down are still withdrawn; set to empty to only track them in memory",
+	)
+	cmd.Flags().BoolVar(
+		&c.SkipEvents,
+		"skip-events",
+		c.SkipEvents,
+		"Do not emit Kubernetes events when routes change",
+	)
+
+	return cmd, nil
+}
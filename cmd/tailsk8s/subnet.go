@@ -0,0 +1,113 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dhermes/tailsk8s/pkg/config"
+	"github.com/dhermes/tailsk8s/pkg/subnet"
+)
+
+func newSubnetCommand(ctx context.Context, rf *rootFlags) (*cobra.Command, error) {
+	c, err := subnet.NewConfig()
+	if err != nil {
+		return nil, err
+	}
+	cmd := &cobra.Command{
+		Use:   "subnet",
+		Short: "Manage per-node pod subnet allocations",
+	}
+
+	allocate := &cobra.Command{
+		Use:   "allocate",
+		Short: "Allocate a pod subnet for a node",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			err := config.Require(cmd.Flags(), "pod-subnet")
+			if err != nil {
+				return err
+			}
+			return subnet.Allocate(rf.Context(ctx), c)
+		},
+	}
+	allocate.Flags().IntVar(
+		&c.PrefixLength,
+		"prefix-length",
+		c.PrefixLength,
+		"The prefix length of the subnet allocated to each node",
+	)
+
+	release := &cobra.Command{
+		Use:   "release",
+		Short: "Release the pod subnet allocated to a node",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return subnet.Release(rf.Context(ctx), c)
+		},
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List pod subnet allocations and any conflicts",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return subnet.List(rf.Context(ctx), c)
+		},
+	}
+
+	c.APIConfig.AddFlags(cmd.PersistentFlags())
+	cmd.PersistentFlags().StringVar(
+		&c.PodSubnet,
+		"pod-subnet",
+		c.PodSubnet,
+		"The cluster pod subnet that node subnets are allocated from, e.g. 10.100.0.0/16",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.Node,
+		"node",
+		c.Node,
+		"The node to act on; defaults to the local hostname",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.Backend,
+		"backend",
+		c.Backend,
+		"Where allocations are persisted: \"file\" or \"kubernetes\" (node labels)",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.StateFile,
+		"state-file",
+		c.StateFile,
+		"The state file used by the \"file\" backend",
+	)
+	cmd.PersistentFlags().StringVar(
+		&c.Kubectl.Kubeconfig,
+		"kubeconfig",
+		c.Kubectl.Kubeconfig,
+		"The kubeconfig file used by the \"kubernetes\" backend",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&c.SkipTailnetCheck,
+		"skip-tailnet-check",
+		c.SkipTailnetCheck,
+		"Skip checking for conflicts with routes enabled in the Tailnet (avoids using the cloud API)",
+	)
+
+	cmd.AddCommand(allocate, release, list)
+	return cmd, nil
+}
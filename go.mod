@@ -4,6 +4,7 @@ go 1.17
 
 require (
 	github.com/spf13/cobra v1.2.1
+	github.com/spf13/pflag v1.0.5
 	honnef.co/go/tools v0.2.2
 	inet.af/netaddr v0.0.0-20211027220019-c74959edd3b6
 	tailscale.com v1.18.1
@@ -14,7 +15,6 @@ require (
 	github.com/akutz/memconn v0.1.0 // indirect
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/mitchellh/go-ps v1.0.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
 	go4.org/intern v0.0.0-20211027215823-ae77deb06f29 // indirect
 	go4.org/mem v0.0.0-20201119185036-c04c5a6ff174 // indirect
 	go4.org/unsafe/assume-no-moving-gc v0.0.0-20211027215541-db492cf91b37 // indirect
@@ -0,0 +1,151 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package approver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/remix"
+)
+
+// Run runs the approver until `ctx` is cancelled (or runs a single pass if
+// `Once` is set). The policy file is re-read on every pass so it can be
+// updated without a restart.
+func Run(ctx context.Context, c Config) error {
+	err := c.APIConfig.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Rejections are only alerted once per device and route (for the lifetime
+	// of the process) to avoid sending an alert on every pass.
+	alerted := map[string]bool{}
+	if c.Once {
+		return Approve(ctx, c, alerted)
+	}
+
+	cli.Printf(ctx, "Approving routes every %s\n", c.Interval)
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		err = Approve(ctx, c, alerted)
+		if err != nil {
+			cli.Printf(ctx, "Approval pass failed: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			cli.Println(ctx, "Stopping route approver")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Approve runs a single pass: it enables every pending route that satisfies
+// the policy and logs (and optionally alerts on) every rejected route. A
+// failure for one device does not stop the pass; all failures are printed
+// and an aggregated error is returned.
+func Approve(ctx context.Context, c Config, alerted map[string]bool) error {
+	policy, err := LoadPolicy(c.PolicyFile)
+	if err != nil {
+		return err
+	}
+	devices, err := cloud.GetDevices(ctx, c.APIConfig, cloud.Empty{})
+	if err != nil {
+		return err
+	}
+
+	failures := []string{}
+	fail := func(device cloud.Device, err error) {
+		cli.Printf(ctx, "Failed to approve routes for device %s (%s): %v\n", device.ID, device.Hostname, err)
+		failures = append(failures, fmt.Sprintf("device %s: %v", device.ID, err))
+	}
+
+	// NOTE: The routes for **every** device are read before any are
+	//       evaluated so that a pending route can be checked against the
+	//       routes enabled for all other devices. Devices whose routes can't
+	//       be read (e.g. a device deleted during the pass) are skipped.
+	all := []DeviceRoutes{}
+	for _, device := range devices.Devices {
+		grr := cloud.GetRoutesRequest{DeviceID: device.ID}
+		rr, err := cloud.GetRoutes(ctx, c.APIConfig, grr)
+		if err != nil {
+			fail(device, err)
+			continue
+		}
+		all = append(all, DeviceRoutes{Device: device, Routes: *rr})
+	}
+
+	for i, dr := range all {
+		device := dr.Device
+		others := append(append([]DeviceRoutes{}, all[:i]...), all[i+1:]...)
+		approved := []string{}
+		for _, d := range policy.Evaluate(device, dr.Routes, others) {
+			if d.Approved {
+				approved = append(approved, d.Route)
+				continue
+			}
+			reject(ctx, c, device, d, alerted)
+		}
+		if len(approved) == 0 {
+			continue
+		}
+
+		if c.DryRun {
+			cli.Printf(ctx, "Would enable routes %v for device %s (%s)\n", approved, device.ID, device.Hostname)
+		} else {
+			urr := remix.UpdateRoutesRequest{DeviceID: device.ID, Enable: approved}
+			_, err = remix.UpdateRoutes(ctx, c.APIConfig, urr)
+			if err != nil {
+				fail(device, err)
+				continue
+			}
+			cli.Printf(ctx, "Enabled routes %v for device %s (%s)\n", approved, device.ID, device.Hostname)
+		}
+		// Ensure routes approved for this device are taken into account for
+		// the overlap check of devices evaluated later in this pass.
+		all[i].Routes.EnabledRoutes = append(all[i].Routes.EnabledRoutes, approved...)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to approve routes for %d device(s): %s", len(failures), strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func reject(ctx context.Context, c Config, device cloud.Device, d Decision, alerted map[string]bool) {
+	message := fmt.Sprintf("Rejected route %s advertised by device %s (%s): %s", d.Route, device.ID, device.Hostname, d.Reason)
+	key := device.ID + " " + d.Route
+	if alerted[key] {
+		cli.DebugPrintf(ctx, "%s\n", message)
+		return
+	}
+	alerted[key] = true
+	cli.Printf(ctx, "%s\n", message)
+
+	if c.AlertWebhook == "" || c.DryRun {
+		return
+	}
+	err := SendAlert(ctx, c.AlertWebhook, message)
+	if err != nil {
+		cli.Printf(ctx, "Failed to send alert: %v\n", err)
+	}
+}
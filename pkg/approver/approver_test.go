@@ -0,0 +1,108 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package approver_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dhermes/tailsk8s/pkg/approver"
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/cloudtest"
+)
+
+func newApprover(t *testing.T) (*cloudtest.Server, approver.Config) {
+	t.Helper()
+	server := cloudtest.NewServer()
+	t.Cleanup(server.Close)
+	policyFile := filepath.Join(t.TempDir(), "route-policy.yaml")
+	err := os.WriteFile(policyFile, []byte("allowed-supernets: 10.100.0.0/16\n"), 0600)
+	if err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+	return server, approver.Config{APIConfig: server.Config(), PolicyFile: policyFile}
+}
+
+func addDevice(server *cloudtest.Server, id, hostname string, advertised ...string) {
+	server.AddDevice(cloudtest.Device{
+		Device:           cloud.Device{ID: id, Hostname: hostname, Authorized: true},
+		AdvertisedRoutes: advertised,
+	})
+}
+
+func assertEnabled(t *testing.T, server *cloudtest.Server, id string, expected ...string) {
+	t.Helper()
+	d, ok := server.Device(id)
+	if !ok {
+		t.Fatalf("device %s does not exist", id)
+	}
+	if len(expected) == 0 && len(d.EnabledRoutes) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(d.EnabledRoutes, expected) {
+		t.Fatalf("device %s: expected enabled routes %v, got %v", id, expected, d.EnabledRoutes)
+	}
+}
+
+func TestApproveContinuesAfterError(t *testing.T) {
+	server, c := newApprover(t)
+	addDevice(server, "dev-a", "node-a", "10.100.1.0/24")
+	addDevice(server, "dev-b", "node-b", "10.100.2.0/24")
+	addDevice(server, "dev-c", "node-c", "10.100.3.0/24")
+	server.Fail("GET /api/v2/device/dev-a/routes", 1)
+	server.Fail("POST /api/v2/device/dev-b/routes", 1)
+
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	err := approver.Approve(ctx, c, map[string]bool{})
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to approve routes for 2 device(s): device dev-a: ") {
+		t.Fatalf("expected aggregated error, got %v", err)
+	}
+	assertEnabled(t, server, "dev-a")
+	assertEnabled(t, server, "dev-b")
+	assertEnabled(t, server, "dev-c", "10.100.3.0/24")
+
+	err = approver.Approve(ctx, c, map[string]bool{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertEnabled(t, server, "dev-a", "10.100.1.0/24")
+	assertEnabled(t, server, "dev-b", "10.100.2.0/24")
+}
+
+func TestApproveRejectsOverlap(t *testing.T) {
+	server, c := newApprover(t)
+	// Both devices advertise the same (pending) pod CIDR in the same pass;
+	// only the first one may have it enabled.
+	addDevice(server, "dev-a", "node-a", "10.100.1.0/24")
+	addDevice(server, "dev-b", "node-b", "10.100.1.0/24")
+
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	alerted := map[string]bool{}
+	err := approver.Approve(ctx, c, alerted)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertEnabled(t, server, "dev-a", "10.100.1.0/24")
+	assertEnabled(t, server, "dev-b")
+	if !alerted["dev-b 10.100.1.0/24"] {
+		t.Fatalf("expected the overlapping route to be rejected, got %v", alerted)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package approver
+
+import (
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	// DefaultPolicyFile is the policy used to approve routes.
+	DefaultPolicyFile = "/etc/tailsk8s/route-policy.yaml"
+	// DefaultInterval is the time between approval passes.
+	DefaultInterval = 30 * time.Second
+)
+
+// Config provides the core set of (CLI) inputs needed to run the route
+// approver.
+type Config struct {
+	APIConfig  cloud.Config
+	PolicyFile string
+	Interval   time.Duration
+	// AlertWebhook is an optional URL that rejected advertisements are
+	// posted to (as a Slack-compatible `{"text": "..."}` JSON payload).
+	AlertWebhook string
+	// Once indicates a single approval pass should be run.
+	Once bool
+	// DryRun indicates decisions should be printed but routes should not be
+	// enabled.
+	DryRun bool
+}
+
+// NewConfig returns a new `Config` with all relevant defaults provided and
+// options for overriding.
+func NewConfig(opts ...Option) (Config, error) {
+	ac, err := cloud.NewConfig()
+	if err != nil {
+		return Config{}, err
+	}
+
+	c := Config{
+		APIConfig:  ac,
+		PolicyFile: DefaultPolicyFile,
+		Interval:   DefaultInterval,
+	}
+	for _, opt := range opts {
+		err := opt(&c)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,230 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package approver
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/config"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+// Policy determines which advertised routes may be enabled. A policy file
+// uses the same YAML subset as `tailsk8s` configuration files, with lists
+// given as comma separated values, e.g.
+//
+//	allowed-supernets: 10.100.0.0/16
+//	required-tags: tag:k8s-node
+//	hostname-patterns: "k8s-*, pedantic-*"
+//	one-cidr-per-node: true
+type Policy struct {
+	// AllowedSupernets is the set of supernets that approved routes must be
+	// contained in; this is required.
+	AllowedSupernets []netaddr.IPPrefix
+	// RequiredTags are tags that a device must have (all of them).
+	RequiredTags []string
+	// HostnamePatterns are `path.Match()` patterns; if set, the device
+	// hostname must match at least one.
+	HostnamePatterns []string
+	// OneCIDRPerNode limits each device to a single enabled route.
+	OneCIDRPerNode bool
+}
+
+// LoadPolicy reads and validates a policy file.
+func LoadPolicy(filename string) (*Policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	values, err := config.ParseYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", filename, err)
+	}
+
+	p, err := parsePolicy(values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy file %s: %w", filename, err)
+	}
+	return p, nil
+}
+
+func parsePolicy(values map[string]string) (*Policy, error) {
+	p := &Policy{}
+	for key, value := range values {
+		switch key {
+		case "allowed-supernets":
+			for _, s := range splitList(value) {
+				prefix, err := netaddr.ParseIPPrefix(s)
+				if err != nil {
+					return nil, err
+				}
+				if prefix.Masked() != prefix {
+					return nil, fmt.Errorf("supernet %s has host bits set", prefix)
+				}
+				p.AllowedSupernets = append(p.AllowedSupernets, prefix)
+			}
+		case "required-tags":
+			p.RequiredTags = splitList(value)
+		case "hostname-patterns":
+			p.HostnamePatterns = splitList(value)
+			for _, pattern := range p.HostnamePatterns {
+				_, err := path.Match(pattern, "")
+				if err != nil {
+					return nil, fmt.Errorf("invalid hostname pattern %q: %w", pattern, err)
+				}
+			}
+		case "one-cidr-per-node":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %q: %w", key, err)
+			}
+			p.OneCIDRPerNode = b
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	if len(p.AllowedSupernets) == 0 {
+		return nil, fmt.Errorf("%q must contain at least one supernet", "allowed-supernets")
+	}
+	return p, nil
+}
+
+// DeviceRoutes is a device along with its advertised and enabled routes.
+type DeviceRoutes struct {
+	Device cloud.Device
+	Routes cloud.RoutesResponse
+}
+
+// Decision is the outcome of evaluating an advertised route against a policy.
+type Decision struct {
+	Route    string
+	Approved bool
+	// Reason explains why a route was rejected.
+	Reason string
+}
+
+// Evaluate decides which of a device's pending routes (advertised but not
+// enabled) may be enabled. A route that overlaps a route enabled for any of
+// the `others` devices is rejected, e.g. so one node can't take over the pod
+// CIDR of another node.
+func (p Policy) Evaluate(device cloud.Device, rr cloud.RoutesResponse, others []DeviceRoutes) []Decision {
+	enabled := map[string]bool{}
+	for _, e := range rr.EnabledRoutes {
+		enabled[e] = true
+	}
+	pending := []string{}
+	for _, a := range rr.AdvertisedRoutes {
+		if !enabled[a] {
+			pending = append(pending, a)
+		}
+	}
+	sort.Strings(pending)
+
+	deviceReason := p.checkDevice(device)
+	decisions := []Decision{}
+	for _, route := range pending {
+		reason := deviceReason
+		if reason == "" {
+			reason = p.checkRoute(route)
+		}
+		if reason == "" {
+			reason = checkOverlap(route, others)
+		}
+		if reason == "" && p.OneCIDRPerNode {
+			if len(rr.EnabledRoutes) > 0 {
+				reason = fmt.Sprintf("device already has enabled route(s) %s", strings.Join(rr.EnabledRoutes, ", "))
+			} else if len(pending) > 1 {
+				reason = fmt.Sprintf("device advertises %d routes (%s), only one is allowed", len(pending), strings.Join(pending, ", "))
+			}
+		}
+		decisions = append(decisions, Decision{Route: route, Approved: reason == "", Reason: reason})
+	}
+	return decisions
+}
+
+func (p Policy) checkDevice(device cloud.Device) string {
+	tags := map[string]bool{}
+	for _, t := range device.Tags {
+		tags[t] = true
+	}
+	for _, t := range p.RequiredTags {
+		if !tags[t] {
+			return fmt.Sprintf("device is missing required tag %q", t)
+		}
+	}
+
+	if len(p.HostnamePatterns) == 0 {
+		return ""
+	}
+	for _, pattern := range p.HostnamePatterns {
+		// Ignore error: patterns are validated when the policy is loaded.
+		matched, _ := path.Match(pattern, device.Hostname)
+		if matched {
+			return ""
+		}
+	}
+	return fmt.Sprintf("hostname %q does not match any allowed pattern", device.Hostname)
+}
+
+func (p Policy) checkRoute(route string) string {
+	prefix, err := netaddr.ParseIPPrefix(route)
+	if err != nil {
+		return fmt.Sprintf("invalid route: %v", err)
+	}
+	for _, s := range p.AllowedSupernets {
+		if prefix.Bits() >= s.Bits() && s.Contains(prefix.IP()) {
+			return ""
+		}
+	}
+	return "route is not contained in any allowed supernet"
+}
+
+// checkOverlap rejects a route that overlaps a route enabled for another
+// device. Default routes (i.e. exit nodes) are ignored.
+func checkOverlap(route string, others []DeviceRoutes) string {
+	prefix, err := netaddr.ParseIPPrefix(route)
+	if err != nil {
+		return fmt.Sprintf("invalid route: %v", err)
+	}
+	for _, other := range others {
+		for _, e := range other.Routes.EnabledRoutes {
+			enabled, err := netaddr.ParseIPPrefix(e)
+			if err != nil || enabled.Bits() == 0 || !enabled.Overlaps(prefix) {
+				continue
+			}
+			return fmt.Sprintf("route overlaps %s enabled for device %s (%s)", enabled, other.Device.ID, other.Device.Hostname)
+		}
+	}
+	return ""
+}
+
+func splitList(value string) []string {
+	parts := []string{}
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
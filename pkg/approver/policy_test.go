@@ -0,0 +1,166 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package approver_test
+
+import (
+	"reflect"
+	"testing"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/approver"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	base := approver.Policy{
+		AllowedSupernets: []netaddr.IPPrefix{netaddr.MustParseIPPrefix("10.100.0.0/16")},
+	}
+	tagged := base
+	tagged.RequiredTags = []string{"tag:k8s-node", "tag:prod"}
+	patterns := base
+	patterns.HostnamePatterns = []string{"k8s-*", "pedantic-*"}
+	oneCIDR := base
+	oneCIDR.OneCIDRPerNode = true
+
+	node := cloud.Device{ID: "dev-a", Hostname: "k8s-a", Tags: []string{"tag:k8s-node", "tag:prod"}}
+	others := []approver.DeviceRoutes{
+		{
+			Device: cloud.Device{ID: "dev-b", Hostname: "k8s-b"},
+			Routes: cloud.RoutesResponse{
+				AdvertisedRoutes: []string{"10.100.2.0/24", "0.0.0.0/0"},
+				EnabledRoutes:    []string{"10.100.2.0/24", "0.0.0.0/0"},
+			},
+		},
+		{
+			// Advertised (but not enabled) routes of other devices don't
+			// block approval.
+			Device: cloud.Device{ID: "dev-c", Hostname: "k8s-c"},
+			Routes: cloud.RoutesResponse{AdvertisedRoutes: []string{"10.100.3.0/24"}},
+		},
+	}
+
+	cases := []struct {
+		name       string
+		policy     approver.Policy
+		device     cloud.Device
+		advertised []string
+		enabled    []string
+		expected   []approver.Decision
+	}{
+		{
+			name:       "approved",
+			policy:     base,
+			device:     node,
+			advertised: []string{"10.100.1.0/24", "10.100.3.0/24"},
+			expected: []approver.Decision{
+				{Route: "10.100.1.0/24", Approved: true},
+				{Route: "10.100.3.0/24", Approved: true},
+			},
+		},
+		{
+			name:       "already-enabled",
+			policy:     base,
+			device:     node,
+			advertised: []string{"10.100.1.0/24"},
+			enabled:    []string{"10.100.1.0/24"},
+			expected:   []approver.Decision{},
+		},
+		{
+			name:       "outside-supernet",
+			policy:     base,
+			device:     node,
+			advertised: []string{"10.101.1.0/24", "10.0.0.0/8"},
+			expected: []approver.Decision{
+				{Route: "10.0.0.0/8", Reason: "route is not contained in any allowed supernet"},
+				{Route: "10.101.1.0/24", Reason: "route is not contained in any allowed supernet"},
+			},
+		},
+		{
+			name:       "overlaps-other-device",
+			policy:     base,
+			device:     node,
+			advertised: []string{"10.100.2.128/25", "10.100.0.0/22"},
+			expected: []approver.Decision{
+				{Route: "10.100.0.0/22", Reason: "route overlaps 10.100.2.0/24 enabled for device dev-b (k8s-b)"},
+				{Route: "10.100.2.128/25", Reason: "route overlaps 10.100.2.0/24 enabled for device dev-b (k8s-b)"},
+			},
+		},
+		{
+			name:       "tags-present",
+			policy:     tagged,
+			device:     node,
+			advertised: []string{"10.100.1.0/24"},
+			expected:   []approver.Decision{{Route: "10.100.1.0/24", Approved: true}},
+		},
+		{
+			name:       "tag-missing",
+			policy:     tagged,
+			device:     cloud.Device{ID: "dev-a", Hostname: "k8s-a", Tags: []string{"tag:k8s-node"}},
+			advertised: []string{"10.100.1.0/24"},
+			expected:   []approver.Decision{{Route: "10.100.1.0/24", Reason: `device is missing required tag "tag:prod"`}},
+		},
+		{
+			name:       "pattern-match",
+			policy:     patterns,
+			device:     cloud.Device{ID: "dev-a", Hostname: "pedantic-yonath"},
+			advertised: []string{"10.100.1.0/24"},
+			expected:   []approver.Decision{{Route: "10.100.1.0/24", Approved: true}},
+		},
+		{
+			name:       "pattern-mismatch",
+			policy:     patterns,
+			device:     cloud.Device{ID: "dev-a", Hostname: "laptop"},
+			advertised: []string{"10.100.1.0/24"},
+			expected:   []approver.Decision{{Route: "10.100.1.0/24", Reason: `hostname "laptop" does not match any allowed pattern`}},
+		},
+		{
+			name:       "one-cidr",
+			policy:     oneCIDR,
+			device:     node,
+			advertised: []string{"10.100.1.0/24"},
+			expected:   []approver.Decision{{Route: "10.100.1.0/24", Approved: true}},
+		},
+		{
+			name:       "one-cidr-already-enabled",
+			policy:     oneCIDR,
+			device:     node,
+			advertised: []string{"10.100.1.0/24", "10.100.4.0/24"},
+			enabled:    []string{"10.100.1.0/24"},
+			expected:   []approver.Decision{{Route: "10.100.4.0/24", Reason: "device already has enabled route(s) 10.100.1.0/24"}},
+		},
+		{
+			name:       "one-cidr-multiple-pending",
+			policy:     oneCIDR,
+			device:     node,
+			advertised: []string{"10.100.4.0/24", "10.100.1.0/24"},
+			expected: []approver.Decision{
+				{Route: "10.100.1.0/24", Reason: "device advertises 2 routes (10.100.1.0/24, 10.100.4.0/24), only one is allowed"},
+				{Route: "10.100.4.0/24", Reason: "device advertises 2 routes (10.100.1.0/24, 10.100.4.0/24), only one is allowed"},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			rr := cloud.RoutesResponse{AdvertisedRoutes: tc.advertised, EnabledRoutes: tc.enabled}
+			decisions := tc.policy.Evaluate(tc.device, rr, others)
+			if !reflect.DeepEqual(decisions, tc.expected) {
+				t.Fatalf("expected %+v, got %+v", tc.expected, decisions)
+			}
+		})
+	}
+}
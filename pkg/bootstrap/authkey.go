@@ -0,0 +1,57 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"tailscale.com/atomicfile"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	tailscalecli "github.com/dhermes/tailsk8s/pkg/tailscale/cli"
+)
+
+// WriteAuthKey resolves a Tailscale auth key via the secret providers (e.g.
+// from Vault or a Kubernetes `Secret`) and writes it to `c.AuthKeyFile`
+// with mode `0600`. The file can then be copied to a new machine and used via
+// `tailscale up --authkey file:{path}`, which does not need `tailsk8s`
+// installed.
+func WriteAuthKey(ctx context.Context, c Config) error {
+	if c.AuthKey == "" {
+		return errors.New("auth key is required")
+	}
+	if c.AuthKeyFile == "" {
+		return errors.New("auth key file is required")
+	}
+
+	authKey, err := tailscalecli.ReadAuthKey(ctx, c.AuthKey)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.AuthKeyFile), 0755)
+	if err != nil {
+		return err
+	}
+	err = atomicfile.WriteFile(c.AuthKeyFile, []byte(authKey+"\n"), 0600)
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "Wrote %s (mode %04o)\n", c.AuthKeyFile, 0600)
+	return nil
+}
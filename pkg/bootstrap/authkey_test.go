@@ -0,0 +1,76 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/dhermes/tailsk8s/pkg/bootstrap"
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+func TestWriteAuthKey(t *testing.T) {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	t.Setenv("TEST_TS_AUTHKEY", "tskey-auth-abc")
+	c, err := bootstrap.NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.AuthKey = "env:TEST_TS_AUTHKEY"
+	c.AuthKeyFile = filepath.Join(t.TempDir(), "k8s-bootstrap-shared", "tailscale-one-off-key")
+
+	err = bootstrap.WriteAuthKey(ctx, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err := os.ReadFile(c.AuthKeyFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(b) != "tskey-auth-abc\n" {
+		t.Fatalf("expected auth key file to contain the key, got %q", b)
+	}
+	info, err := os.Stat(c.AuthKeyFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode 0600, got %04o", info.Mode().Perm())
+	}
+}
+
+func TestWriteAuthKeyMissingSecret(t *testing.T) {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	c, err := bootstrap.NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.AuthKey = "env:TEST_TS_AUTHKEY_UNSET"
+	c.AuthKeyFile = filepath.Join(t.TempDir(), "tailscale-one-off-key")
+
+	err = bootstrap.WriteAuthKey(ctx, c)
+	if err == nil {
+		t.Fatal("expected an error for an unset environment variable")
+	}
+	_, err = os.Stat(c.AuthKeyFile)
+	if !os.IsNotExist(err) {
+		t.Fatalf("expected no auth key file to be written, got %v", err)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// Status prints the state of every known file in the bootstrap directory. If
+// a role is set, missing files required by that role are called out.
+func Status(ctx context.Context, c Config) error {
+	if c.Role != "" {
+		err := c.ValidateRole()
+		if err != nil {
+			return err
+		}
+	}
+	statuses, err := Inspect(c)
+	if err != nil {
+		return err
+	}
+
+	cli.Printf(ctx, "Bootstrap directory: %s\n", c.Dir)
+	w := tabwriter.NewWriter(cli.GetStdout(ctx), 0, 4, 2, ' ', 0)
+	_, err = w.Write([]byte("FILE\tMODE\tSTATUS\tEXPIRES\n"))
+	if err != nil {
+		return err
+	}
+	now := c.now()
+	for _, fs := range statuses {
+		mode := "-"
+		if fs.Present {
+			mode = fmt.Sprintf("%04o", fs.Mode)
+		}
+		row := []string{fs.File.Name, mode, describeStatus(c, fs), describeExpiry(fs, now)}
+		_, err = w.Write([]byte(strings.Join(row, "\t") + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// Verify checks that every file required by the configured role is present
+// and valid, that every other present file is valid and that the files which
+// expire have at least `MinValidity` remaining. This is intended to be run
+// before doing anything destructive (e.g. `kubeadm join`).
+func Verify(ctx context.Context, c Config) error {
+	err := c.ValidateRole()
+	if err != nil {
+		return err
+	}
+	statuses, err := Inspect(c)
+	if err != nil {
+		return err
+	}
+
+	problems := []string{}
+	expiring := false
+	now := c.now()
+	for _, fs := range statuses {
+		required := fs.File.IsRequiredBy(c.Role)
+		if !fs.Present {
+			if required {
+				problems = append(problems, fmt.Sprintf("%s is missing", fs.Path))
+			}
+			continue
+		}
+		for _, p := range fs.Problems {
+			problems = append(problems, fmt.Sprintf("%s %s", fs.Path, p))
+		}
+		if !required || fs.ExpiresAt.IsZero() {
+			continue
+		}
+		if fs.Expired(now) {
+			expiring = true
+			problems = append(problems, fmt.Sprintf("%s expired at %s", fs.Path, fs.ExpiresAt.Format(time.RFC3339)))
+		} else if fs.ExpiresAt.Sub(now) < c.MinValidity {
+			expiring = true
+			problems = append(problems, fmt.Sprintf("%s expires in %s (less than %s)", fs.Path, fs.ExpiresAt.Sub(now).Round(time.Second), c.MinValidity))
+		}
+	}
+
+	if len(problems) == 0 {
+		cli.Printf(ctx, "Bootstrap directory %s is ready for %s\n", c.Dir, c.Role)
+		return nil
+	}
+	cli.Printf(ctx, "Problems found in bootstrap directory %s:\n", c.Dir)
+	for _, p := range problems {
+		cli.Printf(ctx, "- %s\n", p)
+	}
+	if expiring {
+		cli.Println(ctx, "An expired join token or certificate key can be replaced by running `k8s-join-refresh.sh` on a control plane node")
+	}
+	return fmt.Errorf("bootstrap directory failed verification for %s (%d problem(s))", c.Role, len(problems))
+}
+
+// Init creates the bootstrap directory (owned by the user that invoked
+// `sudo`, if applicable) and tightens the mode of any known files that are
+// already present.
+func Init(ctx context.Context, c Config) error {
+	err := os.MkdirAll(c.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	uid, gid := cli.SudoOwner()
+	if uid >= 0 && gid >= 0 {
+		err = os.Chown(c.Dir, uid, gid)
+		if err != nil {
+			return err
+		}
+	}
+	cli.Printf(ctx, "Bootstrap directory: %s\n", c.Dir)
+
+	statuses, err := Inspect(c)
+	if err != nil {
+		return err
+	}
+	for _, fs := range statuses {
+		if !fs.Present || fs.Mode == fs.File.Mode {
+			continue
+		}
+		err = os.Chmod(fs.Path, fs.File.Mode)
+		if err != nil {
+			return err
+		}
+		cli.Printf(ctx, "Changed mode of %s from %04o to %04o\n", fs.Path, fs.Mode, fs.File.Mode)
+	}
+	return nil
+}
+
+func describeStatus(c Config, fs FileStatus) string {
+	if !fs.Present {
+		if c.Role != "" && fs.File.IsRequiredBy(c.Role) {
+			return "missing (required)"
+		}
+		return "missing"
+	}
+	if len(fs.Problems) == 0 {
+		return "ok"
+	}
+	return strings.Join(fs.Problems, "; ")
+}
+
+func describeExpiry(fs FileStatus, now time.Time) string {
+	if fs.ExpiresAt.IsZero() {
+		return "-"
+	}
+	if fs.Expired(now) {
+		return "expired"
+	}
+	return fmt.Sprintf("%s (in %s)", fs.ExpiresAt.Format(time.RFC3339), fs.ExpiresAt.Sub(now).Round(time.Minute))
+}
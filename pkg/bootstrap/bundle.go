@@ -0,0 +1,123 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/kubeadm"
+)
+
+// Bundle is the set of bootstrap files a new node needs to join the
+// cluster, keyed by filename.
+type Bundle struct {
+	Role     string            `json:"role"`
+	IssuedAt time.Time         `json:"issuedAt"`
+	Files    map[string]string `json:"files"`
+}
+
+// BuildBundle assembles a bundle for `role`. A new join token (and for
+// control plane nodes, a new certificate key) is issued for every bundle;
+// the remaining files are read from the bootstrap directory.
+func BuildBundle(ctx context.Context, c Config, role, description string) (*Bundle, error) {
+	b := &Bundle{Role: role, IssuedAt: c.now().UTC(), Files: map[string]string{}}
+	for _, f := range Files() {
+		if !f.IsRequiredBy(role) {
+			continue
+		}
+
+		var content string
+		var err error
+		switch f.Name {
+		case JoinTokenFile:
+			content, err = IssueJoinToken(ctx, c, description)
+		case CertificateKeyFile:
+			content, err = IssueCertificateKey(ctx, c)
+		case APIKeyFile:
+			if !c.ShareAPIKey {
+				continue
+			}
+			content, err = readFile(c, f)
+		default:
+			content, err = readFile(c, f)
+		}
+		if err != nil {
+			return nil, err
+		}
+		b.Files[f.Name] = content
+	}
+	return b, nil
+}
+
+// IssueJoinToken creates a new `kubeadm` bootstrap token that expires after
+// `IssueTokenTTL`.
+func IssueJoinToken(ctx context.Context, c Config, description string) (string, error) {
+	stdout, err := c.run()(
+		ctx, nil, "kubeadm", "token", "create",
+		"--ttl", c.IssueTokenTTL.String(),
+		"--description", description,
+	)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(stdout))
+	err = kubeadm.ValidateToken(token)
+	if err != nil {
+		return "", err
+	}
+	return token + "\n", nil
+}
+
+// IssueCertificateKey generates a new certificate key and re-uploads the
+// control plane certificates encrypted with it (the same as
+// `k8s-join-refresh.sh`). The uploaded certificates are deleted by `kubeadm`
+// after two hours.
+func IssueCertificateKey(ctx context.Context, c Config) (string, error) {
+	run := c.run()
+	stdout, err := run(ctx, nil, "kubeadm", "certs", "certificate-key")
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(stdout))
+	err = kubeadm.ValidateCertificateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = run(ctx, nil, "kubeadm", "init", "phase", "upload-certs", "--upload-certs", "--certificate-key", key)
+	if err != nil {
+		return "", err
+	}
+	return key + "\n", nil
+}
+
+// readFile reads and validates a file from the bootstrap directory.
+func readFile(c Config, f File) (string, error) {
+	filename := filepath.Join(c.Dir, f.Name)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	err = f.Validate(strings.TrimSpace(string(data)))
+	if err != nil {
+		return "", fmt.Errorf("invalid bootstrap file %s: %w", filename, err)
+	}
+	return string(data), nil
+}
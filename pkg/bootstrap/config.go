@@ -0,0 +1,154 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/kubeadm"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+const (
+	// DefaultDir is the directory containing bootstrap files.
+	DefaultDir = "/var/data/tailsk8s-bootstrap"
+	// DefaultTokenTTL is the default lifetime of a `kubeadm` bootstrap token.
+	DefaultTokenTTL = 24 * time.Hour
+	// DefaultCertificateKeyTTL is the lifetime of the certificates uploaded
+	// by `kubeadm init --upload-certs` (the `kubeadm-certs` secret is deleted
+	// after two hours).
+	DefaultCertificateKeyTTL = 2 * time.Hour
+	// DefaultMinValidity is the minimum remaining lifetime required by
+	// `Verify()` for files that expire.
+	DefaultMinValidity = 10 * time.Minute
+	// DefaultPort is the port used to serve join bundles.
+	DefaultPort = 8443
+	// DefaultIssueTokenTTL is the lifetime of the join token issued for
+	// each bundle.
+	DefaultIssueTokenTTL = time.Hour
+)
+
+// Config provides the core set of (CLI) inputs needed to inspect and verify
+// the bootstrap directory.
+type Config struct {
+	Dir string
+	// Role is one of the `kubeadm` configuration kinds (`init`,
+	// `join-control-plane` or `join-worker`); it determines which files are
+	// required.
+	Role              string
+	TokenTTL          time.Duration
+	CertificateKeyTTL time.Duration
+	MinValidity       time.Duration
+	// Now is used to compute expiry; defaults to `time.Now`.
+	Now func() time.Time
+
+	// BindIP is the IP that join bundles are served on; defaults to the
+	// Tailscale IP of the local device.
+	BindIP string
+	Port   int
+	// TLSCertFile and TLSKeyFile are optional; if unset, a certificate is
+	// requested from `tailscaled` (this requires HTTPS to be enabled for the
+	// Tailnet).
+	TLSCertFile string
+	TLSKeyFile  string
+	// AllowTags and AllowHostnames determine which devices may fetch a
+	// bundle; hostnames are `path.Match()` patterns, e.g. `worker-*`. Since
+	// a device chooses its own hostname (but tags are granted via ACLs),
+	// tags should be preferred.
+	AllowTags      []string
+	AllowHostnames []string
+	// AllowControlPlane allows bundles for `join-control-plane`, which
+	// include a certificate key for the control plane certificates.
+	AllowControlPlane bool
+	// ShareAPIKey includes the Tailscale API key in bundles.
+	ShareAPIKey   bool
+	IssueTokenTTL time.Duration
+	// Run executes `kubeadm`; defaults to `cli.ExecRun`.
+	Run cli.RunFunc
+	// WhoIs identifies callers; defaults to `local.WhoIs`.
+	WhoIs WhoIsFunc
+
+	// Server is the URL of a `bootstrap serve` instance to fetch from, e.g.
+	// `https://pedantic-yonath.tailnet-abcd.ts.net:8443`.
+	Server string
+	// CAFile is an optional CA bundle used to verify the server.
+	CAFile string
+
+	// AuthKey is a Tailscale auth key or a secret reference for one, e.g.
+	// `vault:secret/tailscale#auth-key`.
+	AuthKey string
+	// AuthKeyFile is where `WriteAuthKey()` writes the auth key.
+	AuthKeyFile string
+}
+
+// NewConfig returns a new `Config` with all relevant defaults provided and
+// options for overriding.
+func NewConfig(opts ...Option) (Config, error) {
+	c := Config{
+		Dir:               DefaultDir,
+		TokenTTL:          DefaultTokenTTL,
+		CertificateKeyTTL: DefaultCertificateKeyTTL,
+		MinValidity:       DefaultMinValidity,
+		Now:               time.Now,
+		Port:              DefaultPort,
+		IssueTokenTTL:     DefaultIssueTokenTTL,
+	}
+	for _, opt := range opts {
+		err := opt(&c)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+// Roles returns the supported roles.
+func Roles() []string {
+	return []string{kubeadm.KindInit, kubeadm.KindJoinControlPlane, kubeadm.KindJoinWorker}
+}
+
+func (c Config) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// ValidateRole checks that the role is one of `Roles()`.
+func (c Config) ValidateRole() error {
+	for _, role := range Roles() {
+		if c.Role == role {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown role %q, expected one of %s", c.Role, strings.Join(Roles(), ", "))
+}
+
+func (c Config) run() cli.RunFunc {
+	if c.Run == nil {
+		return cli.ExecRun
+	}
+	return c.Run
+}
+
+func (c Config) whoIs() WhoIsFunc {
+	if c.WhoIs == nil {
+		return local.WhoIs
+	}
+	return c.WhoIs
+}
@@ -0,0 +1,23 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bootstrap models the bootstrap directory (by default
+// `/var/data/tailsk8s-bootstrap`) used to share files such as the join token
+// and the certificate key between nodes. It validates that the files needed
+// for a given role are present, well-formed and have restrictive enough
+// permissions and estimates when the join token and certificate key expire.
+//
+// This is provided in a way to optimize the testable surface area (even for
+// untested parts of the code) without having any usage of `os.Exit()`.
+package bootstrap
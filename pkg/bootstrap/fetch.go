@@ -0,0 +1,146 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"tailscale.com/atomicfile"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/kubeadm"
+)
+
+const (
+	// fetchTimeout bounds the bundle request; issuing a certificate key
+	// re-uploads the control plane certificates, which can take a while.
+	fetchTimeout = time.Minute
+)
+
+// Fetch requests a join bundle for the configured role from a
+// `bootstrap serve` instance and writes the files into the bootstrap
+// directory with the expected modes.
+func Fetch(ctx context.Context, c Config) error {
+	if c.Role != kubeadm.KindJoinWorker && c.Role != kubeadm.KindJoinControlPlane {
+		return fmt.Errorf("bundles can only be fetched for %s or %s, not %q", kubeadm.KindJoinWorker, kubeadm.KindJoinControlPlane, c.Role)
+	}
+	if c.Server == "" {
+		return errors.New("server is required")
+	}
+	client, err := fetchClient(c)
+	if err != nil {
+		return err
+	}
+
+	u := strings.TrimSuffix(c.Server, "/") + BundlePath + "?role=" + url.QueryEscape(c.Role)
+	cli.DebugPrintf(ctx, "Fetching bundle: %s\n", u)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to fetch bundle (status %d, body %q)", resp.StatusCode, body)
+	}
+
+	var b Bundle
+	err = json.NewDecoder(resp.Body).Decode(&b)
+	if err != nil {
+		return err
+	}
+	if b.Role != c.Role {
+		return fmt.Errorf("received bundle for %s, expected %s", b.Role, c.Role)
+	}
+	return WriteBundle(ctx, c, b)
+}
+
+// WriteBundle validates every file in a bundle and then atomically writes
+// them into the bootstrap directory. No files are written if any file is
+// unknown or invalid.
+func WriteBundle(ctx context.Context, c Config, b Bundle) error {
+	names := []string{}
+	for name, content := range b.Files {
+		f, ok := LookupFile(name)
+		if !ok {
+			return fmt.Errorf("bundle contains unknown file %q", name)
+		}
+		err := f.Validate(strings.TrimSpace(content))
+		if err != nil {
+			return fmt.Errorf("bundle contains invalid %s: %w", name, err)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	err := os.MkdirAll(c.Dir, 0755)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		f, _ := LookupFile(name)
+		filename := filepath.Join(c.Dir, name)
+		err = atomicfile.WriteFile(filename, []byte(b.Files[name]), f.Mode)
+		if err != nil {
+			return err
+		}
+		cli.Printf(ctx, "Wrote %s (mode %04o)\n", filename, f.Mode)
+	}
+
+	_, ok := b.Files[APIKeyFile]
+	_, err = os.Stat(filepath.Join(c.Dir, APIKeyFile))
+	if !ok && errors.Is(err, os.ErrNotExist) {
+		cli.Printf(ctx, "The bundle does not include %s; copy it separately if needed\n", APIKeyFile)
+	}
+	return nil
+}
+
+func fetchClient(c Config) (*http.Client, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: fetchTimeout}, nil
+}
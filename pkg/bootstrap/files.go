@@ -0,0 +1,146 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/kubeadm"
+)
+
+const (
+	// APIKeyFile contains the Tailscale API key.
+	APIKeyFile = "tailscale-api-key"
+	// JoinTokenFile contains the `kubeadm` bootstrap token.
+	JoinTokenFile = "join-token.txt"
+	// CertificateKeyFile contains the key used to decrypt the control plane
+	// certificates uploaded by `kubeadm`.
+	CertificateKeyFile = "certificate-key.txt"
+	// CACertHashFile contains the hash of the cluster CA certificate.
+	CACertHashFile = "ca-cert-hash.txt"
+	// LoadBalancerFile contains the Tailscale IP of the control plane load
+	// balancer.
+	LoadBalancerFile = "control-plane-load-balancer.txt"
+	// KubeconfigFile contains the admin kubeconfig.
+	KubeconfigFile = "kube-config.yaml"
+)
+
+// File describes a file in the bootstrap directory.
+type File struct {
+	Name string
+	// Mode is the mode the scripts create the file with.
+	Mode os.FileMode
+	// Secret files must not be accessible by the group or other users.
+	Secret bool
+	// RequiredBy is the set of roles that need the file.
+	RequiredBy []string
+	// TTL returns how long the file is valid for after it was written; it
+	// is `nil` for files that don't expire.
+	TTL func(c Config) time.Duration
+	// Validate checks the (trimmed) contents.
+	Validate func(value string) error
+}
+
+// IsRequiredBy checks if the file is needed for a role.
+func (f File) IsRequiredBy(role string) bool {
+	for _, r := range f.RequiredBy {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Files returns every known file in the bootstrap directory, with the modes
+// used by `k8s-primary-init.sh` and `k8s-join-refresh.sh`.
+func Files() []File {
+	joins := []string{kubeadm.KindJoinControlPlane, kubeadm.KindJoinWorker}
+	return []File{
+		{
+			Name:       APIKeyFile,
+			Mode:       0400,
+			Secret:     true,
+			RequiredBy: Roles(),
+			Validate:   validateNonEmpty,
+		},
+		{
+			Name:       JoinTokenFile,
+			Mode:       0400,
+			Secret:     true,
+			RequiredBy: joins,
+			TTL:        func(c Config) time.Duration { return c.TokenTTL },
+			Validate:   kubeadm.ValidateToken,
+		},
+		{
+			Name:       CertificateKeyFile,
+			Mode:       0400,
+			Secret:     true,
+			RequiredBy: []string{kubeadm.KindJoinControlPlane},
+			TTL:        func(c Config) time.Duration { return c.CertificateKeyTTL },
+			Validate:   kubeadm.ValidateCertificateKey,
+		},
+		{
+			Name:       CACertHashFile,
+			Mode:       0444,
+			RequiredBy: joins,
+			Validate: func(value string) error {
+				_, err := kubeadm.NormalizeCACertHash(value)
+				return err
+			},
+		},
+		{
+			Name:       LoadBalancerFile,
+			Mode:       0444,
+			RequiredBy: joins,
+			Validate: func(value string) error {
+				_, err := kubeadm.ValidateIPv4("control plane load balancer", value)
+				return err
+			},
+		},
+		{
+			Name:       KubeconfigFile,
+			Mode:       0444,
+			RequiredBy: joins,
+			Validate:   validateKubeconfig,
+		},
+	}
+}
+
+func validateNonEmpty(value string) error {
+	if value == "" {
+		return errors.New("file is empty")
+	}
+	return nil
+}
+
+func validateKubeconfig(value string) error {
+	if !strings.Contains(value, "kind: Config") || !strings.Contains(value, "clusters:") {
+		return errors.New("file does not look like a kubeconfig (expected `kind: Config` and `clusters:`)")
+	}
+	return nil
+}
+
+// LookupFile finds a known file by name.
+func LookupFile(name string) (File, bool) {
+	for _, f := range Files() {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return File{}, false
+}
@@ -0,0 +1,228 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tailscale.com/client/tailscale/apitype"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/kubeadm"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+const (
+	// BundlePath is the HTTP path that join bundles are served on.
+	BundlePath = "/v1/bundle"
+	// shutdownTimeout is the time in-flight requests have to complete when
+	// the server is stopped.
+	shutdownTimeout = 5 * time.Second
+)
+
+// WhoIsFunc identifies the Tailscale node that owns a remote address.
+type WhoIsFunc func(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error)
+
+// Serve serves join bundles over HTTPS until `ctx` is cancelled. Callers are
+// identified via the `tailscaled` local API and must match `AllowTags` or
+// `AllowHostnames`.
+func Serve(ctx context.Context, c Config) error {
+	if len(c.AllowTags) == 0 && len(c.AllowHostnames) == 0 {
+		return errors.New("at least one allowed tag or hostname pattern is required")
+	}
+	if c.BindIP == "" {
+		ip, err := local.SelfIPv4(ctx)
+		if err != nil {
+			return err
+		}
+		c.BindIP = ip.String()
+	}
+	tlsConfig, host, err := serverTLSConfig(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	addr := net.JoinHostPort(c.BindIP, strconv.Itoa(c.Port))
+	var lc net.ListenConfig
+	ln, err := lc.Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{
+		Handler:     NewHandler(c),
+		BaseContext: func(_ net.Listener) context.Context { return ctx },
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(tls.NewListener(ln, tlsConfig))
+	}()
+	if host == "" {
+		host = c.BindIP
+	}
+	cli.Printf(ctx, "Serving join bundles on https://%s%s\n", net.JoinHostPort(host, strconv.Itoa(c.Port)), BundlePath)
+
+	select {
+	case err = <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
+
+// serverTLSConfig loads the configured certificate or, if none is
+// configured, uses certificates issued via `tailscaled` for the local
+// device's domain (which is also returned).
+func serverTLSConfig(ctx context.Context, c Config) (*tls.Config, string, error) {
+	if c.TLSCertFile != "" || c.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, "", err
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, "", nil
+	}
+
+	domain, err := local.CertDomain(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	// NOTE: The certificate is requested for every handshake; `tailscaled`
+	//       caches it on disk and handles renewal.
+	getCertificate := func(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return local.Certificate(ctx, domain)
+	}
+	return &tls.Config{GetCertificate: getCertificate, MinVersion: tls.VersionTLS12}, domain, nil
+}
+
+// NewHandler returns an HTTP handler that serves join bundles on
+// `BundlePath`.
+func NewHandler(c Config) http.Handler {
+	bh := &bundleHandler{Config: c}
+	mux := http.NewServeMux()
+	mux.Handle(BundlePath, bh)
+	return mux
+}
+
+type bundleHandler struct {
+	Config Config
+	// mu ensures a single bundle is issued at a time, since issuing a
+	// certificate key replaces the uploaded control plane certificates.
+	mu sync.Mutex
+}
+
+func (bh *bundleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	who, err := bh.Config.whoIs()(ctx, r.RemoteAddr)
+	if err != nil {
+		cli.Printf(ctx, "Failed to identify %s: %v\n", r.RemoteAddr, err)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	caller, err := Authorize(bh.Config, who)
+	if err != nil {
+		cli.Printf(ctx, "Denied bundle request from %s: %v\n", r.RemoteAddr, err)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	role := r.URL.Query().Get("role")
+	if role == "" {
+		role = kubeadm.KindJoinWorker
+	}
+	if role != kubeadm.KindJoinWorker && role != kubeadm.KindJoinControlPlane {
+		http.Error(w, fmt.Sprintf("unsupported role %q", role), http.StatusBadRequest)
+		return
+	}
+	if role == kubeadm.KindJoinControlPlane && !bh.Config.AllowControlPlane {
+		cli.Printf(ctx, "Denied %s bundle request from %s (%s)\n", role, caller, r.RemoteAddr)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	bh.mu.Lock()
+	b, err := BuildBundle(ctx, bh.Config, role, fmt.Sprintf("tailsk8s bootstrap bundle for %s", caller))
+	bh.mu.Unlock()
+	if err != nil {
+		cli.Printf(ctx, "Failed to build %s bundle for %s: %v\n", role, caller, err)
+		http.Error(w, "failed to build bundle", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	err = json.NewEncoder(w).Encode(b)
+	if err != nil {
+		cli.Printf(ctx, "Failed to send %s bundle to %s: %v\n", role, caller, err)
+		return
+	}
+	cli.Printf(ctx, "Issued %s bundle to %s (%s)\n", role, caller, r.RemoteAddr)
+}
+
+// Authorize checks that the calling node has one of the allowed tags or a
+// hostname matching one of the allowed patterns. The caller's hostname is
+// returned.
+//
+// The hostname (and by default the MagicDNS name) is reported by the device
+// itself, so any device in the Tailnet can match a hostname pattern. Tags can
+// only be applied by ACL tag owners, so they should be preferred.
+func Authorize(c Config, who *apitype.WhoIsResponse) (string, error) {
+	if who == nil || who.Node == nil {
+		return "", errors.New("caller is not a known Tailscale node")
+	}
+	hostname := who.Node.Hostinfo.Hostname
+	// The first label of the MagicDNS name may differ from the hostname
+	// (e.g. when the name was de-duplicated or renamed in the admin console).
+	machineName := strings.SplitN(who.Node.Name, ".", 2)[0]
+
+	for _, tag := range who.Node.Tags {
+		for _, allowed := range c.AllowTags {
+			if tag == allowed {
+				return hostname, nil
+			}
+		}
+	}
+	for _, pattern := range c.AllowHostnames {
+		for _, name := range []string{hostname, machineName} {
+			if name == "" {
+				continue
+			}
+			matched, err := path.Match(pattern, name)
+			if err != nil {
+				return "", fmt.Errorf("invalid hostname pattern %q: %w", pattern, err)
+			}
+			if matched {
+				return hostname, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("node %q (tags %v) does not have an allowed tag or hostname", hostname, who.Node.Tags)
+}
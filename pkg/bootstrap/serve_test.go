@@ -0,0 +1,233 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+
+	"github.com/dhermes/tailsk8s/pkg/bootstrap"
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+const (
+	testToken          = "abcdef.0123456789abcdef"
+	testCertificateKey = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+)
+
+// fakeKubeadm is a stand-in for the `kubeadm` commands used to issue a join
+// token and certificate key.
+type fakeKubeadm struct {
+	mu       sync.Mutex
+	Commands []string
+}
+
+func (fk *fakeKubeadm) Run(_ context.Context, _ []byte, name string, args ...string) ([]byte, error) {
+	fk.mu.Lock()
+	defer fk.mu.Unlock()
+	command := strings.Join(append([]string{name}, args...), " ")
+	fk.Commands = append(fk.Commands, command)
+	switch {
+	case strings.HasPrefix(command, "kubeadm token create "):
+		return []byte(testToken + "\n"), nil
+	case command == "kubeadm certs certificate-key":
+		return []byte(testCertificateKey + "\n"), nil
+	case strings.HasPrefix(command, "kubeadm init phase upload-certs "):
+		return nil, nil
+	}
+	return nil, errors.New("unexpected command: " + command)
+}
+
+func newServeConfig(t *testing.T, fk *fakeKubeadm, who *apitype.WhoIsResponse, whoErr error) bootstrap.Config {
+	t.Helper()
+	c, err := bootstrap.NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.Dir = t.TempDir()
+	files := map[string]string{
+		bootstrap.APIKeyFile:       "tskey-api-abc\n",
+		bootstrap.CACertHashFile:   strings.Repeat("ab", 32) + "\n",
+		bootstrap.LoadBalancerFile: "100.64.0.10\n",
+		bootstrap.KubeconfigFile:   "apiVersion: v1\nkind: Config\nclusters: []\n",
+	}
+	for name, content := range files {
+		err = os.WriteFile(filepath.Join(c.Dir, name), []byte(content), 0400)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	c.AllowTags = []string{"tag:k8s-worker"}
+	c.AllowHostnames = []string{"worker-*"}
+	c.Run = fk.Run
+	c.WhoIs = func(_ context.Context, _ string) (*apitype.WhoIsResponse, error) {
+		return who, whoErr
+	}
+	return c
+}
+
+func whoIs(hostname, name string, tags ...string) *apitype.WhoIsResponse {
+	return &apitype.WhoIsResponse{
+		Node: &tailcfg.Node{
+			Name:     name,
+			Hostinfo: tailcfg.Hostinfo{Hostname: hostname},
+			Tags:     tags,
+		},
+	}
+}
+
+func serve(c bootstrap.Config, method, target string) *httptest.ResponseRecorder {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	r := httptest.NewRequest(method, target, nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	bootstrap.NewHandler(c).ServeHTTP(w, r)
+	return w
+}
+
+func TestHandlerAllowed(t *testing.T) {
+	cases := []struct {
+		name string
+		who  *apitype.WhoIsResponse
+	}{
+		{name: "tag", who: whoIs("laptop", "laptop.tailnet-abcd.ts.net.", "tag:k8s-worker")},
+		{name: "hostname", who: whoIs("worker-1", "laptop.tailnet-abcd.ts.net.")},
+		{name: "magicdns-name", who: whoIs("laptop", "worker-2.tailnet-abcd.ts.net.")},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fk := &fakeKubeadm{}
+			c := newServeConfig(t, fk, tc.who, nil)
+
+			w := serve(c, http.MethodGet, bootstrap.BundlePath)
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d (%q)", w.Code, w.Body.String())
+			}
+			var b bootstrap.Bundle
+			err := json.Unmarshal(w.Body.Bytes(), &b)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if b.Role != "join-worker" {
+				t.Fatalf("expected join-worker bundle, got %q", b.Role)
+			}
+			if b.Files[bootstrap.JoinTokenFile] != testToken+"\n" {
+				t.Fatalf("expected an issued join token, got %q", b.Files[bootstrap.JoinTokenFile])
+			}
+			if _, ok := b.Files[bootstrap.APIKeyFile]; ok {
+				t.Fatal("expected the API key to not be shared")
+			}
+			if _, ok := b.Files[bootstrap.CertificateKeyFile]; ok {
+				t.Fatal("expected no certificate key in a worker bundle")
+			}
+		})
+	}
+}
+
+func TestHandlerForbidden(t *testing.T) {
+	cases := []struct {
+		name   string
+		who    *apitype.WhoIsResponse
+		whoErr error
+		target string
+	}{
+		{name: "denied", who: whoIs("laptop", "laptop.tailnet-abcd.ts.net.", "tag:k8s-other"), target: bootstrap.BundlePath},
+		{name: "unknown-node", who: &apitype.WhoIsResponse{}, target: bootstrap.BundlePath},
+		{name: "whois-error", whoErr: errors.New("no match for IP:port"), target: bootstrap.BundlePath},
+		{
+			name:   "control-plane-not-allowed",
+			who:    whoIs("worker-1", "worker-1.tailnet-abcd.ts.net.", "tag:k8s-worker"),
+			target: bootstrap.BundlePath + "?role=join-control-plane",
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			fk := &fakeKubeadm{}
+			c := newServeConfig(t, fk, tc.who, tc.whoErr)
+
+			w := serve(c, http.MethodGet, tc.target)
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("expected status 403, got %d (%q)", w.Code, w.Body.String())
+			}
+			if strings.Contains(w.Body.String(), "kind: Config") {
+				t.Fatal("expected no bundle contents in the response")
+			}
+			if len(fk.Commands) != 0 {
+				t.Fatalf("expected no join token to be issued, got %v", fk.Commands)
+			}
+		})
+	}
+}
+
+func TestHandlerControlPlane(t *testing.T) {
+	fk := &fakeKubeadm{}
+	c := newServeConfig(t, fk, whoIs("worker-1", "", "tag:k8s-worker"), nil)
+	c.AllowControlPlane = true
+	c.ShareAPIKey = true
+
+	w := serve(c, http.MethodGet, bootstrap.BundlePath+"?role=join-control-plane")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d (%q)", w.Code, w.Body.String())
+	}
+	var b bootstrap.Bundle
+	err := json.Unmarshal(w.Body.Bytes(), &b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.Files[bootstrap.CertificateKeyFile] != testCertificateKey+"\n" {
+		t.Fatalf("expected an issued certificate key, got %q", b.Files[bootstrap.CertificateKeyFile])
+	}
+	if b.Files[bootstrap.APIKeyFile] != "tskey-api-abc\n" {
+		t.Fatalf("expected the API key to be shared, got %q", b.Files[bootstrap.APIKeyFile])
+	}
+}
+
+func TestHandlerMethodNotAllowed(t *testing.T) {
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		fk := &fakeKubeadm{}
+		c := newServeConfig(t, fk, whoIs("worker-1", "", "tag:k8s-worker"), nil)
+
+		w := serve(c, method, bootstrap.BundlePath)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Fatalf("%s: expected status 405, got %d", method, w.Code)
+		}
+		if len(fk.Commands) != 0 {
+			t.Fatalf("%s: expected no join token to be issued, got %v", method, fk.Commands)
+		}
+	}
+}
+
+func TestHandlerUnsupportedRole(t *testing.T) {
+	fk := &fakeKubeadm{}
+	c := newServeConfig(t, fk, whoIs("worker-1", "", "tag:k8s-worker"), nil)
+
+	w := serve(c, http.MethodGet, bootstrap.BundlePath+"?role=init")
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bootstrap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileStatus is the result of inspecting a single bootstrap file.
+type FileStatus struct {
+	File    File
+	Path    string
+	Present bool
+	Mode    os.FileMode
+	ModTime time.Time
+	// ExpiresAt is estimated from the modification time (the scripts always
+	// re-create the file); it is zero for files that don't expire.
+	ExpiresAt time.Time
+	// Problems describes anything wrong with a present file; a missing (or
+	// expired) file is not considered a problem here since that depends on
+	// the role.
+	Problems []string
+}
+
+// Expired checks if the file has expired as of `now`.
+func (fs FileStatus) Expired(now time.Time) bool {
+	return !fs.ExpiresAt.IsZero() && !now.Before(fs.ExpiresAt)
+}
+
+// Inspect checks every known file in the bootstrap directory.
+func Inspect(c Config) ([]FileStatus, error) {
+	statuses := []FileStatus{}
+	for _, f := range Files() {
+		fs, err := inspectFile(c, f)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, fs)
+	}
+	return statuses, nil
+}
+
+func inspectFile(c Config, f File) (FileStatus, error) {
+	fs := FileStatus{File: f, Path: filepath.Join(c.Dir, f.Name)}
+	info, err := os.Stat(fs.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fs, nil
+	}
+	if err != nil {
+		return FileStatus{}, err
+	}
+
+	fs.Present = true
+	fs.Mode = info.Mode().Perm()
+	fs.ModTime = info.ModTime()
+	if f.TTL != nil {
+		fs.ExpiresAt = fs.ModTime.Add(f.TTL(c))
+	}
+
+	if info.IsDir() {
+		fs.Problems = append(fs.Problems, "is a directory")
+		return fs, nil
+	}
+	if f.Secret && fs.Mode&0077 != 0 {
+		fs.Problems = append(fs.Problems, fmt.Sprintf("mode %04o allows access by other users (expected %04o)", fs.Mode, f.Mode))
+	}
+	if !f.Secret && fs.Mode&0022 != 0 {
+		fs.Problems = append(fs.Problems, fmt.Sprintf("mode %04o allows writes by other users (expected %04o)", fs.Mode, f.Mode))
+	}
+
+	data, err := os.ReadFile(fs.Path)
+	if err != nil {
+		fs.Problems = append(fs.Problems, fmt.Sprintf("cannot be read: %v", err))
+		return fs, nil
+	}
+	err = f.Validate(strings.TrimSpace(string(data)))
+	if err != nil {
+		fs.Problems = append(fs.Problems, err.Error())
+	}
+	return fs, nil
+}
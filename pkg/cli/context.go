@@ -20,12 +20,29 @@ import (
 	"os"
 )
 
+type stdinKey struct{}
+
 type stdoutKey struct{}
 
 type stderrKey struct{}
 
 type debugKey struct{}
 
+// WithStdin adds a STDIN reader to a context.
+func WithStdin(ctx context.Context, r io.Reader) context.Context {
+	return context.WithValue(ctx, stdinKey{}, r)
+}
+
+// GetStdin enables STDIN to be specified on a context; if not provided,
+// falls back to `os.Stdin`.
+func GetStdin(ctx context.Context) io.Reader {
+	r, ok := ctx.Value(stdinKey{}).(io.Reader)
+	if ok && r != nil {
+		return r
+	}
+	return os.Stdin
+}
+
 // WithStdout adds a STDOUT writer to a context.
 func WithStdout(ctx context.Context, w io.Writer) context.Context {
 	return context.WithValue(ctx, stdoutKey{}, w)
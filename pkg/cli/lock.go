@@ -0,0 +1,50 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"time"
+)
+
+// lockFilePoll is the time between attempts to acquire a lock file.
+const lockFilePoll = 100 * time.Millisecond
+
+// LockFile acquires a host-level (exclusive) lock on `filename`, blocking
+// until the lock is acquired or `ctx` is done. The `description` is used in
+// the message printed if the lock is already held by another process. The
+// returned function releases the lock.
+func LockFile(ctx context.Context, description, filename string) (func(), error) {
+	waiting := false
+	for {
+		unlock, acquired, err := tryLockFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		if acquired {
+			return unlock, nil
+		}
+
+		if !waiting {
+			Printf(ctx, "Waiting for lock on %s: %s\n", description, filename)
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockFilePoll):
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package cli
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile attempts to acquire an exclusive `flock(2)` on `filename`
+// without blocking. The lock is released when the process exits, so a
+// crashed process can't leave the lock held.
+func tryLockFile(filename string) (func(), bool, error) {
+	// NOTE: The file is opened read-only when it already exists so that any
+	//       user that can read the file can acquire the lock.
+	f, err := os.OpenFile(filename, os.O_RDONLY, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = os.OpenFile(filename, os.O_RDONLY|os.O_CREATE, 0644)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		f.Close()
+		return nil, false, nil
+	}
+	if err != nil {
+		f.Close()
+		return nil, false, err
+	}
+
+	unlock := func() {
+		// Ignore errors: closing the file releases the lock.
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		_ = f.Close()
+	}
+	return unlock, true, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Confirm prints a yes / no prompt and reads the answer from the STDIN
+// attached to the current context. Only "y" or "yes" (case insensitive) are
+// treated as confirmation; if STDIN is closed, the answer is "no".
+func Confirm(ctx context.Context, prompt string) (bool, error) {
+	_, err := Printf(ctx, "%s [y/N]: ", prompt)
+	if err != nil {
+		return false, err
+	}
+
+	answer, err := bufio.NewReader(GetStdin(ctx)).ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		Println(ctx, "")
+		err = nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
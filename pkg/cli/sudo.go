@@ -0,0 +1,36 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os"
+	"strconv"
+)
+
+// SudoOwner returns the user and group that invoked `sudo`, or `-1` for
+// both if not running via `sudo`. This is intended to be used with
+// `os.Chown()` so that files written by a command run via `sudo` are owned
+// by the invoking user (`-1` leaves the owner unchanged).
+func SudoOwner() (int, int) {
+	uid, err := strconv.Atoi(os.Getenv("SUDO_UID"))
+	if err != nil {
+		return -1, -1
+	}
+	gid, err := strconv.Atoi(os.Getenv("SUDO_GID"))
+	if err != nil {
+		return -1, -1
+	}
+	return uid, gid
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Drift describes a difference between rendered CNI configuration and the
+// contents of a CNI configuration directory.
+type Drift struct {
+	Filename string
+	Reason   string
+}
+
+// CheckFiles compares rendered files with the contents of `dir`. Files are
+// compared as JSON (so formatting differences are not considered drift).
+// Stale managed files and any other network configuration files in `dir`
+// are also reported since the container runtime uses the first file (in
+// lexical order) to configure pod networking.
+func CheckFiles(dir string, files []File) ([]Drift, error) {
+	drift := []Drift{}
+	current := map[string]bool{}
+	for _, f := range files {
+		current[f.Name] = true
+		existing, err := os.ReadFile(filepath.Join(dir, f.Name))
+		if errors.Is(err, os.ErrNotExist) {
+			drift = append(drift, Drift{Filename: f.Name, Reason: "missing"})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		equal, err := jsonEqual(existing, f.Content)
+		if err != nil {
+			drift = append(drift, Drift{Filename: f.Name, Reason: "invalid JSON: " + err.Error()})
+			continue
+		}
+		if !equal {
+			drift = append(drift, Drift{Filename: f.Name, Reason: "contents differ from rendered configuration"})
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return drift, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	managed := map[string]bool{}
+	for _, name := range ManagedFilenames() {
+		managed[name] = true
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if current[name] || entry.IsDir() || !isNetworkConfig(name) {
+			continue
+		}
+		if managed[name] {
+			drift = append(drift, Drift{Filename: name, Reason: "stale (not part of rendered configuration)"})
+			continue
+		}
+		drift = append(drift, Drift{Filename: name, Reason: "unmanaged network configuration"})
+	}
+
+	sort.SliceStable(drift, func(i, j int) bool {
+		return drift[i].Filename < drift[j].Filename
+	})
+	return drift, nil
+}
+
+func isNetworkConfig(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".conf" || ext == ".conflist" || ext == ".json"
+}
+
+func jsonEqual(a, b []byte) (bool, error) {
+	var va, vb interface{}
+	err := json.Unmarshal(a, &va)
+	if err != nil {
+		return false, err
+	}
+	err = json.Unmarshal(b, &vb)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(va, vb), nil
+}
+
+// DriftSummary formats drift as a bulleted list.
+func DriftSummary(drift []Drift) string {
+	lines := make([]string, 0, len(drift))
+	for _, d := range drift {
+		lines = append(lines, "- "+d.Filename+": "+d.Reason)
+	}
+	return strings.Join(lines, "\n")
+}
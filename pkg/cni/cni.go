@@ -0,0 +1,98 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/advertise"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+// Render validates the node subnet, renders CNI configuration and writes it
+// to the CNI configuration directory (or prints it to STDOUT).
+func Render(ctx context.Context, c Config) error {
+	files, err := validateAndRender(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	if c.Stdout {
+		for _, f := range files {
+			cli.Printf(ctx, "# %s\n%s", filepath.Join(c.Dir, f.Name), f.Content)
+		}
+		return nil
+	}
+	return WriteFiles(ctx, c.Dir, files)
+}
+
+// Check validates the node subnet, renders CNI configuration and compares it
+// to the contents of the CNI configuration directory. An error is returned if
+// any drift is detected.
+func Check(ctx context.Context, c Config) error {
+	files, err := validateAndRender(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	drift, err := CheckFiles(c.Dir, files)
+	if err != nil {
+		return err
+	}
+	if len(drift) > 0 {
+		return fmt.Errorf("CNI configuration in %s has drifted:\n%s", c.Dir, DriftSummary(drift))
+	}
+
+	cli.Printf(ctx, "CNI configuration in %s matches subnet %s\n", c.Dir, c.Subnet)
+	return nil
+}
+
+func validateAndRender(ctx context.Context, c Config) ([]File, error) {
+	cidr, err := ParseSubnet(c.Subnet)
+	if err != nil {
+		return nil, err
+	}
+	if !c.SkipAdvertiseCheck {
+		err = ValidateAdvertised(ctx, cidr)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return RenderFiles(c)
+}
+
+// ValidateAdvertised ensures the local node advertises `cidr` to the Tailnet
+// (via the local `tailscaled` API), i.e. pod traffic for the subnet will be
+// routed to this node.
+func ValidateAdvertised(ctx context.Context, cidr netaddr.IPPrefix) error {
+	prefs, err := local.GetPrefs(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !advertise.IPPrefixesContain(prefs.AdvertiseRoutes, cidr) {
+		return fmt.Errorf(
+			"subnet %s is not advertised by this node (advertised routes: %v); run `tailscale-advertise` first or skip this check",
+			cidr, prefs.AdvertiseRoutes,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+import (
+	"fmt"
+
+	"inet.af/netaddr"
+)
+
+const (
+	// DefaultDir is the directory where the container runtime looks for CNI
+	// network configuration.
+	DefaultDir = "/etc/cni/net.d"
+	// DefaultCNIVersion is the CNI specification version used in rendered
+	// configuration.
+	DefaultCNIVersion = "0.4.0"
+	// DefaultNetworkName is the name of the bridge network.
+	DefaultNetworkName = "tailsk8s"
+	// DefaultBridge is the name of the Linux bridge created on the node.
+	DefaultBridge = "cnio0"
+)
+
+// Config provides the core set of (CLI) inputs needed to render CNI
+// configuration for a node.
+type Config struct {
+	Subnet      string
+	Dir         string
+	CNIVersion  string
+	NetworkName string
+	Bridge      string
+	// ConfList indicates the bridge network should be rendered as a
+	// `.conflist` (network configuration list) instead of a `.conf` file.
+	ConfList bool
+	// SkipAdvertiseCheck disables validating that the node advertises
+	// `Subnet` to the Tailnet.
+	SkipAdvertiseCheck bool
+	// Stdout indicates rendered files should be printed rather than written.
+	Stdout bool
+}
+
+// NewConfig returns a new `Config` with all relevant defaults provided and
+// options for overriding.
+func NewConfig(opts ...Option) (Config, error) {
+	c := Config{
+		Dir:         DefaultDir,
+		CNIVersion:  DefaultCNIVersion,
+		NetworkName: DefaultNetworkName,
+		Bridge:      DefaultBridge,
+	}
+	for _, opt := range opts {
+		err := opt(&c)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+// ParseSubnet parses and validates the node subnet; it must be an IPv4
+// CIDR with no host bits set (e.g. `10.100.2.0/24`).
+func ParseSubnet(subnet string) (netaddr.IPPrefix, error) {
+	cidr, err := netaddr.ParseIPPrefix(subnet)
+	if err != nil {
+		return netaddr.IPPrefix{}, err
+	}
+	if !cidr.IP().Is4() {
+		return netaddr.IPPrefix{}, fmt.Errorf("subnet %s is not an IPv4 CIDR", cidr)
+	}
+	if cidr.Masked() != cidr {
+		return netaddr.IPPrefix{}, fmt.Errorf("subnet %s has host bits set, did you mean %s?", cidr, cidr.Masked())
+	}
+	return cidr, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// BridgeSubnet finds the subnet used by the `bridge` plugin in the network
+// configuration that the container runtime will use, i.e. the first network
+// configuration file in `dir` (in lexical order). The filename is also
+// returned.
+func BridgeSubnet(dir string) (string, string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && isNetworkConfig(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return "", "", fmt.Errorf("no network configuration files in %s", dir)
+	}
+	sort.Strings(names)
+
+	filename := filepath.Join(dir, names[0])
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return "", "", err
+	}
+	plugins := []json.RawMessage{content}
+	if filepath.Ext(filename) == ".conflist" {
+		var list struct {
+			Plugins []json.RawMessage `json:"plugins"`
+		}
+		err = json.Unmarshal(content, &list)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid network configuration list %s: %w", filename, err)
+		}
+		plugins = list.Plugins
+	}
+
+	for _, raw := range plugins {
+		var bc BridgeConfig
+		err = json.Unmarshal(raw, &bc)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid network configuration %s: %w", filename, err)
+		}
+		if bc.Type != "bridge" {
+			continue
+		}
+		if len(bc.IPAM.Ranges) == 0 || len(bc.IPAM.Ranges[0]) == 0 {
+			return "", "", fmt.Errorf("bridge configuration in %s has no IPAM ranges", filename)
+		}
+		return filename, bc.IPAM.Ranges[0][0].Subnet, nil
+	}
+	return "", "", fmt.Errorf("no bridge plugin in %s", filename)
+}
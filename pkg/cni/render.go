@@ -0,0 +1,106 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+const (
+	// BridgeFilename is the filename for the bridge network configuration.
+	BridgeFilename = "10-bridge.conf"
+	// BridgeConfListFilename is the filename for the bridge network
+	// configuration when rendered as a list.
+	BridgeConfListFilename = "10-tailsk8s.conflist"
+	// LoopbackFilename is the filename for the loopback network configuration.
+	LoopbackFilename = "99-loopback.conf"
+)
+
+// File is a rendered CNI configuration file.
+type File struct {
+	Name    string
+	Content []byte
+}
+
+// NewBridgeConfig returns the bridge network configuration for a node subnet.
+func NewBridgeConfig(c Config) BridgeConfig {
+	return BridgeConfig{
+		CNIVersion: c.CNIVersion,
+		Name:       c.NetworkName,
+		Type:       "bridge",
+		Bridge:     c.Bridge,
+		IsGateway:  true,
+		IPMasq:     true,
+		IPAM: IPAMConfig{
+			Type:   "host-local",
+			Ranges: [][]Range{{{Subnet: c.Subnet}}},
+			Routes: []Route{{Dst: "0.0.0.0/0"}},
+		},
+	}
+}
+
+// NewLoopbackConfig returns the loopback network configuration.
+func NewLoopbackConfig(c Config) LoopbackConfig {
+	return LoopbackConfig{CNIVersion: c.CNIVersion, Name: "lo", Type: "loopback"}
+}
+
+// RenderFiles renders the CNI configuration files for a node, sorted by
+// filename. The subnet is expected to have already been validated.
+func RenderFiles(c Config) ([]File, error) {
+	bridge := NewBridgeConfig(c)
+	var bridgeFile File
+	if c.ConfList {
+		// In a list, `cniVersion` and `name` belong to the list.
+		bridge.CNIVersion = ""
+		bridge.Name = ""
+		cl := ConfList{CNIVersion: c.CNIVersion, Name: c.NetworkName, Plugins: []interface{}{bridge}}
+		content, err := marshal(cl)
+		if err != nil {
+			return nil, err
+		}
+		bridgeFile = File{Name: BridgeConfListFilename, Content: content}
+	} else {
+		content, err := marshal(bridge)
+		if err != nil {
+			return nil, err
+		}
+		bridgeFile = File{Name: BridgeFilename, Content: content}
+	}
+
+	content, err := marshal(NewLoopbackConfig(c))
+	if err != nil {
+		return nil, err
+	}
+	files := []File{bridgeFile, {Name: LoopbackFilename, Content: content}}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, nil
+}
+
+// ManagedFilenames are all files that may be written by `RenderFiles()`.
+// Any of these that are not part of the current render are considered stale.
+func ManagedFilenames() []string {
+	return []string{BridgeFilename, BridgeConfListFilename, LoopbackFilename}
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	asJSON, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	return append(asJSON, '\n'), nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+// NOTE: The types here cover only the fields of the CNI specification that
+//       are used by `tailsk8s`.
+//       See: https://github.com/containernetworking/cni/blob/v0.8.1/SPEC.md
+
+// BridgeConfig is the network configuration for the `bridge` plugin.
+//
+// See: https://www.cni.dev/plugins/current/main/bridge/
+type BridgeConfig struct {
+	CNIVersion string     `json:"cniVersion,omitempty"`
+	Name       string     `json:"name,omitempty"`
+	Type       string     `json:"type"`
+	Bridge     string     `json:"bridge"`
+	IsGateway  bool       `json:"isGateway"`
+	IPMasq     bool       `json:"ipMasq"`
+	IPAM       IPAMConfig `json:"ipam"`
+}
+
+// IPAMConfig is the configuration for the `host-local` IPAM plugin.
+//
+// See: https://www.cni.dev/plugins/current/ipam/host-local/
+type IPAMConfig struct {
+	Type   string    `json:"type"`
+	Ranges [][]Range `json:"ranges"`
+	Routes []Route   `json:"routes"`
+}
+
+// Range is an IP range for IPAM allocation.
+type Range struct {
+	Subnet string `json:"subnet"`
+}
+
+// Route is a route to add to the container namespace.
+type Route struct {
+	Dst string `json:"dst"`
+}
+
+// LoopbackConfig is the network configuration for the `loopback` plugin.
+type LoopbackConfig struct {
+	CNIVersion string `json:"cniVersion"`
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+}
+
+// ConfList is a network configuration list; `cniVersion` and `name` are set
+// once for the list rather than for each plugin.
+type ConfList struct {
+	CNIVersion string        `json:"cniVersion"`
+	Name       string        `json:"name"`
+	Plugins    []interface{} `json:"plugins"`
+}
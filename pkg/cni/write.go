@@ -0,0 +1,65 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cni
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"tailscale.com/atomicfile"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// WriteFiles atomically writes rendered files into `dir` (each file is
+// written to a temporary file and then renamed). Any managed files that are
+// not part of `files` (e.g. `10-bridge.conf` after switching to a `.conflist`)
+// are removed.
+func WriteFiles(ctx context.Context, dir string, files []File) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	current := map[string]bool{}
+	for _, f := range files {
+		current[f.Name] = true
+		filename := filepath.Join(dir, f.Name)
+		err = atomicfile.WriteFile(filename, f.Content, 0644)
+		if err != nil {
+			return err
+		}
+		cli.Printf(ctx, "Wrote CNI configuration: %s\n", filename)
+	}
+
+	for _, name := range ManagedFilenames() {
+		if current[name] {
+			continue
+		}
+		filename := filepath.Join(dir, name)
+		err = os.Remove(filename)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		cli.Printf(ctx, "Removed stale CNI configuration: %s\n", filename)
+	}
+
+	return nil
+}
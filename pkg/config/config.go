@@ -0,0 +1,291 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	// SystemFilename is the system-wide configuration file.
+	SystemFilename = "/etc/tailsk8s/config.yaml"
+	// EnvConfig is an environment variable that can be used to specify
+	// an explicit configuration file; if set, the system-wide and per-user
+	// files will not be read.
+	EnvConfig = "TAILSK8S_CONFIG"
+	// EnvPrefix is the prefix for all environment variables that can be
+	// used to specify a configuration value.
+	EnvPrefix = "TAILSK8S_"
+
+	// SourceDefault indicates a value is the default for a command.
+	SourceDefault = "default"
+	// SourceEnv indicates a value was set via an environment variable.
+	SourceEnv = "env"
+	// SourceFile indicates a value was set via a configuration file.
+	SourceFile = "file"
+	// SourceFlag indicates a value was set via a CLI flag.
+	SourceFlag = "flag"
+)
+
+// Key describes a configuration value that can be set via a CLI flag, an
+// environment variable or a configuration file.
+type Key struct {
+	// Name is used as the CLI flag name **and** the key in a configuration
+	// file.
+	Name string
+	// Secret indicates that the value should be redacted when displayed.
+	Secret bool
+	// Description is a short description for `config view`.
+	Description string
+}
+
+// Env is the name of the environment variable for a key, e.g. `api-key`
+// becomes `TAILSK8S_API_KEY`.
+func (k Key) Env() string {
+	normalized := strings.ToUpper(strings.ReplaceAll(k.Name, "-", "_"))
+	return EnvPrefix + normalized
+}
+
+// Keys is the set of all known configuration keys.
+var Keys = []Key{
+	{Name: "api-key", Secret: true, Description: "The Tailscale API key (or a \"file:\" reference)"},
+	{Name: "bootstrap-dir", Description: "The directory containing bootstrap files (e.g. join-token.txt)"},
+	{Name: "cidr", Description: "The (IPv4) CIDR to advertise or withdraw"},
+	{Name: "cluster-name", Description: "The human readable name of the cluster"},
+	{Name: "debug", Description: "Enable extra print debugging"},
+	{Name: "hostname", Description: "The hostname of the device to act on"},
+	{Name: "kubeconfig", Description: "The kubeconfig file used for Kubernetes API calls"},
+	{Name: "oauth-client-id", Description: "The Tailscale OAuth client ID"},
+	{Name: "oauth-client-secret", Secret: true, Description: "The Tailscale OAuth client secret (or a secret reference)"},
+	{Name: "oauth-scopes", Description: "The OAuth scopes to request (comma separated)"},
+	{Name: "oauth-token-url", Description: "The OAuth token endpoint"},
+	{Name: "pod-subnet", Description: "The cluster pod subnet that node subnets are allocated from"},
+	{Name: "service-subnet", Description: "The cluster service subnet"},
+	{Name: "socket", Description: "The path to the 'tailscaled' socket"},
+	{Name: "tailnet", Description: "The Tailnet where the device exists"},
+}
+
+// LookupKey finds a known configuration key by name.
+func LookupKey(name string) (Key, bool) {
+	for _, k := range Keys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Setting is a resolved configuration value along with where the value
+// came from.
+type Setting struct {
+	Key    Key
+	Value  string
+	Source string
+	// Filename is set when `Source` is `SourceFile`.
+	Filename string
+}
+
+// SourceDescription describes the source of a setting, e.g. `env` or
+// `file:/etc/tailsk8s/config.yaml`.
+func (s Setting) SourceDescription() string {
+	if s.Source == SourceFile {
+		return fmt.Sprintf("%s:%s", SourceFile, s.Filename)
+	}
+	if s.Source == SourceEnv {
+		return fmt.Sprintf("%s:%s", SourceEnv, s.Key.Env())
+	}
+	return s.Source
+}
+
+// Config is the effective configuration from configuration files and
+// environment variables. CLI flags and defaults are layered on top via
+// `Apply()`.
+type Config struct {
+	// Filenames are the configuration files that were read, in the order
+	// they were read (later files take precedence).
+	Filenames []string
+	settings  map[string]Setting
+}
+
+// Load reads configuration files and environment variables. The files
+// are read in order (later files take precedence):
+//   - `/etc/tailsk8s/config.yaml`
+//   - `${XDG_CONFIG_HOME}/tailsk8s/config.yaml` (typically
+//     `~/.config/tailsk8s/config.yaml`)
+//
+// If `TAILSK8S_CONFIG` is set, **only** that file will be read and it is
+// an error for the file to be missing. Environment variables of the form
+// `TAILSK8S_*` take precedence over all files.
+func Load() (*Config, error) {
+	c := &Config{settings: map[string]Setting{}}
+
+	filenames, required := configFilenames()
+	for _, filename := range filenames {
+		err := c.loadFile(filename, required)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range Keys {
+		value, ok := os.LookupEnv(k.Env())
+		if !ok {
+			continue
+		}
+		c.settings[k.Name] = Setting{Key: k, Value: value, Source: SourceEnv}
+	}
+
+	return c, nil
+}
+
+func configFilenames() ([]string, bool) {
+	explicit := os.Getenv(EnvConfig)
+	if explicit != "" {
+		return []string{explicit}, true
+	}
+
+	filenames := []string{SystemFilename}
+	userDir, err := os.UserConfigDir()
+	// Ignore error: no per-user configuration directory (e.g. `$HOME` is
+	// unset) just means there is no per-user configuration file.
+	if err == nil {
+		filenames = append(filenames, filepath.Join(userDir, "tailsk8s", "config.yaml"))
+	}
+	return filenames, false
+}
+
+func (c *Config) loadFile(filename string, required bool) error {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	values, err := ParseYAML(data)
+	if err != nil {
+		return fmt.Errorf("invalid configuration file %s: %w", filename, err)
+	}
+	for name, value := range values {
+		k, ok := LookupKey(name)
+		if !ok {
+			return fmt.Errorf("invalid configuration file %s: unknown key %q", filename, name)
+		}
+		c.settings[name] = Setting{Key: k, Value: value, Source: SourceFile, Filename: filename}
+	}
+
+	c.Filenames = append(c.Filenames, filename)
+	return nil
+}
+
+// Lookup returns the setting for a configuration key, if it has been set
+// via a configuration file or environment variable.
+func (c *Config) Lookup(name string) (Setting, bool) {
+	s, ok := c.settings[name]
+	return s, ok
+}
+
+// Apply uses configuration values to populate every flag in `fs` that has
+// a matching configuration key and was **not** explicitly set on the command
+// line. This establishes the precedence: flag > env > file > default. This
+// is intended to be called after flags have been parsed (e.g. at the start of
+// a `RunE` function).
+func (c *Config) Apply(fs *pflag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+		s, ok := c.settings[f.Name]
+		if !ok {
+			return
+		}
+		// NOTE: This uses `f.Value.Set()` rather than `fs.Set()` so the flag
+		//       is not marked as changed.
+		setErr := f.Value.Set(s.Value)
+		if setErr != nil {
+			err = fmt.Errorf("invalid value for %q from %s: %w", f.Name, s.SourceDescription(), setErr)
+		}
+	})
+	return err
+}
+
+// Require ensures that each of the named flags has a value, whether from
+// a flag, an environment variable or a configuration file. It is intended to
+// replace `cobra.MarkFlagRequired()` (which only considers CLI flags) and
+// must be called **after** `Apply()`.
+func Require(fs *pflag.FlagSet, names ...string) error {
+	missing := []string{}
+	for _, name := range names {
+		f := fs.Lookup(name)
+		if f == nil {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		if f.Value.String() != "" {
+			continue
+		}
+		missing = append(missing, fmt.Sprintf("%q", name))
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf(
+			"required flag(s) %s not set (they may also be set via %s* environment variables or a configuration file)",
+			strings.Join(missing, ", "), EnvPrefix,
+		)
+	}
+	return nil
+}
+
+// Effective returns the effective setting for every known configuration key.
+// If `fs` is not `nil`, flags that were explicitly set on the command line
+// and flag defaults are also taken into account. This must be called
+// **before** `Apply()` to correctly distinguish defaults from configured
+// values.
+func (c *Config) Effective(fs *pflag.FlagSet) []Setting {
+	settings := []Setting{}
+	for _, k := range Keys {
+		var f *pflag.Flag
+		if fs != nil {
+			f = fs.Lookup(k.Name)
+		}
+		if f != nil && f.Changed {
+			settings = append(settings, Setting{Key: k, Value: f.Value.String(), Source: SourceFlag})
+			continue
+		}
+		s, ok := c.settings[k.Name]
+		if ok {
+			settings = append(settings, s)
+			continue
+		}
+		defValue := ""
+		if f != nil {
+			defValue = f.DefValue
+		}
+		settings = append(settings, Setting{Key: k, Value: defValue, Source: SourceDefault})
+	}
+
+	sort.Slice(settings, func(i, j int) bool {
+		return settings[i].Key.Name < settings[j].Key.Name
+	})
+	return settings
+}
@@ -0,0 +1,27 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config provides layered configuration for `tailsk8s` commands.
+//
+// Values can be provided (in order of precedence) via CLI flags, `TAILSK8S_*`
+// environment variables, a per-user configuration file, a system-wide
+// configuration file (`/etc/tailsk8s/config.yaml`) or command defaults. For
+// example, a configuration file may contain
+//
+//	api-key: file:/var/data/tailsk8s-bootstrap/tailscale-api-key
+//	tailnet: example.com
+//
+// and the same values could be set via `TAILSK8S_API_KEY` and
+// `TAILSK8S_TAILNET`.
+package config
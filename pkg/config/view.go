@@ -0,0 +1,67 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/secret"
+)
+
+// Redact hides the value of a secret setting. References to a secret (e.g.
+// a `file:` path) are not redacted since they are not secret themselves.
+func Redact(s Setting) string {
+	if !s.Key.Secret || s.Value == "" {
+		return s.Value
+	}
+	if secret.Describe(s.Value) != "" {
+		return s.Value
+	}
+	return "...redacted..."
+}
+
+// View prints the effective settings, one per line, with secrets redacted.
+func View(ctx context.Context, c *Config, settings []Setting) error {
+	if len(c.Filenames) == 0 {
+		cli.Println(ctx, "Configuration files: (none)")
+	} else {
+		cli.Println(ctx, "Configuration files:")
+		for _, filename := range c.Filenames {
+			cli.Printf(ctx, "- %s\n", filename)
+		}
+	}
+	cli.Println(ctx, "")
+
+	w := tabwriter.NewWriter(cli.GetStdout(ctx), 0, 4, 2, ' ', 0)
+	_, err := w.Write([]byte("KEY\tVALUE\tSOURCE\tENV\n"))
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		value := Redact(s)
+		if value == "" {
+			value = `""`
+		}
+		line := strings.Join([]string{s.Key.Name, value, s.SourceDescription(), s.Key.Env()}, "\t")
+		_, err = w.Write([]byte(line + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
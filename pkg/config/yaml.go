@@ -0,0 +1,118 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseYAML parses a (very) small subset of YAML: a single block mapping with
+// scalar values, comments and single / double quoted strings. Nested mappings
+// and sequences are rejected. This is intended for configuration files that
+// are a flat list of `key: value` pairs; it avoids taking on a full YAML
+// dependency.
+func ParseYAML(data []byte) (map[string]string, error) {
+	values := map[string]string{}
+
+	lines := strings.Split(string(data), "\n")
+	for i, raw := range lines {
+		lineNumber := i + 1
+		line := strings.TrimRight(stripComment(raw), " \t\r")
+		if strings.TrimSpace(line) == "" || line == "---" {
+			continue
+		}
+		if strings.Contains(line, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", lineNumber)
+		}
+
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.HasPrefix(trimmed, "- ") || trimmed == "-" {
+			return nil, fmt.Errorf("line %d: sequences are not supported", lineNumber)
+		}
+
+		key, value, ok := splitKeyValue(trimmed)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\", got %q", lineNumber, trimmed)
+		}
+
+		if trimmed != line {
+			return nil, fmt.Errorf("line %d: nested mappings are not supported, %q must be a top-level key", lineNumber, key)
+		}
+		if value == "" {
+			return nil, fmt.Errorf("line %d: nested mappings are not supported, %q must have a scalar value", lineNumber, key)
+		}
+
+		scalar, err := parseScalar(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineNumber, key)
+		}
+		values[key] = scalar
+	}
+
+	return values, nil
+}
+
+// stripComment removes a trailing `#` comment from a line, taking care not
+// to remove a `#` inside a quoted string.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func splitKeyValue(s string) (string, string, bool) {
+	index := strings.Index(s, ":")
+	if index <= 0 {
+		return "", "", false
+	}
+	key := strings.TrimSpace(s[:index])
+	rest := s[index+1:]
+	if rest != "" && rest[0] != ' ' {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(rest), true
+}
+
+func parseScalar(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("unterminated string %s", value)
+		}
+		inner := value[1 : len(value)-1]
+		return strings.ReplaceAll(inner, "''", "'"), nil
+	case value == "~" || value == "null":
+		return "", nil
+	}
+	return value, nil
+}
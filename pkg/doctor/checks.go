@@ -0,0 +1,168 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"inet.af/netaddr"
+	"tailscale.com/ipn"
+	"tailscale.com/ipn/ipnstate"
+
+	"github.com/dhermes/tailsk8s/pkg/cni"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/advertise"
+)
+
+const (
+	hintSysctl = "enable IP forwarding in /etc/sysctl.d/tailscale.conf and run `sudo sysctl --system` (see https://tailscale.com/kb/1104/enable-ip-forwarding/)"
+)
+
+// CheckTailscaled checks that `tailscaled` is reachable and logged in.
+func CheckTailscaled(status *ipnstate.Status, err error) Result {
+	name := "tailscaled"
+	if err != nil {
+		return fail(name, "start tailscaled, e.g. `sudo systemctl enable --now tailscaled`", "cannot reach tailscaled: %v", err)
+	}
+	if status.BackendState != ipn.Running.String() {
+		return fail(name, "log in with `sudo tailscale up --accept-routes`", "tailscaled is in state %s", status.BackendState)
+	}
+	if status.Self == nil {
+		return pass(name, "running")
+	}
+	return pass(name, "running as %s", status.Self.HostName)
+}
+
+// CheckRouteAll checks that routes advertised by other nodes are accepted;
+// without this, pods can't reach pods on other nodes.
+func CheckRouteAll(prefs *ipn.Prefs, err error) Result {
+	name := "accept-routes"
+	if err != nil {
+		return skip(name, "cannot read tailscaled preferences: %v", err)
+	}
+	if !prefs.RouteAll {
+		return fail(name, "run `sudo tailscale up --accept-routes` (or `tailscale-advertise`, which also sets it)", "routes advertised by other nodes are not accepted (RouteAll is off)")
+	}
+	return pass(name, "routes advertised by other nodes are accepted")
+}
+
+// CheckAdvertised checks that the node subnet is advertised locally.
+func CheckAdvertised(prefs *ipn.Prefs, err error, cidr netaddr.IPPrefix) Result {
+	name := "advertised-locally"
+	if err != nil {
+		return skip(name, "cannot read tailscaled preferences: %v", err)
+	}
+	if cidr.IsZero() {
+		return skip(name, "the node subnet is unknown")
+	}
+	if !advertise.IPPrefixesContain(prefs.AdvertiseRoutes, cidr) {
+		return fail(name, fmt.Sprintf("run `sudo tailscale-advertise --cidr %s`", cidr), "subnet %s is not advertised (advertised routes: %v)", cidr, prefs.AdvertiseRoutes)
+	}
+	return pass(name, "subnet %s is advertised", cidr)
+}
+
+// CheckEnabled checks that the node subnet is enabled in the Tailscale cloud
+// API (i.e. other devices will route to it).
+func CheckEnabled(rr *cloud.RoutesResponse, err error, cidr netaddr.IPPrefix) Result {
+	name := "enabled-in-tailnet"
+	if err != nil {
+		return skip(name, "%v", err)
+	}
+	if cidr.IsZero() {
+		return skip(name, "the node subnet is unknown")
+	}
+	if !advertise.RoutesContain(rr.EnabledRoutes, cidr) {
+		return fail(name, "enable the route in the admin console, or run `tailscale-advertise` or `tailsk8s approve-routes`", "subnet %s is not enabled (enabled routes: %v)", cidr, rr.EnabledRoutes)
+	}
+	return pass(name, "subnet %s is enabled", cidr)
+}
+
+// CheckAuthorized checks that the device is authorized in the Tailnet.
+func CheckAuthorized(device *cloud.Device, err error) Result {
+	name := "device-authorized"
+	if err != nil {
+		return skip(name, "%v", err)
+	}
+	if !device.Authorized {
+		return fail(name, fmt.Sprintf("run `tailscale-authorize --hostname %s`", device.Hostname), "device %s is not authorized", device.ID)
+	}
+	return pass(name, "device %s is authorized", device.ID)
+}
+
+// CheckKeyExpiry checks that the device key is not expired (or close to
+// expiring); when it expires, the node drops off the Tailnet.
+func CheckKeyExpiry(device *cloud.Device, err error, now time.Time, window time.Duration) Result {
+	name := "key-expiry"
+	hint := "disable key expiry for the device in the admin console or re-authenticate with `sudo tailscale up --force-reauth`"
+	if err != nil {
+		return skip(name, "%v", err)
+	}
+	if device.KeyExpiryDisabled {
+		return pass(name, "key expiry is disabled")
+	}
+	if device.Expires == "" {
+		return skip(name, "the key expiry for device %s is unknown", device.ID)
+	}
+	expires, err := time.Parse(time.RFC3339, device.Expires)
+	if err != nil {
+		return skip(name, "invalid key expiry %q", device.Expires)
+	}
+
+	remaining := expires.Sub(now)
+	if remaining <= 0 {
+		return fail(name, hint, "device key expired at %s", expires.Format(time.RFC3339))
+	}
+	if remaining < window {
+		return warn(name, hint, "device key expires at %s (in %s)", expires.Format(time.RFC3339), remaining.Round(time.Minute))
+	}
+	return pass(name, "device key expires at %s", expires.Format(time.RFC3339))
+}
+
+// CheckSysctl checks that a sysctl (e.g. `net/ipv4/ip_forward`) is `1`.
+func CheckSysctl(dir, name, key string) Result {
+	filename := filepath.Join(dir, key)
+	display := strings.ReplaceAll(key, "/", ".")
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return fail(name, hintSysctl, "cannot read %s: %v", display, err)
+	}
+	value := strings.TrimSpace(string(content))
+	if value != "1" {
+		return fail(name, hintSysctl, "%s = %s", display, value)
+	}
+	return pass(name, "%s = 1", display)
+}
+
+// CheckCNIBridge checks that the CNI bridge allocates pod IPs from the node
+// subnet.
+func CheckCNIBridge(dir string, cidr netaddr.IPPrefix) Result {
+	name := "cni-bridge-subnet"
+	if cidr.IsZero() {
+		return skip(name, "the node subnet is unknown")
+	}
+	hint := fmt.Sprintf("run `sudo tailsk8s cni render --subnet %s`", cidr)
+	filename, subnet, err := cni.BridgeSubnet(dir)
+	if err != nil {
+		return fail(name, hint, "%v", err)
+	}
+	if subnet != cidr.String() {
+		return fail(name, hint, "bridge subnet in %s is %s, expected %s", filename, subnet, cidr)
+	}
+	return pass(name, "bridge subnet in %s is %s", filename, subnet)
+}
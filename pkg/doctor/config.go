@@ -0,0 +1,84 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor
+
+import (
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cni"
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	// DefaultSysctlDir is where sysctl values are read from.
+	DefaultSysctlDir = "/proc/sys"
+	// DefaultKeyExpiryWarning is how close to key expiry a device has to be
+	// before a warning is reported.
+	DefaultKeyExpiryWarning = 7 * 24 * time.Hour
+)
+
+// Config provides the core set of (CLI) inputs needed to check a node.
+type Config struct {
+	APIConfig cloud.Config
+	Kubectl   kubernetes.Kubectl
+	// Node is the node (and Tailscale device hostname) to check; defaults to
+	// the local hostname.
+	Node string
+	// CIDR is the subnet for the node; if unset it is read from the
+	// `tailsk8s.io/advertise-subnet` node label.
+	CIDR   string
+	CNIDir string
+	// SysctlDir is `/proc/sys` (configurable for testing).
+	SysctlDir        string
+	KeyExpiryWarning time.Duration
+	// SkipCloud skips the checks that use the Tailscale cloud API.
+	SkipCloud bool
+	// JSON indicates the report should be printed as JSON.
+	JSON bool
+	// Now is used to check key expiry; defaults to `time.Now`.
+	Now func() time.Time
+}
+
+// NewConfig returns a new `Config` with all relevant defaults provided and
+// options for overriding.
+func NewConfig(opts ...Option) (Config, error) {
+	ac, err := cloud.NewConfig()
+	if err != nil {
+		return Config{}, err
+	}
+
+	c := Config{
+		APIConfig:        ac,
+		CNIDir:           cni.DefaultDir,
+		SysctlDir:        DefaultSysctlDir,
+		KeyExpiryWarning: DefaultKeyExpiryWarning,
+		Now:              time.Now,
+	}
+	for _, opt := range opts {
+		err := opt(&c)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+func (c Config) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
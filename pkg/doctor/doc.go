@@ -0,0 +1,22 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package doctor checks the networking prerequisites for a `tailsk8s` node,
+// e.g. that `tailscaled` accepts routes, that the node's pod subnet is
+// advertised and enabled and that IP forwarding is on. Each check reports
+// pass, warn, fail or skip along with a hint for fixing it.
+//
+// This is provided in a way to optimize the testable surface area (even for
+// untested parts of the code) without having any usage of `os.Exit()`.
+package doctor
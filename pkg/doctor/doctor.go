@@ -0,0 +1,152 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"inet.af/netaddr"
+	"tailscale.com/ipn"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/cni"
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/remix"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+// Run checks the node and prints a report (as text or JSON). An error is
+// returned if any check fails.
+func Run(ctx context.Context, c Config) error {
+	diagnoseCtx := ctx
+	if c.JSON {
+		// Keep STDOUT valid JSON by sending any output from the checks
+		// (e.g. from resolving the Tailnet) to STDERR.
+		diagnoseCtx = cli.WithStdout(ctx, cli.GetStderr(ctx))
+	}
+	r, err := Diagnose(diagnoseCtx, c)
+	if err != nil {
+		return err
+	}
+	if c.JSON {
+		err = PrintJSON(ctx, *r)
+		if err != nil {
+			return err
+		}
+	} else {
+		PrintText(ctx, *r)
+	}
+
+	if r.Summary.Fail > 0 {
+		return fmt.Errorf("%d check(s) failed", r.Summary.Fail)
+	}
+	return nil
+}
+
+// Diagnose runs every check. Checks whose inputs are not available (e.g. the
+// cloud API when no API key is configured) are skipped.
+func Diagnose(ctx context.Context, c Config) (*Report, error) {
+	node := c.Node
+	if node == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		node = hostname
+	}
+	r := &Report{Node: node, Results: []Result{}}
+
+	status, err := local.StatusWithoutPeers(ctx)
+	r.add(CheckTailscaled(status, err))
+	var prefs *ipn.Prefs
+	prefsErr := err
+	if err == nil {
+		prefs, prefsErr = local.GetPrefs(ctx)
+	}
+	r.add(CheckRouteAll(prefs, prefsErr))
+
+	cidr, result := resolveCIDR(ctx, c, node)
+	r.add(result)
+	if !cidr.IsZero() {
+		r.CIDR = cidr.String()
+	}
+	r.add(CheckAdvertised(prefs, prefsErr, cidr))
+
+	device, rr, cloudErr := cloudState(ctx, c, node)
+	r.add(CheckEnabled(rr, cloudErr, cidr))
+	r.add(CheckAuthorized(device, cloudErr))
+	r.add(CheckKeyExpiry(device, cloudErr, c.now(), c.KeyExpiryWarning))
+
+	r.add(CheckSysctl(c.SysctlDir, "ipv4-forwarding", "net/ipv4/ip_forward"))
+	r.add(CheckSysctl(c.SysctlDir, "ipv6-forwarding", "net/ipv6/conf/all/forwarding"))
+	r.add(CheckCNIBridge(c.CNIDir, cidr))
+	return r, nil
+}
+
+// resolveCIDR determines the node subnet, either from the config or from the
+// `tailsk8s.io/advertise-subnet` node label.
+func resolveCIDR(ctx context.Context, c Config, node string) (netaddr.IPPrefix, Result) {
+	name := "node-subnet"
+	if c.CIDR != "" {
+		cidr, err := cni.ParseSubnet(c.CIDR)
+		if err != nil {
+			return netaddr.IPPrefix{}, fail(name, "pass a valid --cidr", "invalid subnet %q: %v", c.CIDR, err)
+		}
+		return cidr, pass(name, "subnet %s (provided)", cidr)
+	}
+
+	n, err := c.Kubectl.FindNode(ctx, node)
+	if err != nil {
+		return netaddr.IPPrefix{}, skip(name, "cannot read node %q (pass --cidr to skip this lookup): %v", node, err)
+	}
+	if n == nil {
+		return netaddr.IPPrefix{}, fail(name, "join the node to the cluster or pass --cidr", "node %q does not exist", node)
+	}
+	value := n.AdvertiseSubnet()
+	hint := fmt.Sprintf("label the node, e.g. `kubectl label node %s %s=10.100.2.0__24`", node, kubernetes.AdvertiseSubnetLabel)
+	if value == "" {
+		return netaddr.IPPrefix{}, fail(name, hint, "node %q has no %s label", node, kubernetes.AdvertiseSubnetLabel)
+	}
+	cidr, err := cni.ParseSubnet(value)
+	if err != nil {
+		return netaddr.IPPrefix{}, fail(name, hint, "invalid %s label %q: %v", kubernetes.AdvertiseSubnetLabel, value, err)
+	}
+	return cidr, pass(name, "subnet %s (from the %s label)", cidr, kubernetes.AdvertiseSubnetLabel)
+}
+
+// cloudState looks up the device and its routes via the Tailscale cloud API.
+func cloudState(ctx context.Context, c Config, node string) (*cloud.Device, *cloud.RoutesResponse, error) {
+	if c.SkipCloud {
+		return nil, nil, errors.New("cloud API checks are disabled")
+	}
+	err := c.APIConfig.Resolve(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cloud API is not configured: %w", err)
+	}
+
+	device, err := remix.GetDeviceByHostname(ctx, c.APIConfig, remix.GetDeviceByHostnameRequest{Hostname: node})
+	if err != nil {
+		return nil, nil, err
+	}
+	rr, err := cloud.GetRoutes(ctx, c.APIConfig, cloud.GetRoutesRequest{DeviceID: device.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+	return device, rr, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+const (
+	// StatusPass indicates a check succeeded.
+	StatusPass = "pass"
+	// StatusWarn indicates a check found something that is likely to cause
+	// problems soon.
+	StatusWarn = "warn"
+	// StatusFail indicates a check failed.
+	StatusFail = "fail"
+	// StatusSkip indicates a check could not be run (e.g. because an input
+	// it depends on is not available).
+	StatusSkip = "skip"
+)
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	// Hint describes how to fix a failure or warning.
+	Hint string `json:"hint,omitempty"`
+}
+
+// Summary counts results by status.
+type Summary struct {
+	Pass int `json:"pass"`
+	Warn int `json:"warn"`
+	Fail int `json:"fail"`
+	Skip int `json:"skip"`
+}
+
+// Report is the full set of results for a node.
+type Report struct {
+	Node    string   `json:"node"`
+	CIDR    string   `json:"cidr,omitempty"`
+	Results []Result `json:"results"`
+	Summary Summary  `json:"summary"`
+}
+
+func (r *Report) add(result Result) {
+	r.Results = append(r.Results, result)
+	switch result.Status {
+	case StatusPass:
+		r.Summary.Pass++
+	case StatusWarn:
+		r.Summary.Warn++
+	case StatusFail:
+		r.Summary.Fail++
+	case StatusSkip:
+		r.Summary.Skip++
+	}
+}
+
+func pass(name, format string, a ...interface{}) Result {
+	return Result{Name: name, Status: StatusPass, Message: fmt.Sprintf(format, a...)}
+}
+
+func skip(name, format string, a ...interface{}) Result {
+	return Result{Name: name, Status: StatusSkip, Message: fmt.Sprintf(format, a...)}
+}
+
+func fail(name, hint, format string, a ...interface{}) Result {
+	return Result{Name: name, Status: StatusFail, Message: fmt.Sprintf(format, a...), Hint: hint}
+}
+
+func warn(name, hint, format string, a ...interface{}) Result {
+	return Result{Name: name, Status: StatusWarn, Message: fmt.Sprintf(format, a...), Hint: hint}
+}
+
+// PrintText prints one line per result (with hints indented below).
+func PrintText(ctx context.Context, r Report) {
+	cli.Printf(ctx, "Node: %s\n", r.Node)
+	if r.CIDR != "" {
+		cli.Printf(ctx, "Subnet: %s\n", r.CIDR)
+	}
+	for _, result := range r.Results {
+		cli.Printf(ctx, "[%s] %s: %s\n", strings.ToUpper(result.Status), result.Name, result.Message)
+		if result.Hint != "" {
+			cli.Printf(ctx, "       hint: %s\n", result.Hint)
+		}
+	}
+	cli.Printf(
+		ctx, "%d passed, %d warning(s), %d failed, %d skipped\n",
+		r.Summary.Pass, r.Summary.Warn, r.Summary.Fail, r.Summary.Skip,
+	)
+}
+
+// PrintJSON prints the report as JSON.
+func PrintJSON(ctx context.Context, r Report) error {
+	asJSON, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "%s\n", asJSON)
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package haproxy
+
+import (
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	// DefaultOutput is the HAProxy configuration file.
+	DefaultOutput = "/etc/haproxy/haproxy.cfg"
+	// DefaultPort is the port of the Kubernetes API server, used both for the
+	// load balancer and the backends.
+	DefaultPort = 6443
+	// DefaultInterval is the time between checks in `--watch` mode.
+	DefaultInterval = 30 * time.Second
+	// DefaultReloadCommand reloads HAProxy after the configuration changes.
+	DefaultReloadCommand = "systemctl reload haproxy"
+)
+
+// Config provides the core set of (CLI) inputs needed to generate HAProxy
+// configuration.
+type Config struct {
+	APIConfig cloud.Config
+	// Hostnames are the (exact) hostnames of control plane devices.
+	Hostnames []string
+	// Tag selects all control plane devices with a given tag.
+	Tag string
+	// BindIP is the IP the load balancer listens on; if unset, the Tailscale
+	// IPv4 address of the local device is used.
+	BindIP string
+	Port   int
+	Output string
+	Stdout bool
+	// Reload indicates HAProxy should be reloaded after the configuration is
+	// written; this is always done in `Watch` mode.
+	Reload        bool
+	ReloadCommand string
+	Watch         bool
+	Interval      time.Duration
+	// Run executes the reload command; defaults to `cli.ExecRun`.
+	Run cli.RunFunc
+}
+
+// NewConfig returns a new `Config` with all relevant defaults provided and
+// options for overriding.
+func NewConfig(opts ...Option) (Config, error) {
+	ac, err := cloud.NewConfig()
+	if err != nil {
+		return Config{}, err
+	}
+
+	c := Config{
+		APIConfig:     ac,
+		Port:          DefaultPort,
+		Output:        DefaultOutput,
+		ReloadCommand: DefaultReloadCommand,
+		Interval:      DefaultInterval,
+		Run:           cli.ExecRun,
+	}
+	for _, opt := range opts {
+		err := opt(&c)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,167 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package haproxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"tailscale.com/atomicfile"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/remix"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+// Generate resolves the control plane devices and writes `haproxy.cfg` (or
+// prints it to STDOUT). In `Watch` mode, this repeats until `ctx` is
+// cancelled, rewriting the configuration and reloading HAProxy whenever the
+// set of control plane devices changes. If a reload fails, it is retried on
+// the next check even if the configuration has not changed.
+func Generate(ctx context.Context, c Config) error {
+	if c.Watch && c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive, got %s", c.Interval)
+	}
+	err := c.APIConfig.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+	if c.BindIP == "" {
+		ip, err := local.SelfIPv4(ctx)
+		if err != nil {
+			return err
+		}
+		c.BindIP = ip.String()
+	}
+
+	if !c.Watch {
+		_, err = generateOnce(ctx, c, c.Reload, false)
+		return err
+	}
+
+	cli.Printf(ctx, "Watching control plane devices every %s\n", c.Interval)
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	reloadPending := false
+	for {
+		reloadPending, err = generateOnce(ctx, c, true, reloadPending)
+		if err != nil {
+			cli.Printf(ctx, "Failed to update HAProxy configuration: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// ResolveBackends resolves the control plane devices to their Tailscale IPv4
+// addresses.
+func ResolveBackends(ctx context.Context, ac cloud.Config, hostnames []string, tag string) ([]Backend, error) {
+	req := remix.GetDevicesBySelectorRequest{Hostnames: hostnames, Tag: tag}
+	devices, err := remix.GetDevicesBySelector(ctx, ac, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return nil, errors.New("no control plane devices matched")
+	}
+
+	backends := []Backend{}
+	for _, device := range devices {
+		ip, err := remix.DeviceIPv4(device)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, Backend{Name: device.Hostname, IP: ip.String()})
+	}
+	return backends, nil
+}
+
+// generateOnce renders the configuration and writes it (and optionally reloads
+// HAProxy) if it has changed. If `reloadPending` is set (i.e. a previous
+// reload failed), HAProxy is reloaded even if the configuration is unchanged.
+// Returns whether a reload is still pending.
+func generateOnce(ctx context.Context, c Config, reload, reloadPending bool) (bool, error) {
+	backends, err := ResolveBackends(ctx, c.APIConfig, c.Hostnames, c.Tag)
+	if err != nil {
+		return reloadPending, err
+	}
+	rendered, err := RenderConfig(TemplateData{BindIP: c.BindIP, Port: c.Port, Backends: backends})
+	if err != nil {
+		return reloadPending, err
+	}
+
+	if c.Stdout {
+		cli.Printf(ctx, "%s", rendered)
+		return false, nil
+	}
+
+	existing, err := os.ReadFile(c.Output)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return reloadPending, err
+	}
+	if bytes.Equal(existing, rendered) {
+		cli.DebugPrintf(ctx, "HAProxy configuration %s is up to date\n", c.Output)
+		if !reloadPending {
+			return false, nil
+		}
+		cli.Println(ctx, "Retrying HAProxy reload after a previous failure")
+	} else {
+		err = atomicfile.WriteFile(c.Output, rendered, 0644)
+		if err != nil {
+			return reloadPending, err
+		}
+		cli.Printf(ctx, "Wrote HAProxy configuration with %d backend(s): %s\n", len(backends), c.Output)
+		for _, b := range backends {
+			cli.Printf(ctx, "- %s (%s)\n", b.Name, b.IP)
+		}
+	}
+
+	if !reload {
+		return false, nil
+	}
+	err = reloadHAProxy(ctx, c)
+	if err != nil {
+		return true, err
+	}
+	return false, nil
+}
+
+func reloadHAProxy(ctx context.Context, c Config) error {
+	parts := strings.Fields(c.ReloadCommand)
+	if len(parts) == 0 {
+		return errors.New("reload command is empty")
+	}
+	run := c.Run
+	if run == nil {
+		run = cli.ExecRun
+	}
+
+	_, err := run(ctx, nil, parts[0], parts[1:]...)
+	if err != nil {
+		return fmt.Errorf("failed to reload HAProxy: %w", err)
+	}
+	cli.Printf(ctx, "Reloaded HAProxy via: %s\n", c.ReloadCommand)
+	return nil
+}
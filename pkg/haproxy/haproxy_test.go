@@ -0,0 +1,94 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package haproxy_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/haproxy"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/cloudtest"
+)
+
+func TestGenerateInvalidInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		c, err := haproxy.NewConfig()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		c.Watch = true
+		c.Interval = interval
+		err = haproxy.Generate(context.Background(), c)
+		if err == nil || !strings.Contains(err.Error(), "interval must be positive") {
+			t.Fatalf("expected invalid interval error for %s, got %v", interval, err)
+		}
+	}
+}
+
+func TestGenerateRetriesFailedReload(t *testing.T) {
+	server := cloudtest.NewServer()
+	defer server.Close()
+	server.AddDevice(cloudtest.Device{
+		Device: cloud.Device{ID: "dev-a", Hostname: "cp-a", Addresses: []string{"100.64.0.1"}},
+	})
+
+	ctx, cancel := context.WithCancel(cli.WithStdout(context.Background(), io.Discard))
+	defer cancel()
+
+	// The first reload fails; the configuration does not change, so the
+	// second reload only happens if the failure is remembered.
+	var mu sync.Mutex
+	reloads := 0
+	run := func(_ context.Context, _ []byte, _ string, _ ...string) ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reloads++
+		if reloads == 1 {
+			return nil, errors.New("haproxy is not running")
+		}
+		cancel()
+		return nil, nil
+	}
+
+	c, err := haproxy.NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.APIConfig = server.Config()
+	c.Hostnames = []string{"cp-a"}
+	c.BindIP = "100.64.0.10"
+	c.Output = filepath.Join(t.TempDir(), "haproxy.cfg")
+	c.Watch = true
+	c.Interval = 10 * time.Millisecond
+	c.Run = run
+
+	err = haproxy.Generate(ctx, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if reloads != 2 {
+		t.Fatalf("expected 2 reloads, got %d", reloads)
+	}
+}
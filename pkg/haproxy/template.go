@@ -0,0 +1,101 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package haproxy
+
+import (
+	"bytes"
+	"text/template"
+)
+
+// configTemplate is the full `haproxy.cfg`; see
+// https://github.com/kubernetes/kubeadm/blob/e55c2a2b8e0b4e3079fd6a3586baf6472700428b/docs/ha-considerations.md#haproxy-configuration
+const configTemplate = `#---------------------------------------------------------------------
+# Global settings
+#---------------------------------------------------------------------
+global
+     log /dev/log local0
+     log /dev/log local1 notice
+     daemon
+     user haproxy
+     group haproxy
+
+#---------------------------------------------------------------------
+# common defaults that all the 'listen' and 'backend' sections will
+# use if not designated in their block
+#---------------------------------------------------------------------
+defaults
+     mode http
+     log global
+     option httplog
+     option dontlognull
+     option http-server-close
+     option forwardfor except 127.0.0.0/8
+     option redispatch
+     retries 1
+     timeout http-request    10s
+     timeout queue           20s
+     timeout connect         5s
+     timeout client          20s
+     timeout server          20s
+     timeout http-keep-alive 10s
+     timeout check           10s
+
+#---------------------------------------------------------------------
+# apiserver frontend which proxys to the control plane nodes
+#---------------------------------------------------------------------
+frontend apiserver
+     bind {{ .BindIP }}:{{ .Port }}
+     mode tcp
+     option tcplog
+     default_backend apiserver
+
+#---------------------------------------------------------------------
+# round robin balancing for apiserver
+#---------------------------------------------------------------------
+backend apiserver
+     option httpchk GET /healthz
+     http-check expect status 200
+     mode tcp
+     option ssl-hello-chk
+     balance roundrobin
+{{- range .Backends }}
+     server {{ .Name }} {{ .IP }}:{{ $.Port }} check fall 3 rise 2
+{{- end }}
+`
+
+var parsedTemplate = template.Must(template.New("haproxy.cfg").Parse(configTemplate))
+
+// Backend is a control plane node behind the load balancer.
+type Backend struct {
+	Name string
+	IP   string
+}
+
+// TemplateData is the input for rendering `haproxy.cfg`.
+type TemplateData struct {
+	BindIP   string
+	Port     int
+	Backends []Backend
+}
+
+// RenderConfig renders the full `haproxy.cfg`.
+func RenderConfig(td TemplateData) ([]byte, error) {
+	var buf bytes.Buffer
+	err := parsedTemplate.Execute(&buf, td)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
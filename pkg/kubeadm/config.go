@@ -0,0 +1,71 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeadm
+
+const (
+	// DefaultBootstrapDir is the directory containing bootstrap files (e.g.
+	// `join-token.txt`) on a node.
+	DefaultBootstrapDir = "/var/data/tailsk8s-bootstrap"
+	// DefaultKubernetesVersion is the Kubernetes version for a new cluster.
+	DefaultKubernetesVersion = "v1.22.4"
+	// DefaultDNSDomain is the cluster DNS domain.
+	DefaultDNSDomain = "cluster.local"
+	// DefaultCgroupDriver is the `kubelet` cgroup driver.
+	DefaultCgroupDriver = "systemd"
+
+	// KindInit renders configuration for `kubeadm init`.
+	KindInit = "init"
+	// KindJoinControlPlane renders configuration for `kubeadm join` on a
+	// control plane node.
+	KindJoinControlPlane = "join-control-plane"
+	// KindJoinWorker renders configuration for `kubeadm join` on a worker
+	// node.
+	KindJoinWorker = "join-worker"
+)
+
+// Config provides the core set of (CLI) inputs needed to render `kubeadm`
+// configuration.
+type Config struct {
+	BootstrapDir      string
+	ClusterName       string
+	PodSubnet         string
+	ServiceSubnet     string
+	KubernetesVersion string
+	// HostIP is the Tailscale IP of the node; if unset, it is inferred via
+	// the local `tailscaled` API.
+	HostIP string
+	// NodeName is the name of the node; if unset, it is inferred from the
+	// hostname of the Tailscale device.
+	NodeName string
+	// Output is the file to write to; if unset, the configuration is printed
+	// to STDOUT.
+	Output string
+}
+
+// NewConfig returns a new `Config` with all relevant defaults provided and
+// options for overriding.
+func NewConfig(opts ...Option) (Config, error) {
+	c := Config{
+		BootstrapDir:      DefaultBootstrapDir,
+		KubernetesVersion: DefaultKubernetesVersion,
+	}
+	for _, opt := range opts {
+		err := opt(&c)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
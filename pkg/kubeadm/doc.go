@@ -0,0 +1,25 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubeadm renders `kubeadm` configuration files for `tailsk8s` nodes.
+//
+// The configuration is built from typed structs (a subset of the `kubeadm`
+// v1beta3 and `kubelet` v1beta1 types) using values read from the bootstrap
+// directory and the local `tailscaled` API. Every value is validated before
+// rendering; this replaces filling templates via `envsubst`, which silently
+// substitutes an empty value when an input is missing.
+//
+// This is provided in a way to optimize the testable surface area (even for
+// untested parts of the code) without having any usage of `os.Exit()`.
+package kubeadm
@@ -0,0 +1,287 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeadm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tailscale.com/atomicfile"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+// Inputs are the validated values used to render `kubeadm` configuration.
+type Inputs struct {
+	JoinToken                string
+	CACertHash               string
+	CertificateKey           string
+	ControlPlaneLoadBalancer string
+	HostIP                   string
+	NodeName                 string
+}
+
+// Render reads and validates all inputs and renders configuration for
+// `kind` (one of `init`, `join-control-plane` or `join-worker`).
+func Render(ctx context.Context, c Config, kind string) error {
+	in, err := ReadInputs(ctx, c, kind)
+	if err != nil {
+		return err
+	}
+
+	var docs []interface{}
+	switch kind {
+	case KindInit:
+		docs, err = InitDocuments(c, in)
+	case KindJoinControlPlane:
+		docs = JoinDocuments(in, true)
+	case KindJoinWorker:
+		docs = JoinDocuments(in, false)
+	default:
+		return fmt.Errorf("unknown configuration kind %q", kind)
+	}
+	if err != nil {
+		return err
+	}
+
+	rendered, err := RenderDocuments(docs...)
+	if err != nil {
+		return err
+	}
+	if c.Output == "" {
+		cli.Printf(ctx, "%s", rendered)
+		return nil
+	}
+
+	// NOTE: The rendered configuration contains the join token (and possibly
+	//       the certificate key), so it is only readable by the owner.
+	err = atomicfile.WriteFile(c.Output, rendered, 0600)
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "Wrote %s configuration: %s\n", kind, c.Output)
+	return nil
+}
+
+// ReadInputs reads values from the bootstrap directory (and `tailscaled`)
+// that are needed for `kind` and validates each of them.
+func ReadInputs(ctx context.Context, c Config, kind string) (Inputs, error) {
+	in := Inputs{HostIP: c.HostIP, NodeName: c.NodeName}
+	var err error
+
+	in.JoinToken, err = readBootstrapFile(c.BootstrapDir, "join-token.txt")
+	if err != nil {
+		return Inputs{}, err
+	}
+	err = ValidateToken(in.JoinToken)
+	if err != nil {
+		return Inputs{}, err
+	}
+
+	in.ControlPlaneLoadBalancer, err = readBootstrapFile(c.BootstrapDir, "control-plane-load-balancer.txt")
+	if err != nil {
+		return Inputs{}, err
+	}
+	_, err = ValidateIPv4("control plane load balancer", in.ControlPlaneLoadBalancer)
+	if err != nil {
+		return Inputs{}, err
+	}
+
+	if kind == KindInit || kind == KindJoinControlPlane {
+		in.CertificateKey, err = readBootstrapFile(c.BootstrapDir, "certificate-key.txt")
+		if err != nil {
+			return Inputs{}, err
+		}
+		err = ValidateCertificateKey(in.CertificateKey)
+		if err != nil {
+			return Inputs{}, err
+		}
+	}
+
+	if kind == KindJoinControlPlane || kind == KindJoinWorker {
+		hash, err := readBootstrapFile(c.BootstrapDir, "ca-cert-hash.txt")
+		if err != nil {
+			return Inputs{}, err
+		}
+		in.CACertHash, err = NormalizeCACertHash(hash)
+		if err != nil {
+			return Inputs{}, err
+		}
+	}
+
+	err = inferNode(ctx, &in)
+	if err != nil {
+		return Inputs{}, err
+	}
+	_, err = ValidateIPv4("host IP", in.HostIP)
+	if err != nil {
+		return Inputs{}, err
+	}
+	return in, nil
+}
+
+// InitDocuments builds the `kubeadm init` configuration documents.
+func InitDocuments(c Config, in Inputs) ([]interface{}, error) {
+	if c.ClusterName == "" {
+		return nil, errors.New("cluster name is required")
+	}
+	err := ValidateVersion(c.KubernetesVersion)
+	if err != nil {
+		return nil, err
+	}
+	err = ValidateSubnets(c.PodSubnet, c.ServiceSubnet)
+	if err != nil {
+		return nil, err
+	}
+
+	ic := InitConfiguration{
+		TypeMeta: TypeMeta{APIVersion: APIVersion, Kind: "InitConfiguration"},
+		BootstrapTokens: []BootstrapToken{
+			{Token: in.JoinToken, Description: "kubeadm bootstrap token"},
+		},
+		LocalAPIEndpoint: APIEndpoint{AdvertiseAddress: in.HostIP, BindPort: APIServerPort},
+		CertificateKey:   in.CertificateKey,
+		NodeRegistration: nodeRegistration(in),
+	}
+	cc := ClusterConfiguration{
+		TypeMeta:             TypeMeta{APIVersion: APIVersion, Kind: "ClusterConfiguration"},
+		KubernetesVersion:    c.KubernetesVersion,
+		ClusterName:          c.ClusterName,
+		ControlPlaneEndpoint: controlPlaneEndpoint(in),
+		Networking: Networking{
+			DNSDomain:     DefaultDNSDomain,
+			PodSubnet:     c.PodSubnet,
+			ServiceSubnet: c.ServiceSubnet,
+		},
+	}
+	return []interface{}{ic, cc, kubeletConfiguration()}, nil
+}
+
+// JoinDocuments builds the `kubeadm join` configuration documents for a
+// control plane or worker node.
+func JoinDocuments(in Inputs, controlPlane bool) []interface{} {
+	jc := JoinConfiguration{
+		TypeMeta: TypeMeta{APIVersion: APIVersion, Kind: "JoinConfiguration"},
+		Discovery: Discovery{
+			BootstrapToken: BootstrapTokenDiscovery{
+				APIServerEndpoint: controlPlaneEndpoint(in),
+				Token:             in.JoinToken,
+				CACertHashes:      []string{in.CACertHash},
+			},
+		},
+		NodeRegistration: nodeRegistration(in),
+	}
+	if controlPlane {
+		jc.ControlPlane = &JoinControlPlane{
+			LocalAPIEndpoint: APIEndpoint{AdvertiseAddress: in.HostIP, BindPort: APIServerPort},
+			CertificateKey:   in.CertificateKey,
+		}
+	}
+	return []interface{}{jc, kubeletConfiguration()}
+}
+
+// RenderDocuments renders each value as a YAML document, separated by `---`.
+func RenderDocuments(docs ...interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, doc := range docs {
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		rendered, err := MarshalYAML(doc)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(rendered)
+	}
+	return buf.Bytes(), nil
+}
+
+func nodeRegistration(in Inputs) NodeRegistrationOptions {
+	// NOTE: The `node-ip` is set to ensure the Tailscale IP is used vs. a
+	//       local IP (e.g. `192.168.7.131`).
+	return NodeRegistrationOptions{
+		Name:             in.NodeName,
+		KubeletExtraArgs: map[string]string{"node-ip": in.HostIP},
+	}
+}
+
+func kubeletConfiguration() KubeletConfiguration {
+	return KubeletConfiguration{
+		TypeMeta:     TypeMeta{APIVersion: KubeletAPIVersion, Kind: "KubeletConfiguration"},
+		CgroupDriver: DefaultCgroupDriver,
+	}
+}
+
+func controlPlaneEndpoint(in Inputs) string {
+	return fmt.Sprintf("%s:%d", in.ControlPlaneLoadBalancer, APIServerPort)
+}
+
+// inferNode fills in the host IP and node name (if unset) from the local
+// `tailscaled` status.
+func inferNode(ctx context.Context, in *Inputs) error {
+	if in.HostIP != "" && in.NodeName != "" {
+		return nil
+	}
+
+	status, err := local.StatusWithoutPeers(ctx)
+	if err != nil {
+		return err
+	}
+	if status.Self == nil {
+		return errors.New("could not determine the local Tailscale device (is tailscaled logged in)")
+	}
+
+	if in.HostIP == "" {
+		for _, ip := range status.Self.TailscaleIPs {
+			if ip.Is4() {
+				in.HostIP = ip.String()
+				break
+			}
+		}
+		if in.HostIP == "" {
+			return errors.New("the local Tailscale device has no IPv4 address")
+		}
+	}
+	if in.NodeName == "" {
+		in.NodeName = strings.ToLower(status.Self.HostName)
+		if in.NodeName == "" {
+			return errors.New("the local Tailscale device has no hostname")
+		}
+	}
+	return nil
+}
+
+func readBootstrapFile(dir, name string) (string, error) {
+	filename := filepath.Join(dir, name)
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("bootstrap file %s is missing", filename)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", fmt.Errorf("bootstrap file %s is empty", filename)
+	}
+	return value, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeadm
+
+const (
+	// APIVersion is the `kubeadm` configuration API version.
+	APIVersion = "kubeadm.k8s.io/v1beta3"
+	// KubeletAPIVersion is the `kubelet` configuration API version.
+	KubeletAPIVersion = "kubelet.config.k8s.io/v1beta1"
+	// APIServerPort is the port used by the Kubernetes API server on every
+	// control plane node (and the load balancer).
+	APIServerPort = 6443
+)
+
+// TypeMeta identifies the type of a configuration document.
+type TypeMeta struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+}
+
+// BootstrapToken is a token used to join nodes to the cluster.
+type BootstrapToken struct {
+	Token       string `json:"token"`
+	Description string `json:"description,omitempty"`
+}
+
+// APIEndpoint is the address the API server listens on for a node.
+type APIEndpoint struct {
+	AdvertiseAddress string `json:"advertiseAddress"`
+	BindPort         int    `json:"bindPort"`
+}
+
+// NodeRegistrationOptions describes how a node registers with the cluster.
+type NodeRegistrationOptions struct {
+	Name             string            `json:"name"`
+	KubeletExtraArgs map[string]string `json:"kubeletExtraArgs,omitempty"`
+}
+
+// InitConfiguration is the `kubeadm init` configuration for the first
+// control plane node.
+type InitConfiguration struct {
+	TypeMeta
+	BootstrapTokens  []BootstrapToken        `json:"bootstrapTokens"`
+	LocalAPIEndpoint APIEndpoint             `json:"localAPIEndpoint"`
+	CertificateKey   string                  `json:"certificateKey"`
+	NodeRegistration NodeRegistrationOptions `json:"nodeRegistration"`
+}
+
+// Networking is the networking configuration for the cluster.
+type Networking struct {
+	DNSDomain     string `json:"dnsDomain"`
+	PodSubnet     string `json:"podSubnet"`
+	ServiceSubnet string `json:"serviceSubnet"`
+}
+
+// ClusterConfiguration is the cluster-wide `kubeadm` configuration.
+type ClusterConfiguration struct {
+	TypeMeta
+	KubernetesVersion    string     `json:"kubernetesVersion"`
+	ClusterName          string     `json:"clusterName"`
+	ControlPlaneEndpoint string     `json:"controlPlaneEndpoint"`
+	Networking           Networking `json:"networking"`
+}
+
+// KubeletConfiguration is the `kubelet` configuration shared by all nodes.
+type KubeletConfiguration struct {
+	TypeMeta
+	CgroupDriver string `json:"cgroupDriver"`
+}
+
+// BootstrapTokenDiscovery is used to discover (and pin) the cluster CA.
+type BootstrapTokenDiscovery struct {
+	APIServerEndpoint string   `json:"apiServerEndpoint"`
+	Token             string   `json:"token"`
+	CACertHashes      []string `json:"caCertHashes"`
+}
+
+// Discovery describes how a joining node discovers the cluster.
+type Discovery struct {
+	BootstrapToken BootstrapTokenDiscovery `json:"bootstrapToken"`
+}
+
+// JoinControlPlane is set when a joining node is a control plane node.
+type JoinControlPlane struct {
+	LocalAPIEndpoint APIEndpoint `json:"localAPIEndpoint"`
+	CertificateKey   string      `json:"certificateKey"`
+}
+
+// JoinConfiguration is the `kubeadm join` configuration.
+type JoinConfiguration struct {
+	TypeMeta
+	Discovery        Discovery               `json:"discovery"`
+	NodeRegistration NodeRegistrationOptions `json:"nodeRegistration"`
+	ControlPlane     *JoinControlPlane       `json:"controlPlane,omitempty"`
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeadm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"inet.af/netaddr"
+)
+
+var (
+	tokenPattern          = regexp.MustCompile(`^[a-z0-9]{6}\.[a-z0-9]{16}$`)
+	caCertHashPattern     = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+	certificateKeyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	versionPattern        = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+$`)
+	cgnat                 = netaddr.MustParseIPPrefix("100.64.0.0/10")
+)
+
+// ValidateToken ensures a bootstrap token has the `[a-z0-9]{6}.[a-z0-9]{16}`
+// format produced by `kubeadm token generate`.
+func ValidateToken(token string) error {
+	if !tokenPattern.MatchString(token) {
+		return fmt.Errorf("invalid join token, expected format [a-z0-9]{6}.[a-z0-9]{16}")
+	}
+	return nil
+}
+
+// NormalizeCACertHash validates a CA certificate hash and ensures it has a
+// `sha256:` prefix (`ca-cert-hash.txt` only contains the hex digest).
+func NormalizeCACertHash(hash string) (string, error) {
+	if !strings.HasPrefix(hash, "sha256:") {
+		hash = "sha256:" + hash
+	}
+	if !caCertHashPattern.MatchString(hash) {
+		return "", fmt.Errorf("invalid CA certificate hash %q, expected 64 hex characters", hash)
+	}
+	return hash, nil
+}
+
+// ValidateCertificateKey ensures a certificate key has the 64 hex character
+// format produced by `kubeadm certs certificate-key`.
+func ValidateCertificateKey(key string) error {
+	if !certificateKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid certificate key, expected 64 hex characters")
+	}
+	return nil
+}
+
+// ValidateVersion ensures a Kubernetes version has the form `v1.22.4`.
+func ValidateVersion(version string) error {
+	if !versionPattern.MatchString(version) {
+		return fmt.Errorf("invalid Kubernetes version %q, expected a value like %q", version, DefaultKubernetesVersion)
+	}
+	return nil
+}
+
+// ValidateIPv4 ensures a value is an IPv4 address (e.g. a Tailscale IP).
+func ValidateIPv4(name, value string) (netaddr.IP, error) {
+	ip, err := netaddr.ParseIP(value)
+	if err != nil {
+		return netaddr.IP{}, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if !ip.Is4() {
+		return netaddr.IP{}, fmt.Errorf("invalid %s %s, expected an IPv4 address", name, ip)
+	}
+	return ip, nil
+}
+
+// ValidateSubnets ensures the pod and service subnets are valid IPv4 CIDRs
+// (with no host bits set) that do not overlap each other or the `100.x.y.z`
+// CGNAT address space used by Tailscale.
+func ValidateSubnets(podSubnet, serviceSubnet string) error {
+	pod, err := parseSubnet("pod subnet", podSubnet)
+	if err != nil {
+		return err
+	}
+	service, err := parseSubnet("service subnet", serviceSubnet)
+	if err != nil {
+		return err
+	}
+	if pod.Overlaps(service) {
+		return fmt.Errorf("pod subnet %s overlaps service subnet %s", pod, service)
+	}
+	return nil
+}
+
+func parseSubnet(name, value string) (netaddr.IPPrefix, error) {
+	p, err := netaddr.ParseIPPrefix(value)
+	if err != nil {
+		return netaddr.IPPrefix{}, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if !p.IP().Is4() {
+		return netaddr.IPPrefix{}, fmt.Errorf("invalid %s %s, expected an IPv4 CIDR", name, p)
+	}
+	if p.Masked() != p {
+		return netaddr.IPPrefix{}, fmt.Errorf("invalid %s %s, host bits are set (did you mean %s?)", name, p, p.Masked())
+	}
+	if p.Overlaps(cgnat) {
+		return netaddr.IPPrefix{}, fmt.Errorf("invalid %s %s, it overlaps the Tailscale CGNAT range %s", name, p, cgnat)
+	}
+	return p, nil
+}
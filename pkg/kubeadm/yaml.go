@@ -0,0 +1,213 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubeadm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MarshalYAML renders a value as a YAML document. Only the shapes used by
+// the types in this package are supported: structs (with `json` tags and
+// embedded structs inlined), pointers, `map[string]string`, slices, strings,
+// integers and booleans. Using the `json` tags means the same types can be
+// rendered as JSON without any changes.
+func MarshalYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := writeMapping(&buf, reflect.ValueOf(v), 0, false)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type field struct {
+	name  string
+	value reflect.Value
+}
+
+// mappingFields returns the (ordered) fields of a struct or map that should
+// be rendered.
+func mappingFields(v reflect.Value) ([]field, error) {
+	switch v.Kind() {
+	case reflect.Map:
+		keys := []string{}
+		for _, k := range v.MapKeys() {
+			keys = append(keys, k.String())
+		}
+		sort.Strings(keys)
+		fields := []field{}
+		for _, k := range keys {
+			fields = append(fields, field{name: k, value: v.MapIndex(reflect.ValueOf(k))})
+		}
+		return fields, nil
+	case reflect.Struct:
+		fields := []field{}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			fv := v.Field(i)
+			if sf.Anonymous {
+				inner, err := mappingFields(fv)
+				if err != nil {
+					return nil, err
+				}
+				fields = append(fields, inner...)
+				continue
+			}
+
+			name, omitEmpty := sf.Name, false
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			parts := strings.Split(tag, ",")
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				omitEmpty = omitEmpty || opt == "omitempty"
+			}
+			if omitEmpty && isEmpty(fv) {
+				continue
+			}
+			fields = append(fields, field{name: name, value: fv})
+		}
+		return fields, nil
+	}
+	return nil, fmt.Errorf("cannot render %s as a YAML mapping", v.Kind())
+}
+
+// isEmpty matches the `encoding/json` definition of an empty value for
+// `omitempty`.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+// writeMapping writes a struct or map. If `inSequence` is set, the first
+// key is written on the current line (i.e. directly after `- `).
+func writeMapping(buf *bytes.Buffer, v reflect.Value, indent int, inSequence bool) error {
+	v = indirect(v)
+	fields, err := mappingFields(v)
+	if err != nil {
+		return err
+	}
+
+	for i, f := range fields {
+		prefix := strings.Repeat(" ", indent)
+		if inSequence && i == 0 {
+			prefix = ""
+		}
+
+		value := indirect(f.value)
+		switch value.Kind() {
+		case reflect.Struct, reflect.Map:
+			fmt.Fprintf(buf, "%s%s:\n", prefix, f.name)
+			err = writeMapping(buf, value, indent+2, false)
+		case reflect.Slice:
+			if value.Len() == 0 {
+				fmt.Fprintf(buf, "%s%s: []\n", prefix, f.name)
+				continue
+			}
+			fmt.Fprintf(buf, "%s%s:\n", prefix, f.name)
+			err = writeSequence(buf, value, indent+2)
+		default:
+			var s string
+			s, err = scalar(value)
+			fmt.Fprintf(buf, "%s%s: %s\n", prefix, f.name, s)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSequence(buf *bytes.Buffer, v reflect.Value, indent int) error {
+	prefix := strings.Repeat(" ", indent)
+	for i := 0; i < v.Len(); i++ {
+		item := indirect(v.Index(i))
+		if item.Kind() == reflect.Struct || item.Kind() == reflect.Map {
+			buf.WriteString(prefix + "- ")
+			err := writeMapping(buf, item, indent+2, true)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, err := scalar(item)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%s- %s\n", prefix, s)
+	}
+	return nil
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	return v
+}
+
+func scalar(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return quoteIfNeeded(v.String()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("cannot render %s as a YAML scalar", v.Kind())
+}
+
+// quoteIfNeeded double quotes a string if it would otherwise be parsed as
+// something other than the same string (e.g. a number, a boolean or null) or
+// if it contains characters with special meaning in YAML.
+func quoteIfNeeded(s string) string {
+	if s == "" || strings.TrimSpace(s) != s {
+		return strconv.Quote(s)
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null", "~":
+		return strconv.Quote(s)
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	if err == nil {
+		return strconv.Quote(s)
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return strconv.Quote(s)
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.ContainsAny(s, "\n\t") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,21 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubernetes provides helpers for interacting with a Kubernetes cluster.
+//
+// Rather than depending on a Kubernetes client library, this package execs
+// out to `kubectl`, matching the way the `tailsk8s` scripts interact with the
+// cluster. Every invocation goes through a `cli.RunFunc` so that callers can
+// substitute a fake `kubectl` in tests.
+package kubernetes
@@ -0,0 +1,94 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// EventTypeNormal is the event type for informational events.
+	EventTypeNormal = "Normal"
+	// EventTypeWarning is the event type for events that may need attention.
+	EventTypeWarning = "Warning"
+)
+
+// ObjectReference is the subset of a Kubernetes object reference used here.
+type ObjectReference struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+	UID        string `json:"uid,omitempty"`
+}
+
+// EventSource identifies the component that emitted an event.
+type EventSource struct {
+	Component string `json:"component,omitempty"`
+	Host      string `json:"host,omitempty"`
+}
+
+// Event is the subset of a Kubernetes (`core/v1`) `Event` object used here.
+type Event struct {
+	APIVersion     string          `json:"apiVersion"`
+	Kind           string          `json:"kind"`
+	Metadata       ObjectMeta      `json:"metadata"`
+	InvolvedObject ObjectReference `json:"involvedObject"`
+	Reason         string          `json:"reason"`
+	Message        string          `json:"message"`
+	Type           string          `json:"type"`
+	Source         EventSource     `json:"source"`
+	Count          int             `json:"count"`
+	FirstTimestamp time.Time       `json:"firstTimestamp"`
+	LastTimestamp  time.Time       `json:"lastTimestamp"`
+}
+
+// NodeEvent returns an event about a node. Since nodes are not namespaced,
+// the event is created in the `default` namespace (matching `kubelet`).
+func NodeEvent(n Node, component, eventType, reason, message string, now time.Time) Event {
+	return Event{
+		APIVersion: "v1",
+		Kind:       "Event",
+		Metadata: ObjectMeta{
+			Name:      n.Metadata.Name + "." + now.UTC().Format("20060102150405.000000000"),
+			Namespace: "default",
+		},
+		InvolvedObject: ObjectReference{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       n.Metadata.Name,
+			UID:        n.Metadata.UID,
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         EventSource{Component: component},
+		Count:          1,
+		FirstTimestamp: now.UTC().Truncate(time.Second),
+		LastTimestamp:  now.UTC().Truncate(time.Second),
+	}
+}
+
+// CreateEvent creates an event in the cluster.
+func (k Kubectl) CreateEvent(ctx context.Context, e Event) error {
+	asJSON, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = k.Exec(ctx, asJSON, "create", "--filename", "-")
+	return err
+}
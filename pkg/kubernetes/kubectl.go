@@ -0,0 +1,53 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// Kubectl is a thin wrapper around the `kubectl` binary.
+type Kubectl struct {
+	// Binary is the `kubectl` executable; defaults to `kubectl`.
+	Binary string
+	// Kubeconfig is an optional path to a kubeconfig file; if unset, `kubectl`
+	// will use its own defaults (e.g. `${KUBECONFIG}` or `~/.kube/config`).
+	Kubeconfig string
+	// Run executes the command; defaults to `cli.ExecRun`.
+	Run cli.RunFunc
+}
+
+// Exec runs `kubectl` with the given arguments and returns STDOUT.
+func (k Kubectl) Exec(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	binary := k.Binary
+	if binary == "" {
+		binary = "kubectl"
+	}
+	run := k.Run
+	if run == nil {
+		run = cli.ExecRun
+	}
+
+	all := []string{}
+	if k.Kubeconfig != "" {
+		all = append(all, "--kubeconfig", k.Kubeconfig)
+	}
+	all = append(all, args...)
+	cli.DebugPrintf(ctx, "Running: %s %s\n", binary, strings.Join(all, " "))
+	return run(ctx, stdin, binary, all...)
+}
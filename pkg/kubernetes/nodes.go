@@ -0,0 +1,153 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	// AdvertiseSubnetLabel is the node label used to record the subnet that
+	// a node advertises to the Tailnet. Since `/` is not allowed in a label
+	// value, the CIDR is encoded via `EncodeSubnetLabel()`.
+	AdvertiseSubnetLabel = "tailsk8s.io/advertise-subnet"
+)
+
+// ObjectMeta is the subset of Kubernetes object metadata used here.
+type ObjectMeta struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	UID         string            `json:"uid,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// NodeSpec is the subset of a Kubernetes `Node` spec used here.
+type NodeSpec struct {
+	PodCIDR  string   `json:"podCIDR,omitempty"`
+	PodCIDRs []string `json:"podCIDRs,omitempty"`
+}
+
+// Node is the subset of a Kubernetes `Node` object used here.
+type Node struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     NodeSpec   `json:"spec"`
+}
+
+// NodeList is a list of Kubernetes `Node` objects.
+type NodeList struct {
+	Items []Node `json:"items"`
+}
+
+// AdvertiseSubnet returns the (decoded) value of the advertise subnet label,
+// or the empty string if the label is not set.
+func (n Node) AdvertiseSubnet() string {
+	return DecodeSubnetLabel(n.Metadata.Labels[AdvertiseSubnetLabel])
+}
+
+// EncodeSubnetLabel encodes a CIDR as a label value, e.g. `10.100.2.0/24`
+// becomes `10.100.2.0__24`.
+func EncodeSubnetLabel(cidr string) string {
+	return strings.ReplaceAll(cidr, "/", "__")
+}
+
+// DecodeSubnetLabel is the inverse of `EncodeSubnetLabel()`.
+func DecodeSubnetLabel(value string) string {
+	return strings.ReplaceAll(value, "__", "/")
+}
+
+// GetNodes lists all nodes in the cluster.
+func (k Kubectl) GetNodes(ctx context.Context) ([]Node, error) {
+	stdout, err := k.Exec(ctx, nil, "get", "nodes", "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var nl NodeList
+	err = json.Unmarshal(stdout, &nl)
+	if err != nil {
+		return nil, err
+	}
+	return nl.Items, nil
+}
+
+// GetNode gets a single node by name.
+func (k Kubectl) GetNode(ctx context.Context, name string) (*Node, error) {
+	stdout, err := k.Exec(ctx, nil, "get", "node", name, "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+
+	var n Node
+	err = json.Unmarshal(stdout, &n)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// FindNode gets a single node by name, returning `nil` (and no error) if
+// the node does not exist.
+func (k Kubectl) FindNode(ctx context.Context, name string) (*Node, error) {
+	stdout, err := k.Exec(ctx, nil, "get", "node", name, "--ignore-not-found", "--output", "json")
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(stdout)) == 0 {
+		return nil, nil
+	}
+
+	var n Node
+	err = json.Unmarshal(stdout, &n)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// DrainNode cordons a node and evicts all pods (other than those managed by
+// a `DaemonSet`).
+func (k Kubectl) DrainNode(ctx context.Context, name string) error {
+	_, err := k.Exec(ctx, nil, "drain", name, "--delete-emptydir-data", "--force", "--ignore-daemonsets")
+	return err
+}
+
+// DeleteNode removes a node from the cluster.
+func (k Kubectl) DeleteNode(ctx context.Context, name string) error {
+	_, err := k.Exec(ctx, nil, "delete", "node", name)
+	return err
+}
+
+// LabelNode sets a label on a node. If `overwrite` is false, this will fail
+// if the label is already set to a different value.
+func (k Kubectl) LabelNode(ctx context.Context, name, key, value string, overwrite bool) error {
+	args := []string{"label", "node", name, fmt.Sprintf("%s=%s", key, value)}
+	if overwrite {
+		args = append(args, "--overwrite")
+	}
+	_, err := k.Exec(ctx, nil, args...)
+	return err
+}
+
+// RemoveNodeLabel removes a label from a node (this is a no-op if the label
+// is not set).
+func (k Kubectl) RemoveNodeLabel(ctx context.Context, name, key string) error {
+	_, err := k.Exec(ctx, nil, "label", "node", name, key+"-")
+	return err
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Secret is the subset of a Kubernetes `Secret` object used here.
+type Secret struct {
+	Data map[string]string `json:"data"`
+}
+
+// GetSecretValue reads a single (decoded) value from a Kubernetes `Secret`.
+func (k Kubectl) GetSecretValue(ctx context.Context, namespace, name, key string) (string, error) {
+	stdout, err := k.Exec(ctx, nil, "get", "secret", name, "--namespace", namespace, "--output", "json")
+	if err != nil {
+		return "", err
+	}
+
+	var s Secret
+	err = json.Unmarshal(stdout, &s)
+	if err != nil {
+		return "", err
+	}
+
+	encoded, ok := s.Data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s/%s has no key %q", namespace, name, key)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
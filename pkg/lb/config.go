@@ -0,0 +1,112 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lb
+
+import (
+	"context"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	// DefaultPort is the port of the Kubernetes API server, used both for the
+	// load balancer and the backends.
+	DefaultPort = 6443
+	// DefaultStatusAddr is the address of the status endpoint.
+	DefaultStatusAddr = "127.0.0.1:8404"
+	// DefaultCheckInterval matches the HAProxy default `inter 2s`.
+	DefaultCheckInterval = 2 * time.Second
+	// DefaultCheckTimeout matches `timeout check 10s` in our HAProxy
+	// configuration.
+	DefaultCheckTimeout = 10 * time.Second
+	// DefaultFall matches `fall 3` in our HAProxy configuration.
+	DefaultFall = 3
+	// DefaultRise matches `rise 2` in our HAProxy configuration.
+	DefaultRise = 2
+	// DefaultDialTimeout matches `timeout connect 5s` in our HAProxy
+	// configuration.
+	DefaultDialTimeout = 5 * time.Second
+	// DefaultDiscoveryInterval is the time between Tailnet discovery passes.
+	DefaultDiscoveryInterval = 30 * time.Second
+	// DefaultDrainTimeout is how long active connections are allowed to
+	// finish when the load balancer shuts down.
+	DefaultDrainTimeout = 30 * time.Second
+)
+
+// HealthCheckFunc checks if a backend (`host:port`) is healthy.
+type HealthCheckFunc func(ctx context.Context, addr string) error
+
+// Config provides the core set of (CLI) inputs needed to run the load
+// balancer.
+type Config struct {
+	APIConfig cloud.Config
+	// BindIP is the IP the load balancer listens on; if unset, the Tailscale
+	// IPv4 address of the local device is used.
+	BindIP string
+	Port   int
+	// Backends are static backend addresses (`host:port`).
+	Backends []string
+	// Hostnames and Tag select control plane devices in the Tailnet; if
+	// either is set, backends are discovered dynamically.
+	Hostnames         []string
+	Tag               string
+	BackendPort       int
+	DiscoveryInterval time.Duration
+	StatusAddr        string
+	CheckInterval     time.Duration
+	CheckTimeout      time.Duration
+	Fall              int
+	Rise              int
+	DialTimeout       time.Duration
+	DrainTimeout      time.Duration
+	// HealthCheck defaults to an HTTPS `GET /healthz` that expects a 200.
+	HealthCheck HealthCheckFunc
+}
+
+// NewConfig returns a new `Config` with all relevant defaults provided and
+// options for overriding.
+func NewConfig(opts ...Option) (Config, error) {
+	ac, err := cloud.NewConfig()
+	if err != nil {
+		return Config{}, err
+	}
+
+	c := Config{
+		APIConfig:         ac,
+		Port:              DefaultPort,
+		BackendPort:       DefaultPort,
+		DiscoveryInterval: DefaultDiscoveryInterval,
+		StatusAddr:        DefaultStatusAddr,
+		CheckInterval:     DefaultCheckInterval,
+		CheckTimeout:      DefaultCheckTimeout,
+		Fall:              DefaultFall,
+		Rise:              DefaultRise,
+		DialTimeout:       DefaultDialTimeout,
+		DrainTimeout:      DefaultDrainTimeout,
+	}
+	for _, opt := range opts {
+		err := opt(&c)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+// Discovery indicates if backends are discovered from the Tailnet.
+func (c Config) Discovery() bool {
+	return len(c.Hostnames) > 0 || c.Tag != ""
+}
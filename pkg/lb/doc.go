@@ -0,0 +1,27 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lb provides a layer 4 (TCP) load balancer for the Kubernetes API
+// servers running on control plane nodes.
+//
+// This is intended as a built-in alternative to running HAProxy on a spare
+// machine: it listens on the Tailscale IP, balances connections round-robin
+// across healthy backends, health checks backends via HTTPS `GET /healthz`
+// with the same fall / rise thresholds as our HAProxy configuration and can
+// discover backends dynamically from the Tailnet. Backends that are removed
+// are drained (no new connections) rather than cut off.
+//
+// This is provided in a way to optimize the testable surface area (even for
+// untested parts of the code) without having any usage of `os.Exit()`.
+package lb
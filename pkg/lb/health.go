@@ -0,0 +1,102 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lb
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// HTTPSHealthCheck returns a health check that sends `GET /healthz` to the
+// backend over HTTPS and expects a 200 (matching `option httpchk GET /healthz`
+// and `http-check expect status 200` in our HAProxy configuration).
+// The API server certificate is not verified since the backend is addressed
+// by IP; the check only determines liveness, proxied traffic is never
+// terminated by the load balancer.
+func HTTPSHealthCheck(timeout time.Duration) HealthCheckFunc {
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			DisableKeepAlives: true,
+		},
+	}
+	return func(ctx context.Context, addr string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("https://%s/healthz", addr), nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+
+		defer resp.Body.Close()
+		// Ignore error: the body is only drained so it is fully read.
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("health check returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// checkBackends runs a health check against every backend (concurrently)
+// and records the results.
+func checkBackends(ctx context.Context, c Config, pool *Pool) {
+	var wg sync.WaitGroup
+	for _, addr := range pool.Addrs() {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.CheckTimeout)
+			defer cancel()
+
+			err := c.HealthCheck(checkCtx, addr)
+			s, changed := pool.RecordCheck(addr, err)
+			if s == nil || !changed {
+				return
+			}
+			if s.Healthy {
+				cli.Printf(ctx, "Backend %s (%s) is UP\n", s.Name, s.Addr)
+			} else {
+				cli.Printf(ctx, "Backend %s (%s) is DOWN: %s\n", s.Name, s.Addr, s.LastError)
+			}
+		}(addr)
+	}
+	wg.Wait()
+}
+
+// healthLoop runs health checks every `CheckInterval` until `ctx` is
+// cancelled.
+func healthLoop(ctx context.Context, c Config, pool *Pool) {
+	ticker := time.NewTicker(c.CheckInterval)
+	defer ticker.Stop()
+	for {
+		checkBackends(ctx, c, pool)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
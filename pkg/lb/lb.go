@@ -0,0 +1,264 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/remix"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+// Serve runs the load balancer until `ctx` is cancelled. On shutdown, the
+// listener is closed and active connections are given `DrainTimeout` to
+// finish before being closed.
+func Serve(ctx context.Context, c Config) error {
+	err := validate(c)
+	if err != nil {
+		return err
+	}
+	if c.Discovery() {
+		err = c.APIConfig.Resolve(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	if c.BindIP == "" {
+		ip, err := local.SelfIPv4(ctx)
+		if err != nil {
+			return err
+		}
+		c.BindIP = ip.String()
+	}
+	if c.HealthCheck == nil {
+		c.HealthCheck = HTTPSHealthCheck(c.CheckTimeout)
+	}
+
+	pool := NewPool(c.Fall, c.Rise)
+	backends, err := DiscoverBackends(ctx, c)
+	if err != nil {
+		return err
+	}
+	pool.Update(backends)
+
+	addr := net.JoinHostPort(c.BindIP, strconv.Itoa(c.Port))
+	ln, err := listen(ctx, addr)
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "Load balancing %s across %d backend(s)\n", ln.Addr(), len(backends))
+
+	if c.StatusAddr != "" {
+		shutdown, err := serveStatus(ctx, c.StatusAddr, StatusHandler(ln.Addr().String(), pool))
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		defer shutdown(context.Background())
+		cli.Printf(ctx, "Serving status on http://%s/status\n", c.StatusAddr)
+	}
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go healthLoop(loopCtx, c, pool)
+	if c.Discovery() {
+		go discoveryLoop(loopCtx, c, pool)
+	}
+
+	conns := &connSet{conns: map[net.Conn]bool{}}
+	acceptDone := make(chan struct{})
+	go func() {
+		defer close(acceptDone)
+		acceptLoop(ctx, c, pool, ln, conns)
+	}()
+
+	<-ctx.Done()
+	ln.Close()
+	// NOTE: No new connections may be added once `conns.wait()` is called
+	//       (`sync.WaitGroup` does not allow `Add()` concurrent with
+	//       `Wait()`), so wait for the accept loop to exit first.
+	<-acceptDone
+	cli.Printf(ctx, "Draining %d active connection(s)\n", conns.len())
+	if !conns.wait(c.DrainTimeout) {
+		cli.Printf(ctx, "Closing %d connection(s) after %s drain timeout\n", conns.len(), c.DrainTimeout)
+		conns.closeAll()
+	}
+	return nil
+}
+
+// validate ensures that the config can be used to run the load balancer; in
+// particular `time.NewTicker()` panics for a non-positive interval.
+func validate(c Config) error {
+	if len(c.Backends) == 0 && !c.Discovery() {
+		return errors.New("at least one backend, control plane hostname or tag is required")
+	}
+	if c.CheckInterval <= 0 {
+		return fmt.Errorf("check interval must be positive, got %s", c.CheckInterval)
+	}
+	if c.CheckTimeout <= 0 {
+		return fmt.Errorf("check timeout must be positive, got %s", c.CheckTimeout)
+	}
+	if c.Discovery() && c.DiscoveryInterval <= 0 {
+		return fmt.Errorf("discovery interval must be positive, got %s", c.DiscoveryInterval)
+	}
+	if c.Fall < 1 {
+		return fmt.Errorf("fall must be at least 1, got %d", c.Fall)
+	}
+	if c.Rise < 1 {
+		return fmt.Errorf("rise must be at least 1, got %d", c.Rise)
+	}
+	if c.DrainTimeout < 0 {
+		return fmt.Errorf("drain timeout must not be negative, got %s", c.DrainTimeout)
+	}
+	return nil
+}
+
+// DiscoverBackends combines the static backends with control plane devices
+// discovered from the Tailnet.
+func DiscoverBackends(ctx context.Context, c Config) ([]Backend, error) {
+	backends := []Backend{}
+	for _, b := range c.Backends {
+		_, _, err := net.SplitHostPort(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid backend %q: %w", b, err)
+		}
+		backends = append(backends, Backend{Name: b, Addr: b})
+	}
+	if !c.Discovery() {
+		return backends, nil
+	}
+
+	req := remix.GetDevicesBySelectorRequest{Hostnames: c.Hostnames, Tag: c.Tag}
+	devices, err := remix.GetDevicesBySelector(ctx, c.APIConfig, req)
+	if err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		ip, err := remix.DeviceIPv4(device)
+		if err != nil {
+			return nil, err
+		}
+		addr := net.JoinHostPort(ip.String(), strconv.Itoa(c.BackendPort))
+		backends = append(backends, Backend{Name: device.Hostname, Addr: addr})
+	}
+	return backends, nil
+}
+
+// discoveryLoop refreshes the backends from the Tailnet every
+// `DiscoveryInterval` until `ctx` is cancelled. If discovery fails, the
+// current backends are kept.
+func discoveryLoop(ctx context.Context, c Config, pool *Pool) {
+	ticker := time.NewTicker(c.DiscoveryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		backends, err := DiscoverBackends(ctx, c)
+		if err != nil {
+			cli.Printf(ctx, "Backend discovery failed: %v\n", err)
+			continue
+		}
+		pool.Update(backends)
+	}
+}
+
+func acceptLoop(ctx context.Context, c Config, pool *Pool, ln net.Listener, conns *connSet) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				cli.Printf(ctx, "Failed to accept connection: %v\n", err)
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		conns.add(conn)
+		go func() {
+			defer conns.remove(conn)
+			handle(ctx, c, pool, conn)
+		}()
+	}
+}
+
+func listen(ctx context.Context, addr string) (net.Listener, error) {
+	var lc net.ListenConfig
+	return lc.Listen(ctx, "tcp", addr)
+}
+
+// connSet tracks active client connections so they can be drained (or
+// forcibly closed) on shutdown.
+type connSet struct {
+	mu    sync.Mutex
+	wg    sync.WaitGroup
+	conns map[net.Conn]bool
+}
+
+func (cs *connSet) add(conn net.Conn) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.wg.Add(1)
+	cs.conns[conn] = true
+}
+
+func (cs *connSet) remove(conn net.Conn) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	delete(cs.conns, conn)
+	cs.wg.Done()
+}
+
+func (cs *connSet) len() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return len(cs.conns)
+}
+
+// wait waits for all connections to finish; returns `false` on timeout.
+func (cs *connSet) wait(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		cs.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (cs *connSet) closeAll() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for conn := range cs.conns {
+		// Ignore error: the connection is being abandoned.
+		_ = conn.Close()
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lb
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+func TestServeValidate(t *testing.T) {
+	valid := Config{
+		BindIP:            "127.0.0.1",
+		Backends:          []string{"127.0.0.1:6443"},
+		CheckInterval:     DefaultCheckInterval,
+		CheckTimeout:      DefaultCheckTimeout,
+		DiscoveryInterval: DefaultDiscoveryInterval,
+		Fall:              DefaultFall,
+		Rise:              DefaultRise,
+	}
+	cases := map[string]func(c *Config){
+		"at least one backend":                func(c *Config) { c.Backends = nil },
+		"check interval must be positive":     func(c *Config) { c.CheckInterval = 0 },
+		"check timeout must be positive":      func(c *Config) { c.CheckTimeout = -time.Second },
+		"discovery interval must be positive": func(c *Config) { c.Tag = "tag:control-plane"; c.DiscoveryInterval = 0 },
+		"fall must be at least 1":             func(c *Config) { c.Fall = 0 },
+		"rise must be at least 1":             func(c *Config) { c.Rise = 0 },
+		"drain timeout must not be negative":  func(c *Config) { c.DrainTimeout = -time.Second },
+	}
+	for expected, modify := range cases {
+		c := valid
+		modify(&c)
+		err := Serve(context.Background(), c)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected error containing %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	backend := startBackend(t, "backend")
+	port := freePort(t)
+	var checks int32
+	c := Config{
+		BindIP:        "127.0.0.1",
+		Port:          port,
+		Backends:      []string{backend},
+		CheckInterval: 10 * time.Millisecond,
+		CheckTimeout:  time.Second,
+		Fall:          DefaultFall,
+		Rise:          DefaultRise,
+		DialTimeout:   time.Second,
+		DrainTimeout:  time.Second,
+		HealthCheck: func(_ context.Context, _ string) error {
+			atomic.AddInt32(&checks, 1)
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithCancel(cli.WithStdout(context.Background(), io.Discard))
+	defer cancel()
+	served := make(chan error, 1)
+	go func() {
+		served <- Serve(ctx, c)
+	}()
+
+	received := dialAndRead(t, net.JoinHostPort(c.BindIP, strconv.Itoa(port)))
+	if received != "backend" {
+		t.Fatalf("expected %q, got %q", "backend", received)
+	}
+	waitFor(t, func() bool { return atomic.LoadInt32(&checks) > 0 })
+
+	cancel()
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Serve() did not return after cancellation")
+	}
+}
+
+// freePort returns a port that is (very likely) free on the loopback
+// interface.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// dialAndRead connects to `addr` (retrying until the listener is up) and
+// returns everything received.
+func dialAndRead(t *testing.T, addr string) string {
+	t.Helper()
+	var conn net.Conn
+	waitFor(t, func() bool {
+		var err error
+		conn, err = net.Dial("tcp", addr)
+		return err == nil
+	})
+	defer conn.Close()
+
+	received, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("failed to read from %s: %v", addr, err)
+	}
+	return string(received)
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,226 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lb
+
+import (
+	"sort"
+	"sync"
+)
+
+// Backend is a server behind the load balancer.
+type Backend struct {
+	Name string
+	Addr string
+
+	healthy   bool
+	draining  bool
+	successes int
+	failures  int
+	active    int
+	total     int
+	lastError string
+}
+
+// BackendStatus is a point-in-time snapshot of a backend.
+type BackendStatus struct {
+	Name              string `json:"name"`
+	Addr              string `json:"addr"`
+	Healthy           bool   `json:"healthy"`
+	Draining          bool   `json:"draining"`
+	ActiveConnections int    `json:"activeConnections"`
+	TotalConnections  int    `json:"totalConnections"`
+	LastError         string `json:"lastError,omitempty"`
+}
+
+// Pool is the set of backends, with round-robin selection among healthy
+// backends. It is safe for concurrent use.
+type Pool struct {
+	mu       sync.Mutex
+	fall     int
+	rise     int
+	backends []*Backend
+	next     int
+}
+
+// NewPool returns an empty pool with the given health check thresholds.
+func NewPool(fall, rise int) *Pool {
+	return &Pool{fall: fall, rise: rise}
+}
+
+// Update sets the backends in the pool. New backends start out healthy (as
+// in HAProxy) and are marked down after `fall` failed checks. Backends that
+// are no longer present are drained: they receive no new connections and
+// are removed once their active connections finish.
+func (p *Pool) Update(backends []Backend) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	wanted := map[string]Backend{}
+	for _, b := range backends {
+		wanted[b.Addr] = b
+	}
+
+	kept := []*Backend{}
+	for _, b := range p.backends {
+		if w, ok := wanted[b.Addr]; ok {
+			b.Name = w.Name
+			b.draining = false
+			delete(wanted, b.Addr)
+		} else {
+			b.draining = true
+		}
+		if b.draining && b.active == 0 {
+			continue
+		}
+		kept = append(kept, b)
+	}
+	for _, b := range backends {
+		if _, ok := wanted[b.Addr]; !ok {
+			continue
+		}
+		kept = append(kept, &Backend{Name: b.Name, Addr: b.Addr, healthy: true})
+		delete(wanted, b.Addr)
+	}
+
+	sort.Slice(kept, func(i, j int) bool {
+		return kept[i].Addr < kept[j].Addr
+	})
+	p.backends = kept
+}
+
+// Acquire selects the next healthy backend (round-robin), skipping any in
+// `exclude`, and counts a new connection to it. Returns `nil` if no backend
+// is available. Each successful call must be matched by a call to `Release()`.
+func (p *Pool) Acquire(exclude map[string]bool) *Backend {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := len(p.backends)
+	for i := 0; i < n; i++ {
+		b := p.backends[(p.next+i)%n]
+		if !b.healthy || b.draining || exclude[b.Addr] {
+			continue
+		}
+		p.next = (p.next + i + 1) % n
+		b.active++
+		b.total++
+		return b
+	}
+	return nil
+}
+
+// Release marks a connection to a backend as finished; a draining backend
+// is removed once it has no active connections.
+func (p *Pool) Release(b *Backend) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b.active--
+	if !b.draining || b.active > 0 {
+		return
+	}
+	kept := []*Backend{}
+	for _, other := range p.backends {
+		if other != b {
+			kept = append(kept, other)
+		}
+	}
+	p.backends = kept
+}
+
+// Addrs returns the addresses of all backends (including draining backends).
+func (p *Pool) Addrs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	addrs := []string{}
+	for _, b := range p.backends {
+		addrs = append(addrs, b.Addr)
+	}
+	return addrs
+}
+
+// RecordCheck records the result of a health check. A backend is marked
+// down after `fall` consecutive failures and up after `rise` consecutive
+// successes. Returns a flag indicating if the health of the backend changed.
+func (p *Pool) RecordCheck(addr string, err error) (*BackendStatus, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, b := range p.backends {
+		if b.Addr != addr {
+			continue
+		}
+		changed := false
+		if err == nil {
+			b.failures = 0
+			b.successes++
+			b.lastError = ""
+			if !b.healthy && b.successes >= p.rise {
+				b.healthy = true
+				changed = true
+			}
+		} else {
+			b.successes = 0
+			b.failures++
+			b.lastError = err.Error()
+			if b.healthy && b.failures >= p.fall {
+				b.healthy = false
+				changed = true
+			}
+		}
+		s := b.status()
+		return &s, changed
+	}
+	return nil, false
+}
+
+// Status returns a snapshot of every backend.
+func (p *Pool) Status() []BackendStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := []BackendStatus{}
+	for _, b := range p.backends {
+		statuses = append(statuses, b.status())
+	}
+	return statuses
+}
+
+// Healthy counts the healthy backends that are not draining.
+func (p *Pool) Healthy() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	count := 0
+	for _, b := range p.backends {
+		if b.healthy && !b.draining {
+			count++
+		}
+	}
+	return count
+}
+
+func (b *Backend) status() BackendStatus {
+	return BackendStatus{
+		Name:              b.Name,
+		Addr:              b.Addr,
+		Healthy:           b.healthy,
+		Draining:          b.draining,
+		ActiveConnections: b.active,
+		TotalConnections:  b.total,
+		LastError:         b.lastError,
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestPoolAcquireRoundRobin(t *testing.T) {
+	p := NewPool(DefaultFall, DefaultRise)
+	p.Update([]Backend{
+		{Name: "b", Addr: "10.0.0.2:6443"},
+		{Name: "a", Addr: "10.0.0.1:6443"},
+		{Name: "c", Addr: "10.0.0.3:6443"},
+	})
+
+	picked := []string{}
+	for i := 0; i < 4; i++ {
+		b := p.Acquire(nil)
+		if b == nil {
+			t.Fatalf("expected a backend")
+		}
+		picked = append(picked, b.Name)
+		p.Release(b)
+	}
+	expected := []string{"a", "b", "c", "a"}
+	if !reflect.DeepEqual(picked, expected) {
+		t.Fatalf("expected %v, got %v", expected, picked)
+	}
+
+	b := p.Acquire(map[string]bool{"10.0.0.1:6443": true, "10.0.0.2:6443": true, "10.0.0.3:6443": true})
+	if b != nil {
+		t.Fatalf("expected no backend when all are excluded, got %s", b.Name)
+	}
+}
+
+func TestPoolFallRise(t *testing.T) {
+	p := NewPool(3, 2)
+	p.Update([]Backend{{Name: "a", Addr: "10.0.0.1:6443"}})
+	checkErr := errors.New("connection refused")
+
+	for i := 1; i <= 3; i++ {
+		s, changed := p.RecordCheck("10.0.0.1:6443", checkErr)
+		if s == nil {
+			t.Fatalf("expected a status for a known backend")
+		}
+		if i < 3 && (changed || !s.Healthy) {
+			t.Fatalf("failure %d: expected backend to stay UP", i)
+		}
+		if i == 3 && (!changed || s.Healthy) {
+			t.Fatalf("failure %d: expected backend to go DOWN", i)
+		}
+	}
+	if p.Healthy() != 0 || p.Acquire(nil) != nil {
+		t.Fatalf("expected no healthy backend")
+	}
+	s, _ := p.RecordCheck("10.0.0.1:6443", nil)
+	if s.LastError != "" {
+		t.Fatalf("expected last error to be cleared, got %q", s.LastError)
+	}
+
+	// A failure resets the count of consecutive successes.
+	_, _ = p.RecordCheck("10.0.0.1:6443", checkErr)
+	s, changed := p.RecordCheck("10.0.0.1:6443", nil)
+	if changed || s.Healthy {
+		t.Fatalf("expected backend to stay DOWN after 1 success")
+	}
+	s, changed = p.RecordCheck("10.0.0.1:6443", nil)
+	if !changed || !s.Healthy {
+		t.Fatalf("expected backend to go UP after 2 successes")
+	}
+
+	s, changed = p.RecordCheck("10.0.0.9:6443", nil)
+	if s != nil || changed {
+		t.Fatalf("expected no status for an unknown backend")
+	}
+}
+
+func TestPoolUpdateDrains(t *testing.T) {
+	p := NewPool(DefaultFall, DefaultRise)
+	p.Update([]Backend{{Name: "a", Addr: "10.0.0.1:6443"}, {Name: "b", Addr: "10.0.0.2:6443"}})
+
+	active := p.Acquire(nil)
+	if active == nil || active.Name != "a" {
+		t.Fatalf("expected backend a, got %v", active)
+	}
+
+	// `a` has an active connection, so it is drained rather than removed.
+	p.Update([]Backend{{Name: "b", Addr: "10.0.0.2:6443"}})
+	statuses := p.Status()
+	if len(statuses) != 2 || !statuses[0].Draining || statuses[0].ActiveConnections != 1 {
+		t.Fatalf("expected backend a to be draining, got %+v", statuses)
+	}
+	if p.Healthy() != 1 {
+		t.Fatalf("expected a draining backend not to count as healthy")
+	}
+	for i := 0; i < 2; i++ {
+		b := p.Acquire(nil)
+		if b == nil || b.Name != "b" {
+			t.Fatalf("expected draining backend to be skipped, got %v", b)
+		}
+		p.Release(b)
+	}
+
+	p.Release(active)
+	if addrs := p.Addrs(); !reflect.DeepEqual(addrs, []string{"10.0.0.2:6443"}) {
+		t.Fatalf("expected drained backend to be removed, got %v", addrs)
+	}
+
+	// A backend with no active connections is removed right away and a
+	// re-added backend is no longer draining.
+	p.Update([]Backend{{Name: "a", Addr: "10.0.0.1:6443"}})
+	statuses = p.Status()
+	if len(statuses) != 1 || statuses[0].Name != "a" || statuses[0].Draining || !statuses[0].Healthy {
+		t.Fatalf("expected only (healthy) backend a, got %+v", statuses)
+	}
+}
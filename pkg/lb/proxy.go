@@ -0,0 +1,88 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lb
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// maxAttempts is the number of backends tried for a single connection
+// (matching `retries 1` and `option redispatch` in our HAProxy
+// configuration).
+const maxAttempts = 2
+
+// handle proxies a single client connection to a backend.
+func handle(ctx context.Context, c Config, pool *Pool, client net.Conn) {
+	defer client.Close()
+
+	tried := map[string]bool{}
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		b := pool.Acquire(tried)
+		if b == nil {
+			cli.Printf(ctx, "No healthy backend available for %s\n", client.RemoteAddr())
+			return
+		}
+		tried[b.Addr] = true
+
+		dialer := net.Dialer{Timeout: c.DialTimeout}
+		server, err := dialer.Dial("tcp", b.Addr)
+		if err != nil {
+			pool.Release(b)
+			cli.Printf(ctx, "Failed to connect to backend %s (%s): %v\n", b.Name, b.Addr, err)
+			continue
+		}
+
+		pipe(client, server)
+		pool.Release(b)
+		return
+	}
+}
+
+// pipe copies data in both directions until either side closes.
+func pipe(client, server net.Conn) {
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// Ignore error: a closed connection is the expected way for a copy
+		//               to finish.
+		_, _ = io.Copy(server, client)
+		closeWrite(server)
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(client, server)
+		closeWrite(client)
+	}()
+	wg.Wait()
+}
+
+// closeWrite half-closes a TCP connection so the other side sees EOF while
+// still being able to send a response.
+func closeWrite(conn net.Conn) {
+	tc, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	// Ignore error: the connection may already be closed.
+	_ = tc.CloseWrite()
+}
@@ -0,0 +1,160 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lb
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// startBackend starts a TCP backend that writes `name` to every connection
+// and then closes it. The listener is closed when the test finishes.
+func startBackend(t *testing.T, name string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = io.WriteString(conn, name)
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// deadBackend returns an address where nothing is listening.
+func deadBackend(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+// proxyOnce runs `handle()` for a single client connection and returns what
+// the client received.
+func proxyOnce(t *testing.T, c Config, pool *Pool) string {
+	t.Helper()
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	// NOTE: A TCP connection is used rather than `net.Pipe()` since `pipe()`
+	//       relies on half-closing the connection to the client.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("failed to accept: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handle(ctx, c, pool, server)
+	}()
+
+	received, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("failed to read from proxy: %v", err)
+	}
+	client.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("handle() did not return")
+	}
+	return string(received)
+}
+
+func TestHandleRedispatch(t *testing.T) {
+	live := startBackend(t, "live")
+	dead := deadBackend(t)
+	pool := NewPool(DefaultFall, DefaultRise)
+	pool.Update([]Backend{{Name: "live", Addr: live}, {Name: "dead", Addr: dead}})
+	c := Config{DialTimeout: time.Second}
+
+	// Round-robin ensures at least one of the two connections tries the
+	// dead backend first; it must be redispatched to the live backend.
+	for i := 0; i < 2; i++ {
+		received := proxyOnce(t, c, pool)
+		if received != "live" {
+			t.Fatalf("connection %d: expected %q, got %q", i, "live", received)
+		}
+	}
+
+	for _, s := range pool.Status() {
+		if s.ActiveConnections != 0 {
+			t.Fatalf("expected no active connections for %s, got %d", s.Name, s.ActiveConnections)
+		}
+		if s.Name == "dead" && s.TotalConnections == 0 {
+			t.Fatalf("expected the dead backend to be tried")
+		}
+	}
+}
+
+func TestHandleRetryLimit(t *testing.T) {
+	pool := NewPool(DefaultFall, DefaultRise)
+	pool.Update([]Backend{
+		{Name: "dead-1", Addr: deadBackend(t)},
+		{Name: "dead-2", Addr: deadBackend(t)},
+		{Name: "dead-3", Addr: deadBackend(t)},
+	})
+	c := Config{DialTimeout: time.Second}
+
+	received := proxyOnce(t, c, pool)
+	if received != "" {
+		t.Fatalf("expected nothing to be received, got %q", received)
+	}
+	attempts := 0
+	for _, s := range pool.Status() {
+		attempts += s.TotalConnections
+	}
+	if attempts != maxAttempts {
+		t.Fatalf("expected %d attempts, got %d", maxAttempts, attempts)
+	}
+}
+
+func TestHandleNoHealthyBackend(t *testing.T) {
+	pool := NewPool(1, 1)
+	addr := startBackend(t, "down")
+	pool.Update([]Backend{{Name: "down", Addr: addr}})
+	pool.RecordCheck(addr, io.EOF)
+	c := Config{DialTimeout: time.Second}
+
+	received := proxyOnce(t, c, pool)
+	if received != "" {
+		t.Fatalf("expected nothing to be received, got %q", received)
+	}
+}
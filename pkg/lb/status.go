@@ -0,0 +1,64 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Status is the response for the status endpoint.
+type Status struct {
+	Listen   string          `json:"listen"`
+	Healthy  int             `json:"healthyBackends"`
+	Backends []BackendStatus `json:"backends"`
+}
+
+// StatusHandler serves the status endpoint:
+//   - `GET /status` returns the state of every backend as JSON
+//   - `GET /healthz` returns a 200 if at least one backend is healthy (and a
+//     503 otherwise)
+func StatusHandler(listen string, pool *Pool) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, _ *http.Request) {
+		s := Status{Listen: listen, Healthy: pool.Healthy(), Backends: pool.Status()}
+		w.Header().Set("Content-Type", "application/json")
+		// Ignore error: failure to write means the client has gone away.
+		_ = json.NewEncoder(w).Encode(s)
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		if pool.Healthy() == 0 {
+			http.Error(w, "no healthy backends", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	return mux
+}
+
+func serveStatus(ctx context.Context, addr string, h http.Handler) (func(context.Context) error, error) {
+	server := &http.Server{Addr: addr, Handler: h}
+	ln, err := listen(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		// Ignore error: `Serve()` always returns an error once `Shutdown()`
+		//               is called.
+		_ = server.Serve(ln)
+	}()
+	return server.Shutdown, nil
+}
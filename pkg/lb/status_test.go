@@ -0,0 +1,60 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatusHandler(t *testing.T) {
+	pool := NewPool(1, 1)
+	pool.Update([]Backend{{Name: "a", Addr: "10.0.0.1:6443"}, {Name: "b", Addr: "10.0.0.2:6443"}})
+	pool.RecordCheck("10.0.0.2:6443", errors.New("connection refused"))
+	h := StatusHandler("100.64.0.1:6443", pool)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	var s Status
+	err := json.Unmarshal(w.Body.Bytes(), &s)
+	if err != nil {
+		t.Fatalf("invalid status response: %v", err)
+	}
+	if s.Listen != "100.64.0.1:6443" || s.Healthy != 1 || len(s.Backends) != 2 {
+		t.Fatalf("unexpected status: %+v", s)
+	}
+	if !s.Backends[0].Healthy || s.Backends[1].Healthy || s.Backends[1].LastError != "connection refused" {
+		t.Fatalf("unexpected backend status: %+v", s.Backends)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+
+	pool.RecordCheck("10.0.0.1:6443", errors.New("connection refused"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d", w.Code)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodedown
+
+import (
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	// DefaultStateFile records completed steps. It is intentionally **not**
+	// in the bootstrap directory since that directory is cleared by the
+	// workflow.
+	DefaultStateFile = "/var/lib/tailsk8s/node-down.json"
+	// DefaultBootstrapDir is the directory containing bootstrap files.
+	DefaultBootstrapDir = "/var/data/tailsk8s-bootstrap"
+)
+
+// Config provides the core set of (CLI) inputs needed to take a node down.
+type Config struct {
+	APIConfig cloud.Config
+	Kubectl   kubernetes.Kubectl
+	// Node is the node (and Tailscale device hostname); defaults to the
+	// local hostname.
+	Node string
+	// CIDR is the subnet advertised by the node; if unset it is read from
+	// the `tailsk8s.io/advertise-subnet` node label.
+	CIDR         string
+	StateFile    string
+	BootstrapDir string
+	// KubeDir is the `~/.kube` directory to remove.
+	KubeDir string
+	// DeleteDevice indicates the Tailscale device should also be removed
+	// from the Tailnet.
+	DeleteDevice    bool
+	SkipDockerPrune bool
+	DryRun          bool
+	// Cluster, Host and Tailnet can be provided to replace the defaults
+	// (`Kubectl`, `LocalHost` and `APITailnet`).
+	Cluster Cluster
+	Host    Host
+	Tailnet Tailnet
+}
+
+// NewConfig returns a new `Config` with all relevant defaults provided and
+// options for overriding.
+func NewConfig(opts ...Option) (Config, error) {
+	ac, err := cloud.NewConfig()
+	if err != nil {
+		return Config{}, err
+	}
+
+	c := Config{
+		APIConfig:    ac,
+		StateFile:    DefaultStateFile,
+		BootstrapDir: DefaultBootstrapDir,
+		KubeDir:      defaultKubeDir(),
+	}
+	for _, opt := range opts {
+		err := opt(&c)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+func (c Config) cluster() Cluster {
+	if c.Cluster != nil {
+		return c.Cluster
+	}
+	return c.Kubectl
+}
+
+func (c Config) host() Host {
+	if c.Host != nil {
+		return c.Host
+	}
+	return LocalHost{}
+}
+
+func (c Config) tailnet() Tailnet {
+	if c.Tailnet != nil {
+		return c.Tailnet
+	}
+	return APITailnet{APIConfig: c.APIConfig}
+}
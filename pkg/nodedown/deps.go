@@ -0,0 +1,58 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodedown
+
+import (
+	"context"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+// Cluster is the set of Kubernetes operations used to take a node down;
+// `kubernetes.Kubectl` satisfies this interface.
+type Cluster interface {
+	// FindNode returns `nil` (and no error) if the node does not exist.
+	FindNode(ctx context.Context, name string) (*kubernetes.Node, error)
+	DrainNode(ctx context.Context, name string) error
+	DeleteNode(ctx context.Context, name string) error
+}
+
+// Host is the set of operations run on the local machine.
+type Host interface {
+	// Run executes an external command (e.g. `kubeadm reset`).
+	Run(ctx context.Context, name string, args ...string) error
+	Exists(path string) (bool, error)
+	// IsEmptyDir returns false if `path` does not exist.
+	IsEmptyDir(path string) (bool, error)
+	RemoveAll(path string) error
+	// ResetDir removes and re-creates a directory; if `uid` and `gid` are
+	// non-negative the directory will be owned by them.
+	ResetDir(path string, uid, gid int) error
+}
+
+// Tailnet is the set of Tailscale operations (both local and cloud API) used
+// to take a node down.
+type Tailnet interface {
+	AdvertisedLocally(ctx context.Context, cidr netaddr.IPPrefix) (bool, error)
+	WithdrawLocal(ctx context.Context, cidr netaddr.IPPrefix) error
+	RouteEnabled(ctx context.Context, hostname string, cidr netaddr.IPPrefix) (bool, error)
+	DisableRoute(ctx context.Context, hostname string, cidr netaddr.IPPrefix) error
+	// FindDevice returns `nil` (and no error) if the device does not exist.
+	FindDevice(ctx context.Context, hostname string) (*cloud.Device, error)
+	DeleteDevice(ctx context.Context, deviceID string) error
+}
@@ -0,0 +1,26 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nodedown removes a node from a `tailsk8s` cluster.
+//
+// The teardown is an ordered workflow (drain, `kubeadm reset`, delete the
+// node, withdraw and disable the route, clean up directories). Completed
+// steps are recorded in a state file so that a failed run can be resumed,
+// and each step also checks whether its work is already done. Every external
+// interaction (Kubernetes, Tailscale and the local host) goes through an
+// interface so that the workflow can be exercised with fakes.
+//
+// This is provided in a way to optimize the testable surface area (even for
+// untested parts of the code) without having any usage of `os.Exit()`.
+package nodedown
@@ -0,0 +1,93 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodedown
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// LocalHost is the default `Host`; it uses the local filesystem and runs
+// commands via a `cli.RunFunc`.
+type LocalHost struct {
+	// RunFunc executes the command; defaults to `cli.ExecRun`.
+	RunFunc cli.RunFunc
+}
+
+// Run executes an external command, discarding STDOUT.
+func (lh LocalHost) Run(ctx context.Context, name string, args ...string) error {
+	run := lh.RunFunc
+	if run == nil {
+		run = cli.ExecRun
+	}
+	_, err := run(ctx, nil, name, args...)
+	return err
+}
+
+// Exists checks if a file or directory exists.
+func (LocalHost) Exists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// IsEmptyDir checks if a directory exists and has no entries.
+func (LocalHost) IsEmptyDir(path string) (bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	_, err = f.Readdirnames(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
+// RemoveAll removes a path and any children (it is not an error if the path
+// does not exist).
+func (LocalHost) RemoveAll(path string) error {
+	return os.RemoveAll(path)
+}
+
+// ResetDir removes and re-creates a directory, optionally changing the owner.
+func (LocalHost) ResetDir(path string, uid, gid int) error {
+	err := os.RemoveAll(path)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path, 0755)
+	if err != nil {
+		return err
+	}
+	if uid < 0 || gid < 0 {
+		return nil
+	}
+	return os.Chown(path, uid, gid)
+}
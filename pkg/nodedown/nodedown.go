@@ -0,0 +1,129 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodedown
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/cni"
+)
+
+// Down runs the teardown workflow for a node. Steps recorded as completed in
+// the state file are skipped, as are steps whose work is already done. The
+// state file is saved after each step and removed once every step has
+// completed, so a failed run can be resumed by running again.
+//
+// In dry-run mode, nothing is changed (and the state file is not written);
+// each step that would run is printed instead.
+func Down(ctx context.Context, c Config) error {
+	node := c.Node
+	if node == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return err
+		}
+		node = hostname
+	}
+
+	state, err := LoadState(c.StateFile, node)
+	if err != nil {
+		return err
+	}
+	if c.CIDR != "" {
+		cidr, err := cni.ParseSubnet(c.CIDR)
+		if err != nil {
+			return err
+		}
+		if state.Subnet != "" && state.Subnet != cidr.String() {
+			return fmt.Errorf("CIDR %s does not match subnet %s in state file %s", cidr, state.Subnet, c.StateFile)
+		}
+		state.Subnet = cidr.String()
+	}
+
+	// NOTE: The API key is resolved up front since it may be stored in the
+	//       bootstrap directory, which is cleared by the workflow.
+	if c.Tailnet == nil {
+		err = c.APIConfig.Resolve(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	w := &workflow{
+		Config:  c,
+		Node:    node,
+		State:   state,
+		Cluster: c.cluster(),
+		Host:    c.host(),
+		Tailnet: c.tailnet(),
+	}
+	for _, step := range w.Steps() {
+		if state.IsCompleted(step.Name) {
+			cli.Printf(ctx, "Skipping step %s (completed in a previous run)\n", step.Name)
+			continue
+		}
+		if step.Done != nil {
+			done, err := step.Done(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to check step %s: %w", step.Name, err)
+			}
+			if done {
+				cli.Printf(ctx, "Skipping step %s (already done)\n", step.Name)
+				err = markCompleted(c, state, step.Name)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if c.DryRun && !step.ReadOnly {
+			cli.Printf(ctx, "Would run step %s: %s\n", step.Name, step.Description)
+			continue
+		}
+
+		cli.Printf(ctx, "Running step %s: %s\n", step.Name, step.Description)
+		err = step.Run(ctx)
+		if err != nil {
+			return fmt.Errorf("step %s failed (re-run to resume): %w", step.Name, err)
+		}
+		err = markCompleted(c, state, step.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.DryRun {
+		return nil
+	}
+	err = RemoveState(c.StateFile)
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "Node %q has been taken down\n", node)
+	return nil
+}
+
+// markCompleted records a completed step and saves the state file (unless
+// in dry-run mode).
+func markCompleted(c Config, state *State, name string) error {
+	state.Completed = append(state.Completed, name)
+	if c.DryRun {
+		return nil
+	}
+	return SaveState(c.StateFile, *state)
+}
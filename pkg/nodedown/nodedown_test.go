@@ -0,0 +1,374 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodedown_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+	"github.com/dhermes/tailsk8s/pkg/nodedown"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	kubeletConfig = "/etc/kubernetes/kubelet.conf"
+	kubeDir       = "/home/ubuntu/.kube"
+	bootstrapDir  = "/var/data/tailsk8s-bootstrap"
+)
+
+// fakeWorld is a stand-in for the cluster, the local host and the Tailnet
+// (i.e. it implements `nodedown.Cluster`, `nodedown.Host` and
+// `nodedown.Tailnet`). Every mutation is recorded in `Mutations`.
+type fakeWorld struct {
+	Nodes map[string]kubernetes.Node
+	// Paths contains the files and directories that exist; the value is
+	// `true` for a non-empty directory.
+	Paths      map[string]bool
+	Advertised map[string]bool
+	Enabled    map[string]bool
+	Devices    map[string]cloud.Device
+	// FailOn causes the (first) mutation with a matching prefix to fail.
+	FailOn    string
+	Mutations []string
+}
+
+func newFakeWorld() *fakeWorld {
+	return &fakeWorld{
+		Nodes: map[string]kubernetes.Node{
+			"node-a": {Metadata: kubernetes.ObjectMeta{
+				Name:   "node-a",
+				Labels: map[string]string{kubernetes.AdvertiseSubnetLabel: kubernetes.EncodeSubnetLabel("10.100.1.0/24")},
+			}},
+		},
+		Paths: map[string]bool{
+			kubeletConfig:     false,
+			kubeDir:           true,
+			"/etc/cni/net.d":  true,
+			"/etc/kubernetes": true,
+			bootstrapDir:      true,
+		},
+		Advertised: map[string]bool{"10.100.1.0/24": true},
+		Enabled:    map[string]bool{"10.100.1.0/24": true},
+		Devices:    map[string]cloud.Device{"node-a": {ID: "dev-a", Hostname: "node-a"}},
+	}
+}
+
+func (fw *fakeWorld) mutate(format string, a ...interface{}) error {
+	m := fmt.Sprintf(format, a...)
+	if fw.FailOn != "" && strings.HasPrefix(m, fw.FailOn) {
+		fw.FailOn = ""
+		return fmt.Errorf("injected failure for %q", m)
+	}
+	fw.Mutations = append(fw.Mutations, m)
+	return nil
+}
+
+func (fw *fakeWorld) FindNode(_ context.Context, name string) (*kubernetes.Node, error) {
+	n, ok := fw.Nodes[name]
+	if !ok {
+		return nil, nil
+	}
+	return &n, nil
+}
+
+func (fw *fakeWorld) DrainNode(_ context.Context, name string) error {
+	return fw.mutate("drain %s", name)
+}
+
+func (fw *fakeWorld) DeleteNode(_ context.Context, name string) error {
+	err := fw.mutate("delete-node %s", name)
+	if err != nil {
+		return err
+	}
+	delete(fw.Nodes, name)
+	return nil
+}
+
+func (fw *fakeWorld) Run(_ context.Context, name string, args ...string) error {
+	command := strings.Join(append([]string{name}, args...), " ")
+	err := fw.mutate("run %s", command)
+	if err != nil {
+		return err
+	}
+	if command == "kubeadm reset --force" {
+		delete(fw.Paths, kubeletConfig)
+	}
+	return nil
+}
+
+func (fw *fakeWorld) Exists(path string) (bool, error) {
+	_, ok := fw.Paths[path]
+	return ok, nil
+}
+
+func (fw *fakeWorld) IsEmptyDir(path string) (bool, error) {
+	nonEmpty, ok := fw.Paths[path]
+	return ok && !nonEmpty, nil
+}
+
+func (fw *fakeWorld) RemoveAll(path string) error {
+	err := fw.mutate("remove %s", path)
+	if err != nil {
+		return err
+	}
+	delete(fw.Paths, path)
+	return nil
+}
+
+func (fw *fakeWorld) ResetDir(path string, _, _ int) error {
+	err := fw.mutate("reset-dir %s", path)
+	if err != nil {
+		return err
+	}
+	fw.Paths[path] = false
+	return nil
+}
+
+func (fw *fakeWorld) AdvertisedLocally(_ context.Context, cidr netaddr.IPPrefix) (bool, error) {
+	return fw.Advertised[cidr.String()], nil
+}
+
+func (fw *fakeWorld) WithdrawLocal(_ context.Context, cidr netaddr.IPPrefix) error {
+	err := fw.mutate("withdraw-local %s", cidr)
+	if err != nil {
+		return err
+	}
+	delete(fw.Advertised, cidr.String())
+	return nil
+}
+
+func (fw *fakeWorld) RouteEnabled(_ context.Context, _ string, cidr netaddr.IPPrefix) (bool, error) {
+	return fw.Enabled[cidr.String()], nil
+}
+
+func (fw *fakeWorld) DisableRoute(_ context.Context, hostname string, cidr netaddr.IPPrefix) error {
+	err := fw.mutate("disable-route %s %s", hostname, cidr)
+	if err != nil {
+		return err
+	}
+	delete(fw.Enabled, cidr.String())
+	return nil
+}
+
+func (fw *fakeWorld) FindDevice(_ context.Context, hostname string) (*cloud.Device, error) {
+	d, ok := fw.Devices[hostname]
+	if !ok {
+		return nil, nil
+	}
+	return &d, nil
+}
+
+func (fw *fakeWorld) DeleteDevice(_ context.Context, deviceID string) error {
+	err := fw.mutate("delete-device %s", deviceID)
+	if err != nil {
+		return err
+	}
+	for hostname, d := range fw.Devices {
+		if d.ID == deviceID {
+			delete(fw.Devices, hostname)
+		}
+	}
+	return nil
+}
+
+func newConfig(t *testing.T, fw *fakeWorld) nodedown.Config {
+	t.Helper()
+	c, err := nodedown.NewConfig()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c.Node = "node-a"
+	c.StateFile = filepath.Join(t.TempDir(), "node-down.json")
+	c.BootstrapDir = bootstrapDir
+	c.KubeDir = kubeDir
+	c.Cluster = fw
+	c.Host = fw
+	c.Tailnet = fw
+	return c
+}
+
+func down(c nodedown.Config) error {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	return nodedown.Down(ctx, c)
+}
+
+func assertMutations(t *testing.T, fw *fakeWorld, expected ...string) {
+	t.Helper()
+	if len(expected) == 0 && len(fw.Mutations) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(fw.Mutations, expected) {
+		t.Fatalf("expected mutations:\n  %s\ngot:\n  %s", strings.Join(expected, "\n  "), strings.Join(fw.Mutations, "\n  "))
+	}
+}
+
+func assertNoStateFile(t *testing.T, c nodedown.Config) {
+	t.Helper()
+	_, err := os.Stat(c.StateFile)
+	if !os.IsNotExist(err) {
+		t.Fatalf("expected no state file, got %v", err)
+	}
+}
+
+func TestDownStepOrder(t *testing.T) {
+	fw := newFakeWorld()
+	c := newConfig(t, fw)
+	c.DeleteDevice = true
+
+	err := down(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertMutations(
+		t, fw,
+		"drain node-a",
+		"run systemctl disable --now kubelet",
+		"run kubeadm reset --force",
+		"delete-node node-a",
+		"withdraw-local 10.100.1.0/24",
+		"disable-route node-a 10.100.1.0/24",
+		"delete-device dev-a",
+		"remove "+kubeDir,
+		"remove /etc/cni/net.d",
+		"remove /etc/kubernetes",
+		"reset-dir "+bootstrapDir,
+		"run docker system prune --force",
+		"run docker volume prune --force",
+	)
+	assertNoStateFile(t, c)
+}
+
+func TestDownResume(t *testing.T) {
+	fw := newFakeWorld()
+	fw.FailOn = "delete-node"
+	c := newConfig(t, fw)
+	c.SkipDockerPrune = true
+
+	err := down(c)
+	if err == nil || !strings.Contains(err.Error(), "step delete-node failed (re-run to resume)") {
+		t.Fatalf("expected delete-node failure, got %v", err)
+	}
+	state, err := nodedown.LoadState(c.StateFile, "node-a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := nodedown.State{Node: "node-a", Subnet: "10.100.1.0/24", Completed: []string{"read-subnet", "drain", "kubeadm-reset"}}
+	if !reflect.DeepEqual(*state, expected) {
+		t.Fatalf("expected state %+v, got %+v", expected, *state)
+	}
+
+	// NOTE: Make `drain` and `kubeadm-reset` **not** done so that skipping
+	//       them on the re-run can only be due to the state file.
+	fw.Paths[kubeletConfig] = false
+	fw.Mutations = nil
+	err = down(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertMutations(
+		t, fw,
+		"delete-node node-a",
+		"withdraw-local 10.100.1.0/24",
+		"disable-route node-a 10.100.1.0/24",
+		"remove "+kubeDir,
+		"remove /etc/cni/net.d",
+		"remove /etc/kubernetes",
+		"reset-dir "+bootstrapDir,
+	)
+	assertNoStateFile(t, c)
+}
+
+func TestDownSkipsDoneSteps(t *testing.T) {
+	// The node was deleted (and `kubeadm reset` run) by hand, so the subnet
+	// must be provided and only the remaining steps run.
+	fw := newFakeWorld()
+	delete(fw.Nodes, "node-a")
+	delete(fw.Paths, kubeletConfig)
+	delete(fw.Enabled, "10.100.1.0/24")
+	fw.Paths[bootstrapDir] = false
+	c := newConfig(t, fw)
+	c.CIDR = "10.100.1.0/24"
+	c.SkipDockerPrune = true
+
+	err := down(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertMutations(
+		t, fw,
+		"withdraw-local 10.100.1.0/24",
+		"remove "+kubeDir,
+		"remove /etc/cni/net.d",
+		"remove /etc/kubernetes",
+	)
+}
+
+func TestDownDryRun(t *testing.T) {
+	fw := newFakeWorld()
+	c := newConfig(t, fw)
+	c.DeleteDevice = true
+	c.DryRun = true
+
+	err := down(c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertMutations(t, fw)
+	assertNoStateFile(t, c)
+	if _, ok := fw.Nodes["node-a"]; !ok {
+		t.Fatal("expected node to still exist")
+	}
+}
+
+func TestDownStateFileOtherNode(t *testing.T) {
+	fw := newFakeWorld()
+	c := newConfig(t, fw)
+	err := nodedown.SaveState(c.StateFile, nodedown.State{Node: "node-b", Completed: []string{"read-subnet"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = down(c)
+	if err == nil || !strings.Contains(err.Error(), `is for node "node-b", not "node-a"`) {
+		t.Fatalf("expected state file to be rejected, got %v", err)
+	}
+	assertMutations(t, fw)
+}
+
+func TestDownCIDRConflict(t *testing.T) {
+	fw := newFakeWorld()
+	c := newConfig(t, fw)
+	c.CIDR = "10.100.2.0/24"
+	err := nodedown.SaveState(c.StateFile, nodedown.State{Node: "node-a", Subnet: "10.100.1.0/24", Completed: []string{"read-subnet"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err = down(c)
+	if err == nil || !strings.Contains(err.Error(), "CIDR 10.100.2.0/24 does not match subnet 10.100.1.0/24") {
+		t.Fatalf("expected CIDR conflict, got %v", err)
+	}
+	assertMutations(t, fw)
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodedown
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"tailscale.com/atomicfile"
+)
+
+// State records the progress of a teardown so that it can be resumed.
+type State struct {
+	Node string `json:"node"`
+	// Subnet is the (IPv4) CIDR advertised by the node; it is recorded
+	// because the node label it is read from is gone once the node is
+	// deleted.
+	Subnet    string   `json:"subnet,omitempty"`
+	Completed []string `json:"completed"`
+}
+
+// IsCompleted checks if a step has already been recorded as completed.
+func (s State) IsCompleted(name string) bool {
+	for _, c := range s.Completed {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadState reads the state file; if it does not exist, a fresh state is
+// returned. It is an error if the state file is for a different node.
+func LoadState(filename, node string) (*State, error) {
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return &State{Node: node, Completed: []string{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s State
+	err = json.Unmarshal(content, &s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", filename, err)
+	}
+	if s.Node != node {
+		return nil, fmt.Errorf("state file %s is for node %q, not %q", filename, s.Node, node)
+	}
+	if s.Completed == nil {
+		s.Completed = []string{}
+	}
+	return &s, nil
+}
+
+// SaveState atomically writes the state file.
+func SaveState(filename string, s State) error {
+	asJSON, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filename, append(asJSON, '\n'), 0600)
+}
+
+// RemoveState removes the state file (it is not an error if it does not
+// exist).
+func RemoveState(filename string) error {
+	err := os.Remove(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,289 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodedown
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/cni"
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+)
+
+const (
+	// kubeletConfig is removed by `kubeadm reset`.
+	kubeletConfig = "/etc/kubernetes/kubelet.conf"
+)
+
+// Step is a single step in the teardown workflow.
+type Step struct {
+	Name        string
+	Description string
+	// ReadOnly steps make no changes, so they are run even in dry-run mode.
+	ReadOnly bool
+	// Done checks if the work for the step has already been done, e.g. by
+	// hand or by a previous run that failed before the state file was
+	// saved. It may be `nil`.
+	Done func(ctx context.Context) (bool, error)
+	Run  func(ctx context.Context) error
+}
+
+// workflow holds the resolved inputs shared by all steps.
+type workflow struct {
+	Config  Config
+	Node    string
+	State   *State
+	Cluster Cluster
+	Host    Host
+	Tailnet Tailnet
+}
+
+// Steps returns the ordered teardown steps. The order matches the original
+// `k8s-node-down.sh` script, with the cloud route and device steps added.
+func (w *workflow) Steps() []Step {
+	steps := []Step{
+		{
+			Name:        "read-subnet",
+			Description: "Read the subnet advertised by the node",
+			ReadOnly:    true,
+			Done:        w.hasSubnet,
+			Run:         w.readSubnet,
+		},
+		{
+			Name:        "drain",
+			Description: fmt.Sprintf("Drain node %q", w.Node),
+			Done:        w.nodeGone,
+			Run: func(ctx context.Context) error {
+				return w.Cluster.DrainNode(ctx, w.Node)
+			},
+		},
+		{
+			Name:        "kubeadm-reset",
+			Description: "Disable the kubelet and run `kubeadm reset`",
+			Done: func(_ context.Context) (bool, error) {
+				exists, err := w.Host.Exists(kubeletConfig)
+				return !exists, err
+			},
+			Run: w.kubeadmReset,
+		},
+		{
+			Name:        "delete-node",
+			Description: fmt.Sprintf("Delete node %q from the cluster", w.Node),
+			Done:        w.nodeGone,
+			Run: func(ctx context.Context) error {
+				return w.Cluster.DeleteNode(ctx, w.Node)
+			},
+		},
+		{
+			Name:        "withdraw-local",
+			Description: "Withdraw the advertised route from the local Tailscale preferences",
+			Done: func(ctx context.Context) (bool, error) {
+				cidr, err := w.subnet()
+				if err != nil {
+					return false, err
+				}
+				advertised, err := w.Tailnet.AdvertisedLocally(ctx, cidr)
+				return !advertised, err
+			},
+			Run: func(ctx context.Context) error {
+				cidr, err := w.subnet()
+				if err != nil {
+					return err
+				}
+				return w.Tailnet.WithdrawLocal(ctx, cidr)
+			},
+		},
+		{
+			Name:        "disable-route",
+			Description: "Disable the route for the device in the Tailscale cloud API",
+			Done: func(ctx context.Context) (bool, error) {
+				cidr, err := w.subnet()
+				if err != nil {
+					return false, err
+				}
+				enabled, err := w.Tailnet.RouteEnabled(ctx, w.Node, cidr)
+				return !enabled, err
+			},
+			Run: func(ctx context.Context) error {
+				cidr, err := w.subnet()
+				if err != nil {
+					return err
+				}
+				return w.Tailnet.DisableRoute(ctx, w.Node, cidr)
+			},
+		},
+	}
+
+	if w.Config.DeleteDevice {
+		steps = append(steps, Step{
+			Name:        "delete-device",
+			Description: fmt.Sprintf("Delete device %q from the Tailnet", w.Node),
+			Done: func(ctx context.Context) (bool, error) {
+				device, err := w.Tailnet.FindDevice(ctx, w.Node)
+				return device == nil, err
+			},
+			Run: w.deleteDevice,
+		})
+	}
+
+	steps = append(steps,
+		Step{
+			Name:        "remove-directories",
+			Description: "Remove Kubernetes configuration directories",
+			Done: func(_ context.Context) (bool, error) {
+				for _, dir := range w.directories() {
+					exists, err := w.Host.Exists(dir)
+					if err != nil || exists {
+						return false, err
+					}
+				}
+				return true, nil
+			},
+			Run: w.removeDirectories,
+		},
+		Step{
+			Name:        "reset-bootstrap-dir",
+			Description: fmt.Sprintf("Clear the bootstrap directory %s", w.Config.BootstrapDir),
+			Done: func(_ context.Context) (bool, error) {
+				return w.Host.IsEmptyDir(w.Config.BootstrapDir)
+			},
+			Run: func(_ context.Context) error {
+				uid, gid := cli.SudoOwner()
+				return w.Host.ResetDir(w.Config.BootstrapDir, uid, gid)
+			},
+		},
+	)
+
+	if !w.Config.SkipDockerPrune {
+		steps = append(steps, Step{
+			Name:        "docker-prune",
+			Description: "Clean up any leftover Docker data",
+			Run:         w.dockerPrune,
+		})
+	}
+
+	return steps
+}
+
+func (w *workflow) hasSubnet(_ context.Context) (bool, error) {
+	return w.State.Subnet != "", nil
+}
+
+func (w *workflow) readSubnet(ctx context.Context) error {
+	node, err := w.Cluster.FindNode(ctx, w.Node)
+	if err != nil {
+		return err
+	}
+	if node == nil {
+		return fmt.Errorf("node %q does not exist so the advertised subnet can't be determined; use --cidr", w.Node)
+	}
+
+	value := node.AdvertiseSubnet()
+	if value == "" {
+		return fmt.Errorf("node %q has no %s label; use --cidr", w.Node, kubernetes.AdvertiseSubnetLabel)
+	}
+	cidr, err := cni.ParseSubnet(value)
+	if err != nil {
+		return err
+	}
+	w.State.Subnet = cidr.String()
+	cli.Printf(ctx, "Node %q advertises subnet %s\n", w.Node, w.State.Subnet)
+	return nil
+}
+
+func (w *workflow) subnet() (netaddr.IPPrefix, error) {
+	return cni.ParseSubnet(w.State.Subnet)
+}
+
+func (w *workflow) nodeGone(ctx context.Context) (bool, error) {
+	node, err := w.Cluster.FindNode(ctx, w.Node)
+	return node == nil, err
+}
+
+func (w *workflow) kubeadmReset(ctx context.Context) error {
+	err := w.Host.Run(ctx, "systemctl", "disable", "--now", "kubelet")
+	if err != nil {
+		return err
+	}
+	return w.Host.Run(ctx, "kubeadm", "reset", "--force")
+}
+
+func (w *workflow) deleteDevice(ctx context.Context) error {
+	device, err := w.Tailnet.FindDevice(ctx, w.Node)
+	if err != nil {
+		return err
+	}
+	if device == nil {
+		return nil
+	}
+	err = w.Tailnet.DeleteDevice(ctx, device.ID)
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "Deleted device %s (%s)\n", device.ID, device.Hostname)
+	return nil
+}
+
+// directories returns the Kubernetes configuration directories to remove.
+func (w *workflow) directories() []string {
+	dirs := []string{}
+	if w.Config.KubeDir != "" {
+		dirs = append(dirs, w.Config.KubeDir)
+	}
+	return append(dirs, "/etc/cni/net.d", "/etc/kubernetes")
+}
+
+func (w *workflow) removeDirectories(ctx context.Context) error {
+	for _, dir := range w.directories() {
+		err := w.Host.RemoveAll(dir)
+		if err != nil {
+			return err
+		}
+		cli.Printf(ctx, "Removed %s\n", dir)
+	}
+	return nil
+}
+
+func (w *workflow) dockerPrune(ctx context.Context) error {
+	err := w.Host.Run(ctx, "docker", "system", "prune", "--force")
+	if err != nil {
+		return err
+	}
+	return w.Host.Run(ctx, "docker", "volume", "prune", "--force")
+}
+
+// defaultKubeDir returns `~/.kube` for the user that invoked `sudo` (or the
+// current user if not running via `sudo`).
+func defaultKubeDir() string {
+	sudoUser := os.Getenv("SUDO_USER")
+	if sudoUser != "" {
+		u, err := user.Lookup(sudoUser)
+		if err == nil {
+			return filepath.Join(u.HomeDir, ".kube")
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kube")
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodedown
+
+import (
+	"context"
+	"fmt"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/advertise"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/withdraw"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+// APITailnet is the default `Tailnet`; it uses the local `tailscaled` API
+// for preferences and the Tailscale cloud API for devices and routes.
+type APITailnet struct {
+	APIConfig cloud.Config
+}
+
+// AdvertisedLocally checks if the local preferences advertise `cidr`.
+func (at APITailnet) AdvertisedLocally(ctx context.Context, cidr netaddr.IPPrefix) (bool, error) {
+	prefs, err := local.GetPrefs(ctx)
+	if err != nil {
+		return false, err
+	}
+	return advertise.IPPrefixesContain(prefs.AdvertiseRoutes, cidr), nil
+}
+
+// WithdrawLocal removes `cidr` from the local advertised routes.
+func (at APITailnet) WithdrawLocal(ctx context.Context, cidr netaddr.IPPrefix) error {
+	_, err := withdraw.EditPrefsWithdrawCIDR(ctx, cidr)
+	return err
+}
+
+// RouteEnabled checks if `cidr` is enabled for the device. If the device
+// no longer exists, the route is not enabled.
+func (at APITailnet) RouteEnabled(ctx context.Context, hostname string, cidr netaddr.IPPrefix) (bool, error) {
+	device, err := at.FindDevice(ctx, hostname)
+	if err != nil {
+		return false, err
+	}
+	if device == nil {
+		return false, nil
+	}
+
+	grr := cloud.GetRoutesRequest{DeviceID: device.ID}
+	rr, err := cloud.GetRoutes(ctx, at.APIConfig, grr)
+	if err != nil {
+		return false, err
+	}
+	return advertise.RoutesContain(rr.EnabledRoutes, cidr), nil
+}
+
+// DisableRoute removes `cidr` from the enabled routes for the device.
+func (at APITailnet) DisableRoute(ctx context.Context, hostname string, cidr netaddr.IPPrefix) error {
+	return withdraw.DisableWithdrawnCIDR(ctx, at.APIConfig, cidr, hostname)
+}
+
+// FindDevice looks up a device by hostname (or by machine name, in the same
+// way as `remix.GetDeviceByHostname()`) but returns `nil` if there is no
+// match.
+func (at APITailnet) FindDevice(ctx context.Context, hostname string) (*cloud.Device, error) {
+	devices, err := cloud.GetDevices(ctx, at.APIConfig, cloud.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	deviceName := fmt.Sprintf("%s.%s", hostname, at.APIConfig.Tailnet)
+	matches := []cloud.Device{}
+	for _, device := range devices.Devices {
+		if device.Hostname == hostname || device.Name == deviceName {
+			matches = append(matches, device)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	if len(matches) > 1 {
+		return nil, fmt.Errorf("could not find unique device matching hostname %q (%d matches)", hostname, len(matches))
+	}
+	return &matches[0], nil
+}
+
+// DeleteDevice removes a device from the Tailnet.
+func (at APITailnet) DeleteDevice(ctx context.Context, deviceID string) error {
+	ddr := cloud.DeleteDeviceRequest{DeviceID: deviceID}
+	_, err := cloud.DeleteDevice(ctx, at.APIConfig, ddr)
+	return err
+}
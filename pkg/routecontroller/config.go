@@ -0,0 +1,95 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routecontroller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	// DefaultInterval is the time between reconcile passes.
+	DefaultInterval = 30 * time.Second
+	// DefaultStateFile records the nodes managed by the controller so that
+	// routes for nodes deleted while the controller is down are still
+	// withdrawn.
+	DefaultStateFile = "/var/lib/tailsk8s/route-controller.json"
+	// Component is the event source component for emitted events.
+	Component = "tailsk8s-route-controller"
+
+	// SourceAuto uses the `tailsk8s.io/advertise-subnet` label if set and
+	// falls back to `spec.podCIDRs`.
+	SourceAuto = "auto"
+	// SourceLabel only uses the `tailsk8s.io/advertise-subnet` label.
+	SourceLabel = "label"
+	// SourcePodCIDRs only uses `spec.podCIDRs` (or `spec.podCIDR`).
+	SourcePodCIDRs = "pod-cidrs"
+)
+
+// Config provides the core set of (CLI) inputs needed to run the route
+// controller.
+type Config struct {
+	APIConfig cloud.Config
+	Kubectl   kubernetes.Kubectl
+	// PodSubnet is the cluster `podSubnet`; only routes within this subnet
+	// are managed by the controller.
+	PodSubnet string
+	// Source determines where a node's subnet is read from.
+	Source   string
+	Interval time.Duration
+	// Once indicates a single reconcile pass should be run.
+	Once bool
+	// DryRun indicates changes should be printed but not made.
+	DryRun bool
+	// SkipEvents disables emitting Kubernetes events.
+	SkipEvents bool
+	// StateFile records the managed nodes across restarts; if empty, they
+	// are only tracked in memory.
+	StateFile string
+}
+
+// NewConfig returns a new `Config` with all relevant defaults provided and
+// options for overriding.
+func NewConfig(opts ...Option) (Config, error) {
+	ac, err := cloud.NewConfig()
+	if err != nil {
+		return Config{}, err
+	}
+
+	c := Config{
+		APIConfig: ac,
+		Source:    SourceAuto,
+		Interval:  DefaultInterval,
+		StateFile: DefaultStateFile,
+	}
+	for _, opt := range opts {
+		err := opt(&c)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+func validateSource(source string) error {
+	switch source {
+	case SourceAuto, SourceLabel, SourcePodCIDRs:
+		return nil
+	}
+	return fmt.Errorf("unknown subnet source %q, expected %q, %q or %q", source, SourceAuto, SourceLabel, SourcePodCIDRs)
+}
@@ -0,0 +1,242 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routecontroller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/cni"
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/remix"
+)
+
+// Run runs the controller until `ctx` is cancelled (or runs a single pass if
+// `Once` is set). Errors during a pass are printed and retried on the next
+// pass rather than stopping the controller.
+func Run(ctx context.Context, c Config) error {
+	pool, err := cni.ParseSubnet(c.PodSubnet)
+	if err != nil {
+		return err
+	}
+	err = validateSource(c.Source)
+	if err != nil {
+		return err
+	}
+	err = c.APIConfig.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	state, err := LoadState(c.StateFile)
+	if err != nil {
+		return err
+	}
+	if c.Once {
+		return Reconcile(ctx, c, pool, state)
+	}
+
+	cli.Printf(ctx, "Reconciling routes in %s every %s\n", pool, c.Interval)
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		err = Reconcile(ctx, c, pool, state)
+		if err != nil {
+			cli.Printf(ctx, "Reconcile failed: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			cli.Println(ctx, "Stopping route controller")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile runs a single pass: it enables the subnet of every node on the
+// device with a matching hostname. Routes within `pool` are disabled for
+// managed nodes (see `State`) that no longer have a subnet or have been
+// deleted from the cluster. A failure for one node does not stop the pass;
+// all failures are printed and an aggregated error is returned.
+//
+// If `c.StateFile` is set, the updated `state` is saved to it after the pass
+// (unless `c.DryRun` is set).
+func Reconcile(ctx context.Context, c Config, pool netaddr.IPPrefix, state *State) error {
+	before := state.Clone()
+	nodes, err := c.Kubectl.GetNodes(ctx)
+	if err != nil {
+		return err
+	}
+	devices, err := cloud.GetDevices(ctx, c.APIConfig, cloud.Empty{})
+	if err != nil {
+		return err
+	}
+
+	byHostname := map[string][]cloud.Device{}
+	for _, device := range devices.Devices {
+		byHostname[device.Hostname] = append(byHostname[device.Hostname], device)
+	}
+
+	failures := []string{}
+	fail := func(name string, err error) {
+		cli.Printf(ctx, "Failed to reconcile node %q: %v\n", name, err)
+		failures = append(failures, fmt.Sprintf("node %q: %v", name, err))
+	}
+
+	nodeNames := map[string]bool{}
+	for _, n := range nodes {
+		name := n.Metadata.Name
+		nodeNames[name] = true
+		desired, err := NodeSubnets(n, c.Source)
+		if err != nil {
+			cli.Printf(ctx, "Skipping node %q: %v\n", name, err)
+			continue
+		}
+		if len(desired) == 0 && !state.Managed[name] {
+			cli.Printf(ctx, "Skipping node %q: no subnet has been assigned yet\n", name)
+			continue
+		}
+		desired = withinPool(ctx, name, desired, pool)
+
+		matches := byHostname[name]
+		if len(matches) != 1 {
+			cli.Printf(ctx, "Skipping node %q: could not find unique device (%d matches)\n", name, len(matches))
+			continue
+		}
+		err = reconcileDevice(ctx, c, n, matches[0], desired, pool)
+		if err != nil {
+			fail(name, err)
+			continue
+		}
+		if len(desired) > 0 {
+			state.Managed[name] = true
+		} else {
+			delete(state.Managed, name)
+		}
+	}
+
+	// Disable routes for managed nodes that have been deleted from the
+	// cluster. Devices for hostnames that have never been seen as a node
+	// with a subnet are left alone.
+	for name := range state.Managed {
+		if nodeNames[name] {
+			continue
+		}
+		n := kubernetes.Node{Metadata: kubernetes.ObjectMeta{Name: name}}
+		withdrawn := true
+		for _, device := range byHostname[name] {
+			err = reconcileDevice(ctx, c, n, device, nil, pool)
+			if err != nil {
+				fail(name, err)
+				withdrawn = false
+			}
+		}
+		if withdrawn {
+			delete(state.Managed, name)
+		}
+	}
+
+	var saveErr error
+	if c.StateFile != "" && !c.DryRun && !state.Equal(before) {
+		saveErr = SaveState(c.StateFile, *state)
+		if saveErr != nil {
+			saveErr = fmt.Errorf("failed to save state file %s: %w", c.StateFile, saveErr)
+			cli.Printf(ctx, "%v\n", saveErr)
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to reconcile %d node(s): %s", len(failures), strings.Join(failures, "; "))
+	}
+	return saveErr
+}
+
+func reconcileDevice(ctx context.Context, c Config, n kubernetes.Node, device cloud.Device, desired []netaddr.IPPrefix, pool netaddr.IPPrefix) error {
+	grr := cloud.GetRoutesRequest{DeviceID: device.ID}
+	rr, err := cloud.GetRoutes(ctx, c.APIConfig, grr)
+	if err != nil {
+		return err
+	}
+
+	rc, err := ComputeRouteChange(rr.EnabledRoutes, rr.AdvertisedRoutes, desired, pool)
+	if err != nil {
+		return fmt.Errorf("invalid routes for device %s: %w", device.ID, err)
+	}
+	for _, route := range rc.NotAdvertised {
+		cli.Printf(ctx, "Route %s for node %q is not yet advertised by device %s\n", route, n.Metadata.Name, device.ID)
+	}
+	if !rc.Changed() {
+		return nil
+	}
+
+	message := describeChange(rc, device)
+	if c.DryRun {
+		cli.Printf(ctx, "Would update routes for node %q: %s\n", n.Metadata.Name, message)
+		return nil
+	}
+
+	urr := remix.UpdateRoutesRequest{DeviceID: device.ID, Enable: rc.Enabled, Disable: rc.Disabled}
+	_, err = remix.UpdateRoutes(ctx, c.APIConfig, urr)
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "Updated routes for node %q: %s\n", n.Metadata.Name, message)
+
+	if c.SkipEvents {
+		return nil
+	}
+	reason := "TailscaleRoutesEnabled"
+	if len(rc.Enabled) == 0 {
+		reason = "TailscaleRoutesDisabled"
+	}
+	e := kubernetes.NodeEvent(n, Component, kubernetes.EventTypeNormal, reason, message, time.Now())
+	err = c.Kubectl.CreateEvent(ctx, e)
+	if err != nil {
+		// Failing to emit an event should not fail the pass; the routes have
+		// already been updated.
+		cli.Printf(ctx, "Failed to emit event for node %q: %v\n", n.Metadata.Name, err)
+	}
+	return nil
+}
+
+func withinPool(ctx context.Context, node string, subnets []netaddr.IPPrefix, pool netaddr.IPPrefix) []netaddr.IPPrefix {
+	within := []netaddr.IPPrefix{}
+	for _, s := range subnets {
+		if !Managed(s, pool) {
+			cli.Printf(ctx, "Ignoring subnet %s for node %q, it is outside of pod subnet %s\n", s, node, pool)
+			continue
+		}
+		within = append(within, s)
+	}
+	return within
+}
+
+func describeChange(rc RouteChange, device cloud.Device) string {
+	parts := []string{}
+	if len(rc.Enabled) > 0 {
+		parts = append(parts, "enabled "+strings.Join(rc.Enabled, ", "))
+	}
+	if len(rc.Disabled) > 0 {
+		parts = append(parts, "disabled "+strings.Join(rc.Disabled, ", "))
+	}
+	return fmt.Sprintf("%s for device %s (%s)", strings.Join(parts, "; "), device.ID, device.Hostname)
+}
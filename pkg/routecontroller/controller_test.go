@@ -0,0 +1,294 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routecontroller_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+	"github.com/dhermes/tailsk8s/pkg/routecontroller"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/cloudtest"
+)
+
+var pool = netaddr.MustParseIPPrefix("10.100.0.0/16")
+
+// fakeNodes is a stand-in for `kubectl get nodes` and
+// `kubectl create --filename -` (for events).
+type fakeNodes struct {
+	mu     sync.Mutex
+	nodes  []kubernetes.Node
+	events []kubernetes.Event
+}
+
+func (fn *fakeNodes) Set(nodes ...kubernetes.Node) {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	fn.nodes = nodes
+}
+
+func (fn *fakeNodes) Events() []kubernetes.Event {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	return append([]kubernetes.Event{}, fn.events...)
+}
+
+func (fn *fakeNodes) Run(_ context.Context, stdin []byte, _ string, args ...string) ([]byte, error) {
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+	switch strings.Join(args, " ") {
+	case "get nodes --output json":
+		return json.Marshal(kubernetes.NodeList{Items: fn.nodes})
+	case "create --filename -":
+		var e kubernetes.Event
+		err := json.Unmarshal(stdin, &e)
+		if err != nil {
+			return nil, err
+		}
+		fn.events = append(fn.events, e)
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unexpected kubectl command: %q", args)
+}
+
+func labeledNode(name, subnet string) kubernetes.Node {
+	n := kubernetes.Node{Metadata: kubernetes.ObjectMeta{Name: name}}
+	if subnet != "" {
+		n.Metadata.Labels = map[string]string{
+			kubernetes.AdvertiseSubnetLabel: kubernetes.EncodeSubnetLabel(subnet),
+		}
+	}
+	return n
+}
+
+func newController(t *testing.T, source string) (*cloudtest.Server, *fakeNodes, routecontroller.Config) {
+	t.Helper()
+	server := cloudtest.NewServer()
+	t.Cleanup(server.Close)
+	fn := &fakeNodes{}
+	c := routecontroller.Config{
+		APIConfig:  server.Config(),
+		Kubectl:    kubernetes.Kubectl{Run: fn.Run},
+		PodSubnet:  pool.String(),
+		Source:     source,
+		SkipEvents: true,
+	}
+	return server, fn, c
+}
+
+func addDevice(server *cloudtest.Server, id, hostname string, advertised, enabled []string) {
+	server.AddDevice(cloudtest.Device{
+		Device:           cloud.Device{ID: id, Hostname: hostname, Authorized: true},
+		AdvertisedRoutes: advertised,
+		EnabledRoutes:    enabled,
+	})
+}
+
+func assertEnabled(t *testing.T, server *cloudtest.Server, id string, expected ...string) {
+	t.Helper()
+	d, ok := server.Device(id)
+	if !ok {
+		t.Fatalf("device %s does not exist", id)
+	}
+	if len(expected) == 0 && len(d.EnabledRoutes) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(d.EnabledRoutes, expected) {
+		t.Fatalf("device %s: expected enabled routes %v, got %v", id, expected, d.EnabledRoutes)
+	}
+}
+
+func reconcile(t *testing.T, c routecontroller.Config, state *routecontroller.State) {
+	t.Helper()
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	err := routecontroller.Reconcile(ctx, c, pool, state)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestReconcileNodeAdded(t *testing.T) {
+	server, fn, c := newController(t, routecontroller.SourceAuto)
+	addDevice(server, "dev-a", "node-a", []string{"10.100.1.0/24"}, nil)
+	state := routecontroller.NewState()
+
+	reconcile(t, c, state)
+	assertEnabled(t, server, "dev-a")
+
+	fn.Set(labeledNode("node-a", "10.100.1.0/24"))
+	reconcile(t, c, state)
+	assertEnabled(t, server, "dev-a", "10.100.1.0/24")
+}
+
+func TestReconcileNodeDeleted(t *testing.T) {
+	server, fn, c := newController(t, routecontroller.SourceAuto)
+	addDevice(server, "dev-a", "node-a", []string{"10.100.1.0/24", "0.0.0.0/0"}, []string{"0.0.0.0/0"})
+	addDevice(server, "dev-b", "node-b", []string{"10.100.2.0/24"}, nil)
+	fn.Set(labeledNode("node-a", "10.100.1.0/24"), labeledNode("node-b", "10.100.2.0/24"))
+	state := routecontroller.NewState()
+
+	reconcile(t, c, state)
+	assertEnabled(t, server, "dev-a", "0.0.0.0/0", "10.100.1.0/24")
+	assertEnabled(t, server, "dev-b", "10.100.2.0/24")
+
+	fn.Set(labeledNode("node-b", "10.100.2.0/24"))
+	reconcile(t, c, state)
+	// NOTE: Routes outside of the pod subnet (e.g. an exit node) are never
+	//       disabled by the controller.
+	assertEnabled(t, server, "dev-a", "0.0.0.0/0")
+	assertEnabled(t, server, "dev-b", "10.100.2.0/24")
+	if state.Managed["node-a"] {
+		t.Fatalf("expected deleted node to no longer be managed")
+	}
+}
+
+func TestReconcileNodeUnlabeled(t *testing.T) {
+	server, fn, c := newController(t, routecontroller.SourceLabel)
+	addDevice(server, "dev-a", "node-a", []string{"10.100.1.0/24"}, nil)
+	fn.Set(labeledNode("node-a", "10.100.1.0/24"))
+	state := routecontroller.NewState()
+
+	reconcile(t, c, state)
+	assertEnabled(t, server, "dev-a", "10.100.1.0/24")
+
+	fn.Set(labeledNode("node-a", ""))
+	reconcile(t, c, state)
+	assertEnabled(t, server, "dev-a")
+}
+
+func TestReconcileNotJoined(t *testing.T) {
+	server, fn, c := newController(t, routecontroller.SourceAuto)
+	// `node-b` has advertised (and had enabled) its route, but has not yet
+	// run `kubeadm join`. `node-c` has joined but has not been labeled yet.
+	addDevice(server, "dev-b", "node-b", []string{"10.100.2.0/24"}, []string{"10.100.2.0/24"})
+	addDevice(server, "dev-c", "node-c", []string{"10.100.3.0/24"}, []string{"10.100.3.0/24"})
+	fn.Set(labeledNode("node-c", ""))
+	state := routecontroller.NewState()
+
+	reconcile(t, c, state)
+	assertEnabled(t, server, "dev-b", "10.100.2.0/24")
+	assertEnabled(t, server, "dev-c", "10.100.3.0/24")
+	if requests := server.Requests("POST "); requests != 0 {
+		t.Fatalf("expected no routes to be updated, got %d request(s)", requests)
+	}
+}
+
+func TestReconcileContinuesAfterError(t *testing.T) {
+	server, fn, c := newController(t, routecontroller.SourceAuto)
+	addDevice(server, "dev-a", "node-a", []string{"10.100.1.0/24"}, nil)
+	addDevice(server, "dev-b", "node-b", []string{"10.100.2.0/24"}, nil)
+	fn.Set(labeledNode("node-a", "10.100.1.0/24"), labeledNode("node-b", "10.100.2.0/24"))
+	server.Fail("GET /api/v2/device/dev-a/routes", 1)
+	state := routecontroller.NewState()
+
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	err := routecontroller.Reconcile(ctx, c, pool, state)
+	if err == nil || !strings.Contains(err.Error(), `failed to reconcile 1 node(s): node "node-a"`) {
+		t.Fatalf("expected aggregated error, got %v", err)
+	}
+	assertEnabled(t, server, "dev-a")
+	assertEnabled(t, server, "dev-b", "10.100.2.0/24")
+
+	reconcile(t, c, state)
+	assertEnabled(t, server, "dev-a", "10.100.1.0/24")
+}
+
+func TestReconcileNodeDeletedWhileStopped(t *testing.T) {
+	server, fn, c := newController(t, routecontroller.SourceAuto)
+	c.StateFile = filepath.Join(t.TempDir(), "route-controller.json")
+	addDevice(server, "dev-a", "node-a", []string{"10.100.1.0/24"}, nil)
+	fn.Set(labeledNode("node-a", "10.100.1.0/24"))
+
+	state, err := routecontroller.LoadState(c.StateFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reconcile(t, c, state)
+	assertEnabled(t, server, "dev-a", "10.100.1.0/24")
+
+	// The node is deleted while the controller is stopped; after a restart,
+	// the state file is the only record that `node-a` was managed.
+	fn.Set()
+	state, err = routecontroller.LoadState(c.StateFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !state.Managed["node-a"] {
+		t.Fatalf("expected state file to record node-a as managed, got %v", state.Managed)
+	}
+	reconcile(t, c, state)
+	assertEnabled(t, server, "dev-a")
+
+	state, err = routecontroller.LoadState(c.StateFile)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(state.Managed) != 0 {
+		t.Fatalf("expected no managed nodes after withdrawal, got %v", state.Managed)
+	}
+}
+
+func TestReconcileEmitsEvents(t *testing.T) {
+	server, fn, c := newController(t, routecontroller.SourceAuto)
+	c.SkipEvents = false
+	addDevice(server, "dev-a", "node-a", []string{"10.100.1.0/24"}, nil)
+	n := labeledNode("node-a", "10.100.1.0/24")
+	n.Metadata.UID = "0b0e3d0c-uid-a"
+	fn.Set(n)
+	state := routecontroller.NewState()
+
+	reconcile(t, c, state)
+	assertEnabled(t, server, "dev-a", "10.100.1.0/24")
+
+	events := fn.Events()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	e := events[0]
+	expected := kubernetes.ObjectReference{APIVersion: "v1", Kind: "Node", Name: "node-a", UID: "0b0e3d0c-uid-a"}
+	if e.InvolvedObject != expected {
+		t.Fatalf("expected involved object %+v, got %+v", expected, e.InvolvedObject)
+	}
+	if e.Kind != "Event" || e.Type != kubernetes.EventTypeNormal || e.Reason != "TailscaleRoutesEnabled" {
+		t.Fatalf("unexpected event kind, type or reason: %q, %q, %q", e.Kind, e.Type, e.Reason)
+	}
+	if e.Source.Component != routecontroller.Component {
+		t.Fatalf("expected source component %q, got %q", routecontroller.Component, e.Source.Component)
+	}
+	message := "enabled 10.100.1.0/24 for device dev-a (node-a)"
+	if e.Message != message {
+		t.Fatalf("expected message %q, got %q", message, e.Message)
+	}
+	if !strings.HasPrefix(e.Metadata.Name, "node-a.") || e.Metadata.Namespace != "default" {
+		t.Fatalf("unexpected event metadata: %+v", e.Metadata)
+	}
+
+	// No event is emitted when nothing changes.
+	reconcile(t, c, state)
+	if len(fn.Events()) != 1 {
+		t.Fatalf("expected no new events, got %d", len(fn.Events()))
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package routecontroller provides a long-running controller that keeps the
+// routes enabled in the Tailnet in sync with the nodes in a Kubernetes
+// cluster.
+//
+// Each node's pod subnet is read from the `tailsk8s.io/advertise-subnet`
+// label or from `spec.podCIDRs`; the route is enabled for the Tailscale
+// device with the same hostname once the node advertises it. Routes within
+// the cluster pod subnet are disabled for nodes that the controller has
+// previously seen with a subnet and that have since lost their subnet or been
+// deleted; the set of previously seen nodes is kept in a state file so that
+// nodes deleted while the controller is down are also handled. Devices that
+// are not (yet) nodes, e.g. a machine that has run `tailscale-advertise` but
+// not `kubeadm join`, are left alone.
+//
+// Rather than depending on a Kubernetes client library, nodes are listed via
+// `kubectl` on a fixed interval; each pass reconciles the full state, so a
+// missed change is picked up on the next pass.
+//
+// This is provided in a way to optimize the testable surface area (even for
+// untested parts of the code) without having any usage of `os.Exit()`.
+package routecontroller
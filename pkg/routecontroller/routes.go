@@ -0,0 +1,128 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routecontroller
+
+import (
+	"fmt"
+	"sort"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+)
+
+// NodeSubnets returns the subnets that should be routed to a node, based on
+// `source`.
+func NodeSubnets(n kubernetes.Node, source string) ([]netaddr.IPPrefix, error) {
+	label := n.AdvertiseSubnet()
+	podCIDRs := n.Spec.PodCIDRs
+	if len(podCIDRs) == 0 && n.Spec.PodCIDR != "" {
+		podCIDRs = []string{n.Spec.PodCIDR}
+	}
+
+	raw := []string{}
+	switch source {
+	case SourceLabel:
+		if label != "" {
+			raw = []string{label}
+		}
+	case SourcePodCIDRs:
+		raw = podCIDRs
+	default:
+		if label != "" {
+			raw = []string{label}
+		} else {
+			raw = podCIDRs
+		}
+	}
+
+	subnets := []netaddr.IPPrefix{}
+	for _, r := range raw {
+		p, err := netaddr.ParseIPPrefix(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet for node %q: %w", n.Metadata.Name, err)
+		}
+		subnets = append(subnets, p.Masked())
+	}
+	return subnets, nil
+}
+
+// RouteChange describes the updated set of enabled routes for a device.
+type RouteChange struct {
+	// Routes is the full set of routes that should be enabled.
+	Routes   []string
+	Enabled  []string
+	Disabled []string
+	// NotAdvertised are desired routes that can't be enabled yet because
+	// the device has not advertised them.
+	NotAdvertised []string
+}
+
+// Changed determines if the enabled routes need to be updated.
+func (rc RouteChange) Changed() bool {
+	return len(rc.Enabled) > 0 || len(rc.Disabled) > 0
+}
+
+// ComputeRouteChange determines the enabled routes for a device given the
+// currently enabled and advertised routes and the desired routes. Routes
+// outside of `pool` are never disabled, so the controller does not interfere
+// with unrelated routes (e.g. an exit node).
+func ComputeRouteChange(enabled, advertised []string, desired []netaddr.IPPrefix, pool netaddr.IPPrefix) (RouteChange, error) {
+	want := map[string]bool{}
+	for _, d := range desired {
+		want[d.String()] = true
+	}
+	isAdvertised := map[string]bool{}
+	for _, a := range advertised {
+		isAdvertised[a] = true
+	}
+
+	rc := RouteChange{Routes: []string{}}
+	current := map[string]bool{}
+	for _, e := range enabled {
+		p, err := netaddr.ParseIPPrefix(e)
+		if err != nil {
+			return RouteChange{}, err
+		}
+		current[e] = true
+		if Managed(p, pool) && !want[e] {
+			rc.Disabled = append(rc.Disabled, e)
+			continue
+		}
+		rc.Routes = append(rc.Routes, e)
+	}
+
+	for _, d := range desired {
+		route := d.String()
+		if current[route] {
+			continue
+		}
+		if !isAdvertised[route] {
+			rc.NotAdvertised = append(rc.NotAdvertised, route)
+			continue
+		}
+		rc.Enabled = append(rc.Enabled, route)
+		rc.Routes = append(rc.Routes, route)
+	}
+
+	sort.Strings(rc.Routes)
+	return rc, nil
+}
+
+// Managed determines if a route is within the pod subnet, i.e. if it is
+// managed by the controller.
+func Managed(route, pool netaddr.IPPrefix) bool {
+	return route.Bits() >= pool.Bits() && pool.Contains(route.IP())
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routecontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"tailscale.com/atomicfile"
+)
+
+// State records the nodes the controller manages routes for across reconcile
+// passes (and across restarts, via a state file).
+type State struct {
+	// Managed contains the hostname of every node that has had a subnet
+	// (i.e. a label or pod CIDR) during a previous pass. Only routes for
+	// managed hostnames are ever withdrawn; this ensures the controller does
+	// not withdraw routes for a node that is still joining the cluster (the
+	// join scripts advertise a route before `kubeadm join` and labeling).
+	Managed map[string]bool `json:"managed"`
+}
+
+// NewState returns a new (empty) `State`.
+func NewState() *State {
+	return &State{Managed: map[string]bool{}}
+}
+
+// Clone returns a deep copy of the state.
+func (s State) Clone() *State {
+	clone := NewState()
+	for name, managed := range s.Managed {
+		clone.Managed[name] = managed
+	}
+	return clone
+}
+
+// Equal determines if two states manage the same nodes.
+func (s State) Equal(other *State) bool {
+	if len(s.Managed) != len(other.Managed) {
+		return false
+	}
+	for name, managed := range s.Managed {
+		if other.Managed[name] != managed {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadState reads the state file; if `filename` is empty or the file does not
+// exist, a fresh state is returned.
+//
+// Persisting the state ensures a node that is deleted while the controller
+// is down (or restarting) still has its routes withdrawn on the next pass.
+func LoadState(filename string) (*State, error) {
+	if filename == "" {
+		return NewState(), nil
+	}
+	content, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s State
+	err = json.Unmarshal(content, &s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", filename, err)
+	}
+	if s.Managed == nil {
+		s.Managed = map[string]bool{}
+	}
+	return &s, nil
+}
+
+// SaveState atomically writes the state file.
+func SaveState(filename string, s State) error {
+	asJSON, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(filename, append(asJSON, '\n'), 0644)
+}
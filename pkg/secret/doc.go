@@ -0,0 +1,32 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secret provides pluggable providers for reading secrets such as
+// Tailscale API keys, auth keys and OAuth client secrets.
+//
+// A secret is specified via a reference of the form `{scheme}:{ref}`, e.g.
+// `file:/var/data/tailsk8s-bootstrap/tailscale-api-key`. Supported schemes:
+//   - `file:{path}`: read from a file; files with world permissions are
+//     refused and files with group permissions produce a warning
+//   - `env:{VAR}`: read from an environment variable
+//   - `exec:{command}`: read from the STDOUT of a command (split on whitespace,
+//     no shell is involved)
+//   - `systemd:{name}`: read a systemd credential from
+//     `${CREDENTIALS_DIRECTORY}/{name}`
+//   - `k8s:{namespace}/{name}/{key}`: read a key from a Kubernetes `Secret`
+//   - `vault:{path}#{field}`: read a field from a Vault KV secret via
+//     `${VAULT_ADDR}/v1/{path}`
+//
+// A value without a known scheme is treated as the literal secret.
+package secret
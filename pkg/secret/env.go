@@ -0,0 +1,47 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// ReadEnv reads a secret from an environment variable.
+func ReadEnv(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// ReadExec reads a secret from the STDOUT of a command. The command is split
+// on whitespace (no shell is involved).
+func ReadExec(ctx context.Context, command string) (string, error) {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return "", fmt.Errorf("empty command")
+	}
+	stdout, err := cli.ExecRun(ctx, nil, parts[0], parts[1:]...)
+	if err != nil {
+		return "", err
+	}
+	return string(stdout), nil
+}
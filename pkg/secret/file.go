@@ -0,0 +1,65 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// ReadFile reads a secret from a file. Files that can be accessed by
+// other users (world permissions) are refused and files that can be accessed
+// by the owning group produce a warning. Permissions are not checked on
+// Windows.
+func ReadFile(ctx context.Context, filename string) (string, error) {
+	err := CheckPermissions(ctx, filename)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// CheckPermissions ensures a secret file is not accessible by other users.
+func CheckPermissions(ctx context.Context, filename string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	mode := info.Mode().Perm()
+	if mode&0o007 != 0 {
+		return fmt.Errorf(
+			"refusing to read secret from %s, file mode %04o allows access by other users; run `chmod 400 %s`",
+			filename, mode, filename,
+		)
+	}
+	if mode&0o070 != 0 {
+		cli.Printf(ctx, "WARNING: Secret file %s has mode %04o, which allows access by group members\n", filename, mode)
+	}
+	return nil
+}
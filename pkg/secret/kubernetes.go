@@ -0,0 +1,34 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+)
+
+// ReadKubernetesSecret reads a key from a Kubernetes `Secret` via `kubectl`.
+// The reference is expected to be of the form `{namespace}/{name}/{key}`.
+func ReadKubernetesSecret(ctx context.Context, ref string) (string, error) {
+	parts := strings.Split(ref, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("invalid Kubernetes secret reference %q, expected {namespace}/{name}/{key}", ref)
+	}
+	k := kubernetes.Kubectl{}
+	return k.GetSecretValue(ctx, parts[0], parts[1], parts[2])
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Provider reads a secret; `ref` is the part of the reference **after**
+// the `{scheme}:` prefix.
+type Provider func(ctx context.Context, ref string) (string, error)
+
+// Registry is a set of providers, keyed by scheme.
+type Registry struct {
+	providers map[string]Provider
+}
+
+// NewRegistry returns a registry with no providers.
+func NewRegistry() *Registry {
+	return &Registry{providers: map[string]Provider{}}
+}
+
+// DefaultRegistry returns a registry with all built-in providers.
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	r.Register("file", ReadFile)
+	r.Register("env", ReadEnv)
+	r.Register("exec", ReadExec)
+	r.Register("systemd", ReadSystemdCredential)
+	r.Register("k8s", ReadKubernetesSecret)
+	r.Register("vault", ReadVault)
+	return r
+}
+
+// Default is the registry used by the package-level `Read()`.
+var Default = DefaultRegistry()
+
+// Register adds (or replaces) the provider for a scheme.
+func (r *Registry) Register(scheme string, p Provider) {
+	r.providers[scheme] = p
+}
+
+// Schemes returns the (sorted) registered schemes.
+func (r *Registry) Schemes() []string {
+	schemes := make([]string, 0, len(r.providers))
+	for scheme := range r.providers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Split splits a reference into a scheme and the remainder. If `value` does
+// not begin with a registered scheme, `ok` will be `false`.
+func (r *Registry) Split(value string) (scheme, ref string, ok bool) {
+	index := strings.Index(value, ":")
+	if index <= 0 {
+		return "", "", false
+	}
+	scheme = value[:index]
+	if _, ok := r.providers[scheme]; !ok {
+		return "", "", false
+	}
+	return scheme, value[index+1:], true
+}
+
+// Read resolves a secret reference. If `value` does not begin with a
+// registered scheme, it is returned as-is (i.e. it is a literal secret).
+// A single trailing newline is removed from the secret.
+func (r *Registry) Read(ctx context.Context, value string) (string, error) {
+	scheme, ref, ok := r.Split(value)
+	if !ok {
+		return value, nil
+	}
+
+	s, err := r.providers[scheme](ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret via %q provider: %w", scheme, err)
+	}
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return "", fmt.Errorf("secret read via %q provider is empty", scheme)
+	}
+	return s, nil
+}
+
+// Describe returns a human readable (non-secret) description of where a
+// secret will be read from. For literal secrets, it returns the empty string.
+func (r *Registry) Describe(value string) string {
+	scheme, ref, ok := r.Split(value)
+	if !ok {
+		return ""
+	}
+	if scheme == "file" {
+		return ref
+	}
+	return value
+}
+
+// Read resolves a secret reference via the default registry.
+func Read(ctx context.Context, value string) (string, error) {
+	return Default.Read(ctx, value)
+}
+
+// Describe describes a secret reference via the default registry.
+func Describe(value string) string {
+	return Default.Describe(value)
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/secret"
+)
+
+func TestReadLiteral(t *testing.T) {
+	ctx := context.Background()
+	value, err := secret.Read(ctx, "tskey-literal")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value != "tskey-literal" {
+		t.Fatalf("expected literal value, got %q", value)
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	ctx := context.Background()
+	filename := writeSecretFile(t, "tskey-file\n", 0o600)
+
+	value, err := secret.Read(ctx, "file:"+filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value != "tskey-file" {
+		t.Fatalf("expected %q, got %q", "tskey-file", value)
+	}
+}
+
+func TestReadEnv(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("TAILSK8S_TEST_SECRET", "tskey-env")
+
+	value, err := secret.Read(ctx, "env:TAILSK8S_TEST_SECRET")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value != "tskey-env" {
+		t.Fatalf("expected %q, got %q", "tskey-env", value)
+	}
+
+	_, err = secret.Read(ctx, "env:TAILSK8S_TEST_SECRET_MISSING")
+	if err == nil || !strings.Contains(err.Error(), "is not set") {
+		t.Fatalf("expected missing variable error, got %v", err)
+	}
+}
+
+func TestReadExec(t *testing.T) {
+	ctx := context.Background()
+	value, err := secret.Read(ctx, "exec:echo tskey-exec")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value != "tskey-exec" {
+		t.Fatalf("expected %q, got %q", "tskey-exec", value)
+	}
+
+	_, err = secret.Read(ctx, "exec:true")
+	if err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Fatalf("expected empty secret error, got %v", err)
+	}
+}
+
+func TestCheckPermissions(t *testing.T) {
+	var stdout bytes.Buffer
+	ctx := cli.WithStdout(context.Background(), &stdout)
+
+	filename := writeSecretFile(t, "tskey-world\n", 0o604)
+	err := secret.CheckPermissions(ctx, filename)
+	if err == nil || !strings.Contains(err.Error(), "refusing to read secret") {
+		t.Fatalf("expected mode 0604 to be refused, got %v", err)
+	}
+
+	filename = writeSecretFile(t, "tskey-group\n", 0o640)
+	err = secret.CheckPermissions(ctx, filename)
+	if err != nil {
+		t.Fatalf("expected mode 0640 to be allowed, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "WARNING: Secret file") {
+		t.Fatalf("expected mode 0640 to produce a warning, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	filename = writeSecretFile(t, "tskey-owner\n", 0o400)
+	err = secret.CheckPermissions(ctx, filename)
+	if err != nil {
+		t.Fatalf("expected mode 0400 to be allowed, got %v", err)
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("expected no warning for mode 0400, got %q", stdout.String())
+	}
+}
+
+func TestReadVault(t *testing.T) {
+	ctx := context.Background()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s.test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var data map[string]interface{}
+		switch r.URL.Path {
+		case "/v1/secret/data/tailsk8s":
+			// KV version 2
+			data = map[string]interface{}{
+				"data": map[string]interface{}{"api-key": "tskey-kv2"},
+			}
+		case "/v1/kv/tailsk8s":
+			// KV version 1
+			data = map[string]interface{}{"api-key": "tskey-kv1"}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "s.test-token")
+
+	cases := map[string]string{
+		"vault:secret/data/tailsk8s#api-key": "tskey-kv2",
+		"vault:kv/tailsk8s#api-key":          "tskey-kv1",
+	}
+	for ref, expected := range cases {
+		value, err := secret.Read(ctx, ref)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", ref, err)
+		}
+		if value != expected {
+			t.Fatalf("%s: expected %q, got %q", ref, expected, value)
+		}
+	}
+
+	_, err := secret.Read(ctx, "vault:secret/data/tailsk8s#missing")
+	if err == nil || !strings.Contains(err.Error(), `no string field "missing"`) {
+		t.Fatalf("expected missing field error, got %v", err)
+	}
+	_, err = secret.Read(ctx, "vault:secret/data/unknown#api-key")
+	if err == nil || !strings.Contains(err.Error(), "status 404") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	t.Setenv("VAULT_TOKEN", "s.wrong-token")
+	_, err = secret.Read(ctx, "vault:secret/data/tailsk8s#api-key")
+	if err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Fatalf("expected forbidden error, got %v", err)
+	}
+}
+
+func writeSecretFile(t *testing.T, contents string, mode os.FileMode) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "secret.txt")
+	err := os.WriteFile(filename, []byte(contents), mode)
+	if err != nil {
+		t.Fatalf("failed to write secret file: %v", err)
+	}
+	// NOTE: `os.WriteFile()` is subject to the umask, so the mode is set
+	//       explicitly.
+	err = os.Chmod(filename, mode)
+	if err != nil {
+		t.Fatalf("failed to set secret file mode: %v", err)
+	}
+	return filename
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadSystemdCredential reads a secret passed to a systemd service via
+// `LoadCredential=` or `SetCredential=`. These are made available in the
+// directory `${CREDENTIALS_DIRECTORY}`.
+//
+// See: https://systemd.io/CREDENTIALS/
+func ReadSystemdCredential(ctx context.Context, name string) (string, error) {
+	dir := os.Getenv("CREDENTIALS_DIRECTORY")
+	if dir == "" {
+		return "", fmt.Errorf("CREDENTIALS_DIRECTORY is not set, systemd credentials are only available to services")
+	}
+	if name == "" || strings.ContainsRune(name, '/') {
+		return "", fmt.Errorf("invalid credential name %q", name)
+	}
+	return ReadFile(ctx, filepath.Join(dir, name))
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+const (
+	debugCurlVaultRead = `Calling "read secret" Vault API route:
+> curl \
+>   --include \
+>   --header "X-Vault-Token: ...redacted Vault Token..." \
+>   %s
+`
+)
+
+// vaultResponse is the response for the `GET /v1/:path` Vault API route. For
+// the KV version 2 secrets engine, the secret fields are nested under
+// `data.data`; for version 1 they are directly under `data`.
+type vaultResponse struct {
+	Data map[string]interface{} `json:"data"`
+}
+
+// ReadVault reads a field from a Vault KV secret. The reference is expected
+// to be of the form `{path}#{field}` where `{path}` is the full API path, e.g.
+// `secret/data/tailsk8s#api-key` for a KV version 2 engine mounted at
+// `secret/`.
+//
+// The Vault server is determined by `VAULT_ADDR` (defaulting to
+// `https://127.0.0.1:8200`) and the token by `VAULT_TOKEN` (falling back to
+// `~/.vault-token`), matching the `vault` CLI.
+func ReadVault(ctx context.Context, ref string) (string, error) {
+	index := strings.LastIndex(ref, "#")
+	if index <= 0 || index == len(ref)-1 {
+		return "", fmt.Errorf("invalid Vault reference %q, expected {path}#{field}", ref)
+	}
+	path := strings.Trim(ref[:index], "/")
+	field := ref[index+1:]
+
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		addr = "https://127.0.0.1:8200"
+	}
+	token, err := vaultToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(addr, "/"), path)
+	cli.DebugPrintf(ctx, debugCurlVaultRead, url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("failed to read Vault secret (status %d, body %q)", resp.StatusCode, body)
+	}
+
+	var vr vaultResponse
+	err = json.NewDecoder(resp.Body).Decode(&vr)
+	if err != nil {
+		return "", err
+	}
+
+	fields := vr.Data
+	if nested, ok := vr.Data["data"].(map[string]interface{}); ok {
+		fields = nested
+	}
+	value, ok := fields[field].(string)
+	if !ok {
+		return "", fmt.Errorf("secret %s in Vault has no string field %q", path, field)
+	}
+	return value, nil
+}
+
+func vaultToken(ctx context.Context) (string, error) {
+	token := os.Getenv("VAULT_TOKEN")
+	if token != "" {
+		return token, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("VAULT_TOKEN is not set and home directory is unknown: %w", err)
+	}
+	filename := filepath.Join(home, ".vault-token")
+	b, err := ReadFile(ctx, filename)
+	if err != nil {
+		return "", fmt.Errorf("VAULT_TOKEN is not set: %w", err)
+	}
+	return strings.TrimSpace(b), nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subnet
+
+import (
+	"fmt"
+
+	"inet.af/netaddr"
+)
+
+// NextFree returns a free prefix with `bits` prefix length within `pool`
+// that does not overlap any of the `used` prefixes. The smallest free block
+// that can fit the prefix is used, to avoid fragmenting the pool.
+func NextFree(pool netaddr.IPPrefix, bits uint8, used []netaddr.IPPrefix) (netaddr.IPPrefix, error) {
+	if bits < pool.Bits() || bits > pool.IP().BitLen() {
+		return netaddr.IPPrefix{}, fmt.Errorf("invalid prefix length /%d for pool %s", bits, pool)
+	}
+
+	var b netaddr.IPSetBuilder
+	b.AddPrefix(pool)
+	for _, u := range used {
+		b.RemovePrefix(u)
+	}
+	free, err := b.IPSet()
+	if err != nil {
+		return netaddr.IPPrefix{}, err
+	}
+
+	p, _, ok := free.RemoveFreePrefix(bits)
+	if !ok {
+		return netaddr.IPPrefix{}, fmt.Errorf("no free /%d subnets remain in pool %s", bits, pool)
+	}
+	return p, nil
+}
+
+// ParseAllocations parses the subnet for each allocation.
+func ParseAllocations(allocations []Allocation) ([]netaddr.IPPrefix, error) {
+	prefixes := []netaddr.IPPrefix{}
+	for _, a := range allocations {
+		p, err := netaddr.ParseIPPrefix(a.Subnet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet for node %q: %w", a.Node, err)
+		}
+		prefixes = append(prefixes, p)
+	}
+	return prefixes, nil
+}
+
+// FindConflicts describes every problem with a set of allocations:
+// allocations outside of the pool (if `pool` is non-zero), allocations that
+// overlap each other and allocations that overlap a route enabled in the
+// Tailnet for a **different** device.
+func FindConflicts(allocations []Allocation, pool netaddr.IPPrefix, routes []EnabledRoute) ([]string, error) {
+	prefixes, err := ParseAllocations(allocations)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []string{}
+	for i, a := range allocations {
+		p := prefixes[i]
+		if !pool.IsZero() && (!pool.Contains(p.IP()) || p.Bits() < pool.Bits()) {
+			conflicts = append(conflicts, fmt.Sprintf("subnet %s (node %q) is outside of pool %s", p, a.Node, pool))
+		}
+		for j := i + 1; j < len(allocations); j++ {
+			if p.Overlaps(prefixes[j]) {
+				conflicts = append(conflicts, fmt.Sprintf(
+					"subnet %s (node %q) overlaps subnet %s (node %q)",
+					p, a.Node, prefixes[j], allocations[j].Node,
+				))
+			}
+		}
+		for _, r := range routes {
+			if r.Hostname != a.Node && p.Overlaps(r.Route) {
+				conflicts = append(conflicts, fmt.Sprintf(
+					"subnet %s (node %q) overlaps route %s enabled for device %s (%s)",
+					p, a.Node, r.Route, r.DeviceID, r.Hostname,
+				))
+			}
+		}
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subnet
+
+import (
+	"fmt"
+
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	// DefaultPrefixLength is the size of the subnet allocated to each node.
+	DefaultPrefixLength = 24
+	// DefaultStateFile is the state file used by the `file` backend.
+	DefaultStateFile = "/var/data/tailsk8s-bootstrap/subnet-allocations.json"
+
+	// BackendFile persists allocations in a local JSON state file.
+	BackendFile = "file"
+	// BackendKubernetes persists allocations as labels on Kubernetes nodes.
+	BackendKubernetes = "kubernetes"
+)
+
+// Config provides the core set of (CLI) inputs needed to allocate, release
+// and list per-node pod subnets.
+type Config struct {
+	APIConfig cloud.Config
+	// PodSubnet is the cluster `podSubnet` that node subnets are carved from.
+	PodSubnet    string
+	PrefixLength int
+	// Node is the node (and Tailscale device hostname) to act on.
+	Node      string
+	Backend   string
+	StateFile string
+	Kubectl   kubernetes.Kubectl
+	// SkipTailnetCheck disables checking for conflicts with routes that are
+	// already enabled in the Tailnet (this avoids using the cloud API).
+	SkipTailnetCheck bool
+}
+
+// NewConfig returns a new `Config` with all relevant defaults provided and
+// options for overriding.
+func NewConfig(opts ...Option) (Config, error) {
+	ac, err := cloud.NewConfig()
+	if err != nil {
+		return Config{}, err
+	}
+
+	c := Config{
+		APIConfig:    ac,
+		PrefixLength: DefaultPrefixLength,
+		Backend:      BackendFile,
+		StateFile:    DefaultStateFile,
+	}
+	for _, opt := range opts {
+		err := opt(&c)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+// Store returns the allocation store for the configured backend.
+func (c Config) Store() (Store, error) {
+	switch c.Backend {
+	case BackendFile:
+		return FileStore{Filename: c.StateFile}, nil
+	case BackendKubernetes:
+		return NodeLabelStore{Kubectl: c.Kubectl}, nil
+	}
+	return nil, fmt.Errorf("unknown backend %q, expected %q or %q", c.Backend, BackendFile, BackendKubernetes)
+}
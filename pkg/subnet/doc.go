@@ -0,0 +1,26 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package subnet allocates per-node pod subnets out of the cluster
+// `podSubnet`.
+//
+// Each node in a `tailsk8s` cluster exclusively owns a part of the pod subnet
+// and advertises it to the Tailnet as a subnet route. Allocations are carved
+// out so they never overlap each other or routes that are already enabled in
+// the Tailnet, and are persisted either in a local state file or as labels
+// on Kubernetes nodes.
+//
+// This is provided in a way to optimize the testable surface area (even for
+// untested parts of the code) without having any usage of `os.Exit()`.
+package subnet
@@ -0,0 +1,268 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subnet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"inet.af/netaddr"
+	"tailscale.com/atomicfile"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/kubernetes"
+)
+
+// Allocation is a subnet that is exclusively owned by a node.
+type Allocation struct {
+	Node   string `json:"node"`
+	Subnet string `json:"subnet"`
+}
+
+// Store persists allocations.
+type Store interface {
+	// List returns all allocations, sorted by node.
+	List(ctx context.Context) ([]Allocation, error)
+	// Save persists a new allocation; it is an error if the node already has
+	// a different allocation or if the subnet overlaps the allocation of
+	// another node.
+	Save(ctx context.Context, a Allocation) error
+	// Delete removes the allocation for a node and returns the removed
+	// allocation (or `nil` if the node had no allocation).
+	Delete(ctx context.Context, node string) (*Allocation, error)
+	// Lock acquires an exclusive lock so that a `List()` followed by a
+	// `Save()` can't race with another allocation. The returned function
+	// releases the lock.
+	Lock(ctx context.Context) (func(), error)
+}
+
+// FileStore persists allocations in a local JSON state file.
+type FileStore struct {
+	Filename string
+}
+
+type stateFile struct {
+	Allocations []Allocation `json:"allocations"`
+}
+
+// List returns all allocations in the state file; a missing state file has
+// no allocations.
+func (fs FileStore) List(_ context.Context) ([]Allocation, error) {
+	data, err := os.ReadFile(fs.Filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return []Allocation{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sf stateFile
+	err = json.Unmarshal(data, &sf)
+	if err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", fs.Filename, err)
+	}
+	sortAllocations(sf.Allocations)
+	return sf.Allocations, nil
+}
+
+// Save adds an allocation to the state file.
+func (fs FileStore) Save(ctx context.Context, a Allocation) error {
+	allocations, err := fs.List(ctx)
+	if err != nil {
+		return err
+	}
+	existing := findAllocation(allocations, a.Node)
+	if existing != nil {
+		if existing.Subnet == a.Subnet {
+			return nil
+		}
+		return fmt.Errorf("node %q already has subnet %s allocated", a.Node, existing.Subnet)
+	}
+	err = checkOverlap(allocations, a)
+	if err != nil {
+		return err
+	}
+
+	return fs.write(append(allocations, a))
+}
+
+// Delete removes an allocation from the state file.
+func (fs FileStore) Delete(ctx context.Context, node string) (*Allocation, error) {
+	allocations, err := fs.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing := findAllocation(allocations, node)
+	if existing == nil {
+		return nil, nil
+	}
+
+	remaining := []Allocation{}
+	for _, a := range allocations {
+		if a.Node != node {
+			remaining = append(remaining, a)
+		}
+	}
+	return existing, fs.write(remaining)
+}
+
+// Lock acquires an exclusive `flock(2)` on a lock file next to the state
+// file. A separate lock file is used because `write()` atomically replaces
+// the state file, which would drop a lock held on the state file itself.
+func (fs FileStore) Lock(ctx context.Context) (func(), error) {
+	err := os.MkdirAll(filepath.Dir(fs.Filename), 0755)
+	if err != nil {
+		return nil, err
+	}
+	return cli.LockFile(ctx, "subnet allocations", fs.Filename+".lock")
+}
+
+func (fs FileStore) write(allocations []Allocation) error {
+	sortAllocations(allocations)
+	asJSON, err := json.MarshalIndent(stateFile{Allocations: allocations}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(fs.Filename), 0755)
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(fs.Filename, append(asJSON, '\n'), 0644)
+}
+
+// NodeLabelStore persists allocations as the `tailsk8s.io/advertise-subnet`
+// label on Kubernetes nodes. This means a node must have joined the cluster
+// before a subnet can be allocated to it.
+type NodeLabelStore struct {
+	Kubectl kubernetes.Kubectl
+}
+
+// List returns an allocation for each node with the advertise subnet label.
+func (nls NodeLabelStore) List(ctx context.Context) ([]Allocation, error) {
+	nodes, err := nls.Kubectl.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allocations := []Allocation{}
+	for _, n := range nodes {
+		subnet := n.AdvertiseSubnet()
+		if subnet == "" {
+			continue
+		}
+		allocations = append(allocations, Allocation{Node: n.Metadata.Name, Subnet: subnet})
+	}
+	sortAllocations(allocations)
+	return allocations, nil
+}
+
+// Save sets the advertise subnet label on a node; `kubectl` will refuse to
+// overwrite a different existing value.
+//
+// Since there is no lock spanning the `List()` and `Save()` (see `Lock()`),
+// the allocations are listed again after labeling the node. If another node
+// was concurrently labeled with an overlapping subnet, the label is removed
+// again and an error is returned.
+func (nls NodeLabelStore) Save(ctx context.Context, a Allocation) error {
+	value := kubernetes.EncodeSubnetLabel(a.Subnet)
+	err := nls.Kubectl.LabelNode(ctx, a.Node, kubernetes.AdvertiseSubnetLabel, value, false)
+	if err != nil {
+		return err
+	}
+
+	allocations, err := nls.List(ctx)
+	if err != nil {
+		return err
+	}
+	err = checkOverlap(allocations, a)
+	if err == nil {
+		return nil
+	}
+
+	cli.Printf(ctx, "Removing subnet label from node %q: %v\n", a.Node, err)
+	removeErr := nls.Kubectl.RemoveNodeLabel(ctx, a.Node, kubernetes.AdvertiseSubnetLabel)
+	if removeErr != nil {
+		return fmt.Errorf("%w; failed to remove subnet label: %v", err, removeErr)
+	}
+	return err
+}
+
+// Delete removes the advertise subnet label from a node.
+func (nls NodeLabelStore) Delete(ctx context.Context, node string) (*Allocation, error) {
+	n, err := nls.Kubectl.GetNode(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+	subnet := n.AdvertiseSubnet()
+	if subnet == "" {
+		return nil, nil
+	}
+
+	err = nls.Kubectl.RemoveNodeLabel(ctx, node, kubernetes.AdvertiseSubnetLabel)
+	if err != nil {
+		return nil, err
+	}
+	return &Allocation{Node: node, Subnet: subnet}, nil
+}
+
+// Lock is a no-op for node labels; the Kubernetes API has no lock that spans
+// multiple nodes so `Save()` detects conflicts after the fact instead.
+func (NodeLabelStore) Lock(_ context.Context) (func(), error) {
+	return func() {}, nil
+}
+
+// checkOverlap ensures that the subnet in `a` does not overlap the allocation
+// of any other node.
+func checkOverlap(allocations []Allocation, a Allocation) error {
+	subnet, err := netaddr.ParseIPPrefix(a.Subnet)
+	if err != nil {
+		return err
+	}
+	for _, other := range allocations {
+		if other.Node == a.Node {
+			continue
+		}
+		otherSubnet, err := netaddr.ParseIPPrefix(other.Subnet)
+		if err != nil {
+			return fmt.Errorf("invalid subnet for node %q: %w", other.Node, err)
+		}
+		if subnet.Overlaps(otherSubnet) {
+			return fmt.Errorf("subnet %s overlaps subnet %s allocated to node %q", a.Subnet, other.Subnet, other.Node)
+		}
+	}
+	return nil
+}
+
+func findAllocation(allocations []Allocation, node string) *Allocation {
+	for i := range allocations {
+		if allocations[i].Node == node {
+			a := allocations[i]
+			return &a
+		}
+	}
+	return nil
+}
+
+func sortAllocations(allocations []Allocation) {
+	sort.Slice(allocations, func(i, j int) bool {
+		return allocations[i].Node < allocations[j].Node
+	})
+}
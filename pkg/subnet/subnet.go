@@ -0,0 +1,247 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subnet
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/cni"
+)
+
+// Allocate allocates a subnet for a node out of the pod subnet and persists
+// the allocation. If the node already has an allocation, it is left as-is.
+//
+// Unless `SkipTailnetCheck` is set, routes already enabled in the Tailnet are
+// never allocated. As a special case, if the node's own device already has a
+// route enabled that fits in the pool (and is not allocated to another node),
+// that route is adopted as the allocation.
+func Allocate(ctx context.Context, c Config) error {
+	pool, err := cni.ParseSubnet(c.PodSubnet)
+	if err != nil {
+		return err
+	}
+	if c.PrefixLength < int(pool.Bits()) || c.PrefixLength > 32 {
+		return fmt.Errorf("prefix length /%d must be between /%d and /32", c.PrefixLength, pool.Bits())
+	}
+	node, err := nodeOrHostname(c.Node)
+	if err != nil {
+		return err
+	}
+	store, err := c.Store()
+	if err != nil {
+		return err
+	}
+	unlock, err := store.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	allocations, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	existing := findAllocation(allocations, node)
+	if existing != nil {
+		cli.Printf(ctx, "Node %q already has subnet %s allocated\n", node, existing.Subnet)
+		return nil
+	}
+	used, err := ParseAllocations(allocations)
+	if err != nil {
+		return err
+	}
+
+	if !c.SkipTailnetCheck {
+		routes, err := resolveRoutes(ctx, c)
+		if err != nil {
+			return err
+		}
+		for _, r := range routes {
+			if !r.Route.Overlaps(pool) {
+				continue
+			}
+			if r.Hostname == node && adoptable(r.Route, pool, c.PrefixLength, used) {
+				cli.Printf(ctx, "Adopting route %s already enabled for device %s\n", r.Route, r.DeviceID)
+				return save(ctx, store, Allocation{Node: node, Subnet: r.Route.String()})
+			}
+			if !coveredBy(r.Route, allocations, used, r.Hostname) {
+				cli.Printf(ctx, "Excluding route %s enabled for device %s (%s) but not allocated\n", r.Route, r.DeviceID, r.Hostname)
+			}
+			used = append(used, r.Route)
+		}
+	}
+
+	p, err := NextFree(pool, uint8(c.PrefixLength), used)
+	if err != nil {
+		return err
+	}
+	return save(ctx, store, Allocation{Node: node, Subnet: p.String()})
+}
+
+// Release removes the allocation for a node.
+func Release(ctx context.Context, c Config) error {
+	node, err := nodeOrHostname(c.Node)
+	if err != nil {
+		return err
+	}
+	store, err := c.Store()
+	if err != nil {
+		return err
+	}
+	unlock, err := store.Lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	released, err := store.Delete(ctx, node)
+	if err != nil {
+		return err
+	}
+	if released == nil {
+		cli.Printf(ctx, "Node %q has no subnet allocated\n", node)
+		return nil
+	}
+	cli.Printf(ctx, "Released subnet %s from node %q\n", released.Subnet, node)
+
+	if c.SkipTailnetCheck {
+		return nil
+	}
+	routes, err := resolveRoutes(ctx, c)
+	if err != nil {
+		return err
+	}
+	for _, r := range routes {
+		if r.Route.String() == released.Subnet {
+			cli.Printf(ctx, "WARNING: route %s is still enabled for device %s (%s); withdraw it before re-allocating\n", r.Route, r.DeviceID, r.Hostname)
+		}
+	}
+	return nil
+}
+
+// List prints all allocations along with any conflicts. An error is returned
+// if any conflicts are found.
+func List(ctx context.Context, c Config) error {
+	var pool netaddr.IPPrefix
+	if c.PodSubnet != "" {
+		p, err := cni.ParseSubnet(c.PodSubnet)
+		if err != nil {
+			return err
+		}
+		pool = p
+	}
+	store, err := c.Store()
+	if err != nil {
+		return err
+	}
+
+	allocations, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	routes := []EnabledRoute{}
+	if !c.SkipTailnetCheck {
+		routes, err = resolveRoutes(ctx, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	w := tabwriter.NewWriter(cli.GetStdout(ctx), 0, 4, 2, ' ', 0)
+	_, err = w.Write([]byte("NODE\tSUBNET\n"))
+	if err != nil {
+		return err
+	}
+	for _, a := range allocations {
+		_, err = w.Write([]byte(strings.Join([]string{a.Node, a.Subnet}, "\t") + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	conflicts, err := FindConflicts(allocations, pool, routes)
+	if err != nil {
+		return err
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+	cli.Println(ctx, "")
+	cli.Println(ctx, "Conflicts:")
+	for _, conflict := range conflicts {
+		cli.Printf(ctx, "- %s\n", conflict)
+	}
+	return fmt.Errorf("found %d subnet allocation conflict(s)", len(conflicts))
+}
+
+func resolveRoutes(ctx context.Context, c Config) ([]EnabledRoute, error) {
+	err := c.APIConfig.Resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return TailnetEnabledRoutes(ctx, c.APIConfig)
+}
+
+func save(ctx context.Context, store Store, a Allocation) error {
+	err := store.Save(ctx, a)
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "Allocated subnet %s to node %q\n", a.Subnet, a.Node)
+	return nil
+}
+
+// adoptable determines if a route enabled for a node's device can be used as
+// the node's allocation.
+func adoptable(route, pool netaddr.IPPrefix, prefixLength int, used []netaddr.IPPrefix) bool {
+	if int(route.Bits()) != prefixLength || !pool.Contains(route.IP()) {
+		return false
+	}
+	for _, u := range used {
+		if u.Overlaps(route) {
+			return false
+		}
+	}
+	return true
+}
+
+// coveredBy determines if a route is exactly the allocation for the device
+// that has the route enabled.
+func coveredBy(route netaddr.IPPrefix, allocations []Allocation, used []netaddr.IPPrefix, hostname string) bool {
+	for i, a := range allocations {
+		if a.Node == hostname && used[i] == route {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeOrHostname(node string) (string, error) {
+	if node != "" {
+		return node, nil
+	}
+	return os.Hostname()
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subnet_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/subnet"
+)
+
+func TestAllocateConcurrent(t *testing.T) {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	stateFile := filepath.Join(t.TempDir(), "subnet-allocations.json")
+
+	const nodes = 16
+	errs := make([]error, nodes)
+	var wg sync.WaitGroup
+	for i := 0; i < nodes; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			c := subnet.Config{
+				PodSubnet:        "10.100.0.0/16",
+				PrefixLength:     24,
+				Node:             fmt.Sprintf("node-%02d", i),
+				Backend:          subnet.BackendFile,
+				StateFile:        stateFile,
+				SkipTailnetCheck: true,
+			}
+			errs[i] = subnet.Allocate(ctx, c)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("node-%02d: unexpected error: %v", i, err)
+		}
+	}
+
+	store := subnet.FileStore{Filename: stateFile}
+	allocations, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(allocations) != nodes {
+		t.Fatalf("expected %d allocations, got %d", nodes, len(allocations))
+	}
+	conflicts, err := subnet.FindConflicts(allocations, netaddr.MustParseIPPrefix("10.100.0.0/16"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(conflicts) > 0 {
+		t.Fatalf("expected no conflicts, got:\n%s", strings.Join(conflicts, "\n"))
+	}
+}
+
+func TestFileStoreSaveOverlap(t *testing.T) {
+	ctx := context.Background()
+	store := subnet.FileStore{Filename: filepath.Join(t.TempDir(), "subnet-allocations.json")}
+
+	err := store.Save(ctx, subnet.Allocation{Node: "node-a", Subnet: "10.100.0.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = store.Save(ctx, subnet.Allocation{Node: "node-b", Subnet: "10.100.0.128/25"})
+	if err == nil || !strings.Contains(err.Error(), `allocated to node "node-a"`) {
+		t.Fatalf("expected overlap error, got %v", err)
+	}
+	err = store.Save(ctx, subnet.Allocation{Node: "node-b", Subnet: "10.100.1.0/24"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
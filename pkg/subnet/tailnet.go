@@ -0,0 +1,62 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subnet
+
+import (
+	"context"
+	"fmt"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+// EnabledRoute is a subnet route that is enabled for a device in the
+// Tailnet.
+type EnabledRoute struct {
+	DeviceID string
+	Hostname string
+	Route    netaddr.IPPrefix
+}
+
+// TailnetEnabledRoutes lists the enabled routes for every device in the
+// Tailnet. Default routes (i.e. exit nodes) are ignored since they overlap
+// everything.
+func TailnetEnabledRoutes(ctx context.Context, c cloud.Config) ([]EnabledRoute, error) {
+	devices, err := cloud.GetDevices(ctx, c, cloud.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	routes := []EnabledRoute{}
+	for _, device := range devices.Devices {
+		grr := cloud.GetRoutesRequest{DeviceID: device.ID}
+		rr, err := cloud.GetRoutes(ctx, c, grr)
+		if err != nil {
+			return nil, err
+		}
+		for _, er := range rr.EnabledRoutes {
+			p, err := netaddr.ParseIPPrefix(er)
+			if err != nil {
+				return nil, fmt.Errorf("invalid route enabled for device %s: %w", device.ID, err)
+			}
+			if p.Bits() == 0 {
+				continue
+			}
+			routes = append(routes, EnabledRoute{DeviceID: device.ID, Hostname: device.Hostname, Route: p})
+		}
+	}
+	return routes, nil
+}
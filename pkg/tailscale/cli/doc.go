@@ -15,5 +15,7 @@
 // Package cli provides helpers for making Tailscale-specific CLI scripts.
 //
 // The utilities here:
-// - provide defaults for common CLI flags (e.g. `--api-key`)
+//   - provide defaults for common CLI flags (e.g. `--api-key`)
+//   - read Tailscale API keys, auth keys and OAuth client secrets via secret
+//     providers
 package cli
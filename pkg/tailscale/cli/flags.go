@@ -16,80 +16,36 @@ package cli
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"strings"
-
-	"tailscale.com/client/tailscale"
 
 	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/secret"
 )
 
-const (
-	// debugCurlStatusWithoutPeers is a debug mode representation of the
-	// "status without peers" curl command.
-	debugCurlStatusWithoutPeers = `Calling "status without peers" local API route:
-> curl \
->   --include \
->   --unix-socket /var/run/tailscale/tailscaled.sock \
->   http://no-op-host.invalid/localapi/v0/status?peers=false
-`
-)
-
-// ReadAPIKey reads a Tailscale API key from file if the provided `apiKey`
-// is prefixed with `file:`. It is expected that this value has been passed
-// via a CLI flag such as `--api-key`.
+// ReadAPIKey reads a Tailscale API key via a secret provider if the provided
+// `apiKey` is a secret reference such as `file:{path}`, `env:{VAR}` or
+// `vault:{path}#{field}` (see `pkg/secret` for all providers). Otherwise
+// `apiKey` is used as the literal key. It is expected that this value has
+// been passed via a CLI flag such as `--api-key`.
 func ReadAPIKey(ctx context.Context, apiKey string) (string, error) {
-	if !strings.HasPrefix(apiKey, "file:") {
-		return apiKey, nil
-	}
-
-	filename := strings.TrimPrefix(apiKey, "file:")
-	cli.Printf(ctx, "Reading Tailscale API key from: %s\n", filename)
-	apiKeyBytes, err := os.ReadFile(filename)
-	if err != nil {
-		return "", err
-	}
-
-	return strings.TrimSuffix(string(apiKeyBytes), "\n"), nil
+	return readSecret(ctx, "API key", apiKey)
 }
 
-// DefaultTailnet attempts to determine the locally active Tailnet
-// via the local `tailscaled` API. If `tailnet` is already set, it will be
-// used without checking the default. It is expected that this value has been
-// passed via a CLI flag such as `--tailnet`.
-func DefaultTailnet(ctx context.Context, tailnet string) (string, error) {
-	if tailnet != "" {
-		return tailnet, nil
-	}
-
-	cli.DebugPrintf(ctx, debugCurlStatusWithoutPeers)
-	status, err := tailscale.StatusWithoutPeers(ctx)
-	if err != nil {
-		// Early exit (but don't fail) if `tailscaled` isn't running.
-		if tailscaledNotRunning(err) {
-			cli.DebugPrintf(ctx, "Status Without Peers error: %s\n", err.Error())
-			return "", nil
-		}
-
-		return "", err
-	}
-
-	cli.Printf(ctx, "Inferring Tailnet from magic DNS suffix: %s\n", status.MagicDNSSuffix)
-	return getTailnet(status.MagicDNSSuffix)
+// ReadAuthKey reads a Tailscale auth key (e.g. a one-off key used by
+// `tailscale up --authkey`) using the same secret providers as `ReadAPIKey()`.
+func ReadAuthKey(ctx context.Context, authKey string) (string, error) {
+	return readSecret(ctx, "auth key", authKey)
 }
 
-func tailscaledNotRunning(err error) bool {
-	return strings.HasSuffix(err.Error(), ": connect: no such file or directory")
+// ReadOAuthClientSecret reads a Tailscale OAuth client secret using the same
+// secret providers as `ReadAPIKey()`.
+func ReadOAuthClientSecret(ctx context.Context, clientSecret string) (string, error) {
+	return readSecret(ctx, "OAuth client secret", clientSecret)
 }
 
-// getTailnet parses a magic DNS suffix to determine the Tailnet name. The
-// assumption is that the magic DNS suffix is of the form
-// `{TAILNET}.beta.tailscale.net`.
-func getTailnet(magicDNSSuffix string) (string, error) {
-	if !strings.HasSuffix(magicDNSSuffix, ".beta.tailscale.net") {
-		return "", fmt.Errorf("bad %s", magicDNSSuffix)
+func readSecret(ctx context.Context, description, value string) (string, error) {
+	source := secret.Describe(value)
+	if source != "" {
+		cli.Printf(ctx, "Reading Tailscale %s from: %s\n", description, source)
 	}
-
-	return strings.TrimSuffix(magicDNSSuffix, ".beta.tailscale.net"), nil
+	return secret.Read(ctx, value)
 }
@@ -0,0 +1,139 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+const (
+	// DefaultTailnetAlias is understood by the Tailscale cloud API as the
+	// default Tailnet for the API key (or OAuth client) making the request.
+	DefaultTailnetAlias = "-"
+
+	// legacyMagicDNSSuffix is the suffix used by MagicDNS names before the
+	// introduction of `ts.net` names. The Tailnet name is a prefix of the
+	// MagicDNS suffix, e.g. `example.com.beta.tailscale.net`.
+	legacyMagicDNSSuffix = ".beta.tailscale.net"
+)
+
+// localStatus is the subset of the `GET /localapi/v0/status` local API
+// route used to infer the Tailnet. This is decoded directly (rather than via
+// `ipnstate.Status`) because `CurrentTailnet` is only provided by newer
+// versions of `tailscaled`.
+type localStatus struct {
+	MagicDNSSuffix string          `json:"MagicDNSSuffix"`
+	CurrentTailnet *currentTailnet `json:"CurrentTailnet"`
+}
+
+type currentTailnet struct {
+	Name            string `json:"Name"`
+	MagicDNSSuffix  string `json:"MagicDNSSuffix"`
+	MagicDNSEnabled bool   `json:"MagicDNSEnabled"`
+}
+
+// DefaultTailnet attempts to determine the locally active Tailnet
+// via the local `tailscaled` API. If `tailnet` is already set, it will be
+// used without checking the default. It is expected that this value has been
+// passed via a CLI flag such as `--tailnet`. See `InferTailnet()` for the
+// order in which sources are checked; the source used will be printed.
+func DefaultTailnet(ctx context.Context, tailnet string) (string, error) {
+	inferred, source, err := InferTailnet(ctx, tailnet)
+	if err != nil {
+		return "", err
+	}
+
+	cli.Printf(ctx, "Using Tailnet %q (%s)\n", inferred, source)
+	return inferred, nil
+}
+
+// InferTailnet determines the Tailnet and describes the source used. The
+// sources are checked in order:
+//   - an explicitly provided `tailnet` (this may be the default Tailnet alias
+//     `-` or an organization Tailnet such as `example.com` or `user@gmail.com`)
+//   - the `CurrentTailnet` name reported by the local `tailscaled` API
+//   - a legacy MagicDNS suffix of the form `{TAILNET}.beta.tailscale.net`
+//   - the default Tailnet alias `-`, e.g. when the MagicDNS suffix is a
+//     `ts.net` name (which does not identify the Tailnet), MagicDNS is disabled
+//     or `tailscaled` is not running
+func InferTailnet(ctx context.Context, tailnet string) (string, string, error) {
+	if tailnet != "" {
+		return tailnet, "explicitly provided", nil
+	}
+
+	status, err := getLocalStatus(ctx)
+	if err != nil {
+		// Don't fail if `tailscaled` isn't running, the default alias can
+		// still be used with the cloud API.
+		if tailscaledNotRunning(err) {
+			cli.DebugPrintf(ctx, "Status Without Peers error: %s\n", err.Error())
+			return DefaultTailnetAlias, "default Tailnet alias, local 'tailscaled' API is not running", nil
+		}
+
+		return "", "", err
+	}
+
+	if status.CurrentTailnet != nil && status.CurrentTailnet.Name != "" {
+		return status.CurrentTailnet.Name, "current Tailnet from local 'tailscaled' status", nil
+	}
+
+	magicDNSSuffix := status.MagicDNSSuffix
+	if status.CurrentTailnet != nil && status.CurrentTailnet.MagicDNSSuffix != "" {
+		magicDNSSuffix = status.CurrentTailnet.MagicDNSSuffix
+	}
+	inferred, ok := getTailnet(magicDNSSuffix)
+	if ok {
+		return inferred, fmt.Sprintf("inferred from MagicDNS suffix %q", magicDNSSuffix), nil
+	}
+	if magicDNSSuffix == "" {
+		return DefaultTailnetAlias, "default Tailnet alias, no MagicDNS suffix (MagicDNS may be disabled)", nil
+	}
+	return DefaultTailnetAlias, fmt.Sprintf("default Tailnet alias, MagicDNS suffix %q does not identify the Tailnet", magicDNSSuffix), nil
+}
+
+func getLocalStatus(ctx context.Context) (*localStatus, error) {
+	var status localStatus
+	err := local.StatusJSON(ctx, false, &status)
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+func tailscaledNotRunning(err error) bool {
+	return strings.HasSuffix(err.Error(), ": connect: no such file or directory")
+}
+
+// getTailnet parses a magic DNS suffix to determine the Tailnet name. This
+// is only possible for legacy magic DNS suffixes of the form
+// `{TAILNET}.beta.tailscale.net`; modern suffixes (e.g. `tail1234.ts.net`)
+// are not derived from the Tailnet name.
+func getTailnet(magicDNSSuffix string) (string, bool) {
+	magicDNSSuffix = strings.TrimSuffix(magicDNSSuffix, ".")
+	if !strings.HasSuffix(magicDNSSuffix, legacyMagicDNSSuffix) {
+		return "", false
+	}
+
+	tailnet := strings.TrimSuffix(magicDNSSuffix, legacyMagicDNSSuffix)
+	if tailnet == "" {
+		return "", false
+	}
+	return tailnet, true
+}
@@ -31,6 +31,12 @@ type AuthorizeDeviceRequest struct {
 	Authorized bool   `json:"authorized"`
 }
 
+// DeleteDeviceRequest is the request for the `DELETE /api/v2/device/:d`
+// API route.
+type DeleteDeviceRequest struct {
+	DeviceID string `json:"-"`
+}
+
 // GetRoutesRequest is the request for the `GET /api/v2/device/:d/routes`
 // API route.
 type GetRoutesRequest struct {
@@ -0,0 +1,21 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudtest provides an in-memory stand-in for the Tailscale Cloud
+// API, served via `net/http/httptest`, for use in tests.
+//
+// Only the API routes used by this module are supported: listing devices,
+// authorizing and deleting a device and getting / setting the routes for a
+// device.
+package cloudtest
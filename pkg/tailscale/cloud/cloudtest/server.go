@@ -0,0 +1,249 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloudtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	// Tailnet is the Tailnet used by `Server.Config()`.
+	Tailnet = "example.com"
+	// APIKey is the API key used by `Server.Config()`.
+	APIKey = "tskey-test"
+)
+
+// Device is a device in the fake Tailnet along with its routes.
+type Device struct {
+	cloud.Device
+	AdvertisedRoutes []string
+	EnabledRoutes    []string
+}
+
+// Server is a fake Tailscale Cloud API. It is safe for concurrent use.
+type Server struct {
+	*httptest.Server
+	// AfterGetRoutes (if set) is called after the routes for a device are
+	// read, e.g. to simulate another process reading the same routes. It is
+	// called while the server lock is held, so `d` may be modified but other
+	// `Server` methods must not be called.
+	AfterGetRoutes func(d *Device)
+	// AfterSetRoutes (if set) is called after the enabled routes for a device
+	// are replaced, e.g. to simulate a concurrent write by another process.
+	// It is called while the server lock is held, so `d` may be modified but
+	// other `Server` methods must not be called.
+	AfterSetRoutes func(d *Device)
+
+	mu       sync.Mutex
+	devices  []*Device
+	failures map[string]int
+	requests map[string]int
+}
+
+// NewServer starts a new fake Tailscale Cloud API with no devices. The caller
+// is responsible for calling `Close()`.
+func NewServer() *Server {
+	s := &Server{failures: map[string]int{}, requests: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Config returns a cloud API config that points at this server.
+func (s *Server) Config() cloud.Config {
+	return cloud.Config{Addr: s.URL, Tailnet: Tailnet, APIKey: APIKey}
+}
+
+// AddDevice adds a device to the fake Tailnet.
+func (s *Server) AddDevice(d Device) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.devices = append(s.devices, &d)
+}
+
+// RemoveDevice removes a device from the fake Tailnet.
+func (s *Server) RemoveDevice(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeDevice(id)
+}
+
+// Device returns a copy of a device in the fake Tailnet.
+func (s *Server) Device(id string) (Device, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.findDevice(id)
+	if d == nil {
+		return Device{}, false
+	}
+	copied := *d
+	copied.AdvertisedRoutes = append([]string{}, d.AdvertisedRoutes...)
+	copied.EnabledRoutes = append([]string{}, d.EnabledRoutes...)
+	return copied, true
+}
+
+// SetAdvertisedRoutes replaces the routes advertised by a device, e.g. to
+// simulate `tailscale up --advertise-routes` on another host.
+func (s *Server) SetAdvertisedRoutes(id string, routes ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.findDevice(id)
+	if d != nil {
+		d.AdvertisedRoutes = routes
+	}
+}
+
+// Fail makes the next `n` requests with a matching method and path prefix
+// (e.g. `GET /api/v2/tailnet/`) fail with a 500 status.
+func (s *Server) Fail(methodAndPath string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[methodAndPath] = n
+}
+
+// Requests returns the number of requests made with a matching method and
+// path prefix (e.g. `POST /api/v2/device/`).
+func (s *Server) Requests(methodAndPath string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for key, n := range s.requests {
+		if strings.HasPrefix(key, methodAndPath) {
+			count += n
+		}
+	}
+	return count
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.Method + " " + r.URL.Path
+	s.requests[key]++
+	for prefix, n := range s.failures {
+		if n > 0 && strings.HasPrefix(key, prefix) {
+			s.failures[prefix] = n - 1
+			http.Error(w, `{"message":"injected failure"}`, http.StatusInternalServerError)
+			return
+		}
+	}
+	user, _, ok := r.BasicAuth()
+	if !ok || user != APIKey {
+		http.Error(w, `{"message":"API token invalid"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodGet && r.URL.Path == "/api/v2/tailnet/"+Tailnet+"/devices" {
+		s.getDevices(w)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/v2/device/"), "/")
+	if !strings.HasPrefix(r.URL.Path, "/api/v2/device/") || len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+	d := s.findDevice(parts[0])
+	if d == nil {
+		http.NotFound(w, r)
+		return
+	}
+	suffix := ""
+	if len(parts) == 2 {
+		suffix = parts[1]
+	}
+
+	switch {
+	case r.Method == http.MethodGet && suffix == "routes":
+		response := routesResponse(d)
+		if s.AfterGetRoutes != nil {
+			s.AfterGetRoutes(d)
+		}
+		writeJSON(w, response)
+	case r.Method == http.MethodPost && suffix == "routes":
+		var srr cloud.SetRoutesRequest
+		err := json.NewDecoder(r.Body).Decode(&srr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		d.EnabledRoutes = append([]string{}, srr.Routes...)
+		sort.Strings(d.EnabledRoutes)
+		response := routesResponse(d)
+		if s.AfterSetRoutes != nil {
+			s.AfterSetRoutes(d)
+		}
+		writeJSON(w, response)
+	case r.Method == http.MethodPost && suffix == "authorized":
+		var adr cloud.AuthorizeDeviceRequest
+		err := json.NewDecoder(r.Body).Decode(&adr)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		d.Authorized = adr.Authorized
+		writeJSON(w, cloud.Empty{})
+	case r.Method == http.MethodDelete && suffix == "":
+		s.removeDevice(d.ID)
+		writeJSON(w, cloud.Empty{})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) getDevices(w http.ResponseWriter) {
+	dr := cloud.GetDevicesResponse{Devices: []cloud.Device{}}
+	for _, d := range s.devices {
+		dr.Devices = append(dr.Devices, d.Device)
+	}
+	writeJSON(w, dr)
+}
+
+func (s *Server) findDevice(id string) *Device {
+	for _, d := range s.devices {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *Server) removeDevice(id string) {
+	remaining := []*Device{}
+	for _, d := range s.devices {
+		if d.ID != id {
+			remaining = append(remaining, d)
+		}
+	}
+	s.devices = remaining
+}
+
+func routesResponse(d *Device) cloud.RoutesResponse {
+	return cloud.RoutesResponse{
+		AdvertisedRoutes: append([]string{}, d.AdvertisedRoutes...),
+		EnabledRoutes:    append([]string{}, d.EnabledRoutes...),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
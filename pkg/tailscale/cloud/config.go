@@ -16,7 +16,11 @@ package cloud
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/spf13/pflag"
 
 	tailscalecli "github.com/dhermes/tailsk8s/pkg/tailscale/cli"
 )
@@ -27,6 +31,9 @@ type Config struct {
 	Addr    string
 	Tailnet string
 	APIKey  string
+	OAuth   OAuthConfig
+
+	tokenSource *TokenSource
 }
 
 // NewConfig returns a new `Config` with all relevant defaults provided and
@@ -42,6 +49,49 @@ func NewConfig(opts ...Option) (Config, error) {
 	return c, nil
 }
 
+// AddFlags registers the CLI flags used to populate this config.
+func (c *Config) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(
+		&c.Tailnet,
+		"tailnet",
+		c.Tailnet,
+		"The Tailnet where the device exists; a value will be inferred via the local 'tailscaled' API",
+	)
+	fs.StringVar(
+		&c.APIKey,
+		"api-key",
+		c.APIKey,
+		("The Tailscale API key; may be a secret reference such as \"file:{path}\", " +
+			"\"env:{VAR}\", \"exec:{command}\", \"systemd:{name}\", " +
+			"\"k8s:{namespace}/{name}/{key}\" or \"vault:{path}#{field}\"; " +
+			"can also be set via TAILSK8S_API_KEY or a configuration file"),
+	)
+	fs.StringVar(
+		&c.OAuth.ClientID,
+		"oauth-client-id",
+		c.OAuth.ClientID,
+		"The Tailscale OAuth client ID; if set, OAuth client credentials will be used instead of an API key",
+	)
+	fs.StringVar(
+		&c.OAuth.ClientSecret,
+		"oauth-client-secret",
+		c.OAuth.ClientSecret,
+		"The Tailscale OAuth client secret; may be a secret reference (see --api-key)",
+	)
+	fs.StringVar(
+		&c.OAuth.TokenURL,
+		"oauth-token-url",
+		c.OAuth.TokenURL,
+		"The OAuth token endpoint; defaults to the token endpoint of the Tailscale Cloud API",
+	)
+	fs.StringSliceVar(
+		&c.OAuth.Scopes,
+		"oauth-scopes",
+		c.OAuth.Scopes,
+		"The OAuth scopes to request (comma separated); if omitted, all scopes granted to the client are used",
+	)
+}
+
 // HTTPClient returns an HTTP client associated with this config.
 //
 // NOTE: For now this is just a stub wrapper around `http.DefaultClient` but
@@ -50,29 +100,93 @@ func (Config) HTTPClient() *http.Client {
 	return http.DefaultClient
 }
 
+// SetAuth adds authorization to an outgoing request. When OAuth client
+// credentials are configured, a (cached) access token is sent as a bearer
+// token, otherwise the API key is sent via basic auth.
+func (c Config) SetAuth(ctx context.Context, req *http.Request) error {
+	if !c.OAuth.Enabled() {
+		req.SetBasicAuth(c.APIKey, "")
+		return nil
+	}
+
+	if c.tokenSource == nil {
+		return fmt.Errorf("OAuth client credentials have not been resolved")
+	}
+	token, err := c.tokenSource.Token(ctx)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// DebugCurlAuth is the (redacted) `curl` flag that corresponds to the
+// authorization added by `SetAuth()`.
+func (c Config) DebugCurlAuth() string {
+	if c.OAuth.Enabled() {
+		return `--header "Authorization: Bearer ...redacted OAuth Access Token..."`
+	}
+	return `--user "...redacted API Key...:"`
+}
+
 // Resolve sets defaults based on default conventions or based on the local
 // environment.
 // - `Addr` defaults to `https://api.tailscale.com`
-// - If `APIKey` is prefixed with `file:`, the file will be read from the
-//   filesystem
+// - If `APIKey` is a secret reference (e.g. prefixed with `file:` or `env:`),
+//   the secret will be read via the matching provider
+// - If `OAuth.ClientID` is set, `OAuth.ClientSecret` will be read in the same
+//   way and `OAuth.TokenURL` defaults to `{Addr}/api/v2/oauth/token`
 // - If `Tailnet is unset, the local `tailscaled` API will be used to query
 //   for the magic DNS name.
 func (c *Config) Resolve(ctx context.Context) error {
-	apiKey, err := tailscalecli.ReadAPIKey(ctx, c.APIKey)
-	if err != nil {
-		return err
+	c.Addr = stringDefault(c.Addr, "https://api.tailscale.com")
+
+	if c.OAuth.Enabled() {
+		if c.APIKey != "" {
+			return fmt.Errorf("an API key and OAuth client credentials cannot both be provided")
+		}
+		err := c.resolveOAuth(ctx)
+		if err != nil {
+			return err
+		}
+	} else {
+		if c.APIKey == "" {
+			return fmt.Errorf("either an API key or OAuth client credentials are required")
+		}
+		apiKey, err := tailscalecli.ReadAPIKey(ctx, c.APIKey)
+		if err != nil {
+			return err
+		}
+		c.APIKey = apiKey
 	}
+
 	tailnet, err := tailscalecli.DefaultTailnet(ctx, c.Tailnet)
 	if err != nil {
 		return err
 	}
 
-	c.Addr = stringDefault(c.Addr, "https://api.tailscale.com")
-	c.APIKey = apiKey
 	c.Tailnet = tailnet
 	return nil
 }
 
+func (c *Config) resolveOAuth(ctx context.Context) error {
+	if c.OAuth.ClientSecret == "" {
+		return fmt.Errorf("an OAuth client secret is required when an OAuth client ID is provided")
+	}
+	clientSecret, err := tailscalecli.ReadOAuthClientSecret(ctx, c.OAuth.ClientSecret)
+	if err != nil {
+		return err
+	}
+
+	c.OAuth.ClientSecret = clientSecret
+	c.OAuth.TokenURL = stringDefault(
+		c.OAuth.TokenURL,
+		fmt.Sprintf("%s/api/v2/oauth/token", strings.TrimSuffix(c.Addr, "/")),
+	)
+	c.tokenSource = NewTokenSource(c.OAuth, c.HTTPClient())
+	return nil
+}
+
 func stringDefault(s1, s2 string) string {
 	if s1 == "" {
 		return s2
@@ -33,9 +33,18 @@ const (
 	debugCurlAuthorizeDevice = `Calling "authorize device" cloud API route:
 > curl \
 >   --include \
->   --user "...redacted API Key...:" \
+>   %s \
 >   --data-binary '%s'
 >   %s
+`
+	// debugCurlDeleteDevice is a template to print (in debug mode) the
+	// equivalent curl command to the outgoing request.
+	debugCurlDeleteDevice = `Calling "delete device" cloud API route:
+> curl \
+>   --include \
+>   --request DELETE \
+>   %s \
+>   %s
 `
 )
 
@@ -46,12 +55,21 @@ const (
 type Device struct {
 	Addresses  []string `json:"addresses"`
 	Authorized bool     `json:"authorized"`
-	Hostname   string   `json:"hostname"`
-	ID         string   `json:"id"`
-	Name       string   `json:"name"`
+	// Created is the (RFC 3339) time the device was added to the Tailnet.
+	Created string `json:"created,omitempty"`
+	// Expires is the (RFC 3339) time the device key expires; it is kept as
+	// a string so that an unexpected format can't break decoding devices.
+	Expires           string   `json:"expires,omitempty"`
+	Hostname          string   `json:"hostname"`
+	ID                string   `json:"id"`
+	KeyExpiryDisabled bool     `json:"keyExpiryDisabled,omitempty"`
+	Name              string   `json:"name"`
+	OS                string   `json:"os,omitempty"`
+	Tags              []string `json:"tags,omitempty"`
 }
 
-// AuthorizeDevice marks a device as authorized.
+// AuthorizeDevice marks a device as authorized (or as unauthorized, if
+// `adr.Authorized` is `false`).
 //
 // This is only needed in Tailnets where device authorization is required.
 func AuthorizeDevice(ctx context.Context, c Config, adr AuthorizeDeviceRequest) (*Empty, error) {
@@ -65,12 +83,15 @@ func AuthorizeDevice(ctx context.Context, c Config, adr AuthorizeDeviceRequest)
 		return nil, err
 	}
 
-	cli.DebugPrintf(ctx, debugCurlAuthorizeDevice, string(asJSON), url)
+	cli.DebugPrintf(ctx, debugCurlAuthorizeDevice, c.DebugCurlAuth(), string(asJSON), url)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(asJSON))
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.APIKey, "")
+	err = c.SetAuth(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.HTTPClient().Do(req)
@@ -87,3 +108,36 @@ func AuthorizeDevice(ctx context.Context, c Config, adr AuthorizeDeviceRequest)
 	}
 	return &Empty{}, nil
 }
+
+// DeleteDevice removes a device from the Tailnet.
+func DeleteDevice(ctx context.Context, c Config, ddr DeleteDeviceRequest) (*Empty, error) {
+	url := fmt.Sprintf(
+		"%s/api/v2/device/%s",
+		c.Addr,
+		url.PathEscape(ddr.DeviceID),
+	)
+
+	cli.DebugPrintf(ctx, debugCurlDeleteDevice, c.DebugCurlAuth(), url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = c.SetAuth(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to delete device (status %d, body %q)", resp.StatusCode, body)
+	}
+	return &Empty{}, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+const (
+	// debugCurlOAuthToken is a debug mode representation of the "OAuth
+	// token" curl command.
+	debugCurlOAuthToken = `Calling "OAuth token" cloud API route:
+> curl \
+>   --include \
+>   --data-urlencode "client_id=%s" \
+>   --data-urlencode "client_secret=...redacted OAuth Client Secret..." \
+>   --data-urlencode "grant_type=client_credentials" \
+>   %s
+`
+	// tokenExpiryDelta is how long before the actual expiry a cached access
+	// token will be refreshed (to avoid using a token that expires in flight).
+	tokenExpiryDelta = time.Minute
+)
+
+// OAuthConfig provides OAuth client credentials for the Tailscale Cloud API.
+// OAuth clients do not expire (unlike API keys) and are not tied to a
+// specific user.
+type OAuthConfig struct {
+	ClientID     string
+	ClientSecret string
+	// TokenURL defaults to `{Addr}/api/v2/oauth/token`.
+	TokenURL string
+	Scopes   []string
+}
+
+// Enabled indicates that OAuth client credentials should be used.
+func (oc OAuthConfig) Enabled() bool {
+	return oc.ClientID != ""
+}
+
+// TokenResponse is the response for the `POST /api/v2/oauth/token` API route.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// TokenSource exchanges OAuth client credentials for access tokens via the
+// client credentials grant. Access tokens are cached and refreshed shortly
+// before they expire. A `TokenSource` is safe for concurrent use.
+type TokenSource struct {
+	Config     OAuthConfig
+	HTTPClient *http.Client
+	// Now is used to determine token expiry; defaults to `time.Now`.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewTokenSource returns a new token source for a set of OAuth client
+// credentials.
+func NewTokenSource(oc OAuthConfig, hc *http.Client) *TokenSource {
+	return &TokenSource{Config: oc, HTTPClient: hc, Now: time.Now}
+}
+
+// Token returns a valid access token, fetching a new one if there is no
+// cached token or the cached token is about to expire.
+func (ts *TokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	now := ts.now()
+	if ts.token != "" && now.Add(tokenExpiryDelta).Before(ts.expiry) {
+		return ts.token, nil
+	}
+
+	tr, err := ts.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	if tr.AccessToken == "" {
+		return "", fmt.Errorf("OAuth token response did not contain an access token")
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return "", fmt.Errorf("unsupported OAuth token type %q", tr.TokenType)
+	}
+
+	ts.token = tr.AccessToken
+	ts.expiry = now.Add(time.Duration(tr.ExpiresIn) * time.Second)
+	return ts.token, nil
+}
+
+func (ts *TokenSource) now() time.Time {
+	if ts.Now == nil {
+		return time.Now()
+	}
+	return ts.Now()
+}
+
+func (ts *TokenSource) fetch(ctx context.Context) (*TokenResponse, error) {
+	form := url.Values{}
+	form.Set("client_id", ts.Config.ClientID)
+	form.Set("client_secret", ts.Config.ClientSecret)
+	form.Set("grant_type", "client_credentials")
+	if len(ts.Config.Scopes) > 0 {
+		form.Set("scope", strings.Join(ts.Config.Scopes, " "))
+	}
+
+	cli.DebugPrintf(ctx, debugCurlOAuthToken, ts.Config.ClientID, ts.Config.TokenURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.Config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	hc := ts.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get OAuth token (status %d, body %q)", resp.StatusCode, body)
+	}
+
+	var tr TokenResponse
+	err = json.NewDecoder(resp.Body).Decode(&tr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tr, nil
+}
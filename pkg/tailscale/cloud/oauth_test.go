@@ -0,0 +1,203 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package cloud_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+// tokenServer is a stand-in for the Tailscale OAuth token endpoint. Each
+// successful request issues a new access token `token-{N}`.
+type tokenServer struct {
+	mu        sync.Mutex
+	requests  int
+	status    int
+	expiresIn int64
+}
+
+func (ts *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.requests++
+	if ts.status != 0 && ts.status != http.StatusOK {
+		w.WriteHeader(ts.status)
+		_, _ = io.WriteString(w, `{"message":"invalid client"}`)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil ||
+		r.PostForm.Get("client_id") != "k123" ||
+		r.PostForm.Get("client_secret") != "tskey-client-k123" ||
+		r.PostForm.Get("grant_type") != "client_credentials" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	tr := cloud.TokenResponse{
+		AccessToken: fmt.Sprintf("token-%d", ts.requests),
+		TokenType:   "Bearer",
+		ExpiresIn:   ts.expiresIn,
+	}
+	_ = json.NewEncoder(w).Encode(tr)
+}
+
+func (ts *tokenServer) Requests() int {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.requests
+}
+
+func newTokenSource(url string, now *time.Time) *cloud.TokenSource {
+	oc := cloud.OAuthConfig{ClientID: "k123", ClientSecret: "tskey-client-k123", TokenURL: url}
+	ts := cloud.NewTokenSource(oc, http.DefaultClient)
+	ts.Now = func() time.Time { return *now }
+	return ts
+}
+
+func TestTokenSourceCache(t *testing.T) {
+	ctx := context.Background()
+	handler := &tokenServer{expiresIn: 3600}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	ts := newTokenSource(server.URL, &now)
+
+	token, err := ts.Token(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "token-1" {
+		t.Fatalf("expected %q, got %q", "token-1", token)
+	}
+
+	// Well before expiry, the cached token is used.
+	now = now.Add(30 * time.Minute)
+	token, err = ts.Token(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "token-1" || handler.Requests() != 1 {
+		t.Fatalf("expected cached token, got %q after %d request(s)", token, handler.Requests())
+	}
+}
+
+func TestTokenSourceRefresh(t *testing.T) {
+	ctx := context.Background()
+	handler := &tokenServer{expiresIn: 3600}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	ts := newTokenSource(server.URL, &now)
+
+	_, err := ts.Token(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Inside the one minute margin before expiry, a new token is fetched.
+	now = now.Add(time.Hour - 30*time.Second)
+	token, err := ts.Token(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "token-2" || handler.Requests() != 2 {
+		t.Fatalf("expected refreshed token, got %q after %d request(s)", token, handler.Requests())
+	}
+}
+
+func TestTokenSourceError(t *testing.T) {
+	ctx := context.Background()
+	handler := &tokenServer{status: http.StatusUnauthorized}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	ts := newTokenSource(server.URL, &now)
+
+	_, err := ts.Token(ctx)
+	if err == nil || !strings.Contains(err.Error(), "status 401") {
+		t.Fatalf("expected status 401 error, got %v", err)
+	}
+}
+
+func TestSetAuthBearer(t *testing.T) {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	handler := &tokenServer{expiresIn: 3600}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	c := cloud.Config{
+		Addr:    "https://api.tailscale.invalid",
+		Tailnet: "example.com",
+		OAuth: cloud.OAuthConfig{
+			ClientID:     "k123",
+			ClientSecret: "tskey-client-k123",
+			TokenURL:     server.URL,
+		},
+	}
+	err := c.Resolve(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequest(http.MethodGet, "https://api.tailscale.invalid/api/v2/tailnet/example.com/devices", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		err = c.SetAuth(ctx, req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		authorization := req.Header.Get("Authorization")
+		if authorization != "Bearer token-1" {
+			t.Fatalf("expected %q, got %q", "Bearer token-1", authorization)
+		}
+	}
+	if handler.Requests() != 1 {
+		t.Fatalf("expected 1 token request, got %d", handler.Requests())
+	}
+}
+
+func TestSetAuthAPIKey(t *testing.T) {
+	ctx := context.Background()
+	c := cloud.Config{APIKey: "tskey-api-k456"}
+	req, err := http.NewRequest(http.MethodGet, "https://api.tailscale.invalid/api/v2/tailnet/example.com/devices", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	err = c.SetAuth(ctx, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	username, password, ok := req.BasicAuth()
+	if !ok || username != "tskey-api-k456" || password != "" {
+		t.Fatalf("expected basic auth with API key, got %q %q %t", username, password, ok)
+	}
+}
@@ -14,8 +14,45 @@
 
 package remix
 
+import (
+	"time"
+)
+
 // GetDeviceByHostnameRequest is the request for a fictional route that
 // queries for a **single** device by name.
 type GetDeviceByHostnameRequest struct {
 	Hostname string `json:"-"`
 }
+
+// GetDevicesBySelectorRequest is the request for a fictional route that
+// queries for devices matching **exact** hostnames and / or a tag.
+type GetDevicesBySelectorRequest struct {
+	Hostnames []string `json:"-"`
+	Tag       string   `json:"-"`
+}
+
+// UpdateRoutesRequest is the request for a fictional route that enables and
+// disables **specific** routes for a device, leaving all other enabled routes
+// as-is.
+type UpdateRoutesRequest struct {
+	DeviceID string   `json:"-"`
+	Enable   []string `json:"-"`
+	Disable  []string `json:"-"`
+}
+
+// DeauthorizeDeviceRequest is the request for a fictional route that marks a
+// device as unauthorized, optionally disabling all of its enabled routes
+// first.
+type DeauthorizeDeviceRequest struct {
+	DeviceID      string `json:"-"`
+	DisableRoutes bool   `json:"-"`
+}
+
+// WaitForDeviceRequest is the request for a fictional route that polls until
+// a **single** device matches a name.
+type WaitForDeviceRequest struct {
+	Hostname string        `json:"-"`
+	Timeout  time.Duration `json:"-"`
+	// Interval is the time between polls; defaults to `WaitForDeviceInterval`.
+	Interval time.Duration `json:"-"`
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remix
+
+import (
+	"context"
+
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+// DeauthorizeDevice marks a device as unauthorized. This quarantines the
+// device (it can't communicate with the Tailnet) without deleting it, so its
+// history is retained and it can be authorized again later.
+//
+// If `DisableRoutes` is set, all of the device's enabled routes are disabled
+// **first** so that authorizing the device again does not immediately route
+// traffic to it. Returns the routes that were disabled.
+func DeauthorizeDevice(ctx context.Context, c cloud.Config, req DeauthorizeDeviceRequest) ([]string, error) {
+	disabled := []string{}
+	if req.DisableRoutes {
+		grr := cloud.GetRoutesRequest{DeviceID: req.DeviceID}
+		rr, err := cloud.GetRoutes(ctx, c, grr)
+		if err != nil {
+			return nil, err
+		}
+		if len(rr.EnabledRoutes) > 0 {
+			urr := UpdateRoutesRequest{DeviceID: req.DeviceID, Disable: rr.EnabledRoutes}
+			_, err = UpdateRoutes(ctx, c, urr)
+			if err != nil {
+				return nil, err
+			}
+			disabled = rr.EnabledRoutes
+		}
+	}
+
+	adr := cloud.AuthorizeDeviceRequest{DeviceID: req.DeviceID, Authorized: false}
+	_, err := cloud.AuthorizeDevice(ctx, c, adr)
+	if err != nil {
+		return disabled, err
+	}
+	return disabled, nil
+}
@@ -17,7 +17,11 @@ package remix
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+
+	"inet.af/netaddr"
 
 	"github.com/dhermes/tailsk8s/pkg/cli"
 	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
@@ -33,14 +37,7 @@ func GetDeviceByHostname(ctx context.Context, c cloud.Config, req GetDeviceByHos
 		return nil, err
 	}
 
-	deviceName := fmt.Sprintf("%s.%s", req.Hostname, c.Tailnet)
-	matches := []cloud.Device{}
-	for _, device := range devices.Devices {
-		if device.Hostname == req.Hostname || device.Name == deviceName {
-			matches = append(matches, device)
-		}
-	}
-
+	matches := matchHostname(devices.Devices, req.Hostname, c.Tailnet)
 	if len(matches) != 1 {
 		return nil, fmt.Errorf("could not find unique device matching hostname %q (%d matches)", req.Hostname, len(matches))
 	}
@@ -54,3 +51,90 @@ func GetDeviceByHostname(ctx context.Context, c cloud.Config, req GetDeviceByHos
 	}
 	return &device, nil
 }
+
+// GetDevicesBySelector lists devices that match **any** of the hostnames in
+// the request (matched the same way as `GetDeviceByHostname()`, i.e. never
+// by substring) or that have the tag in the request. It is an error if a
+// requested hostname does not match exactly one device. Devices are sorted by
+// hostname.
+func GetDevicesBySelector(ctx context.Context, c cloud.Config, req GetDevicesBySelectorRequest) ([]cloud.Device, error) {
+	if len(req.Hostnames) == 0 && req.Tag == "" {
+		return nil, errors.New("at least one hostname or a tag is required to select devices")
+	}
+	devices, err := cloud.GetDevices(ctx, c, cloud.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []cloud.Device{}
+	seen := map[string]bool{}
+	for _, hostname := range req.Hostnames {
+		deviceName := fmt.Sprintf("%s.%s", hostname, c.Tailnet)
+		count := 0
+		for _, device := range devices.Devices {
+			if device.Hostname != hostname && device.Name != deviceName {
+				continue
+			}
+			count++
+			if !seen[device.ID] {
+				seen[device.ID] = true
+				matches = append(matches, device)
+			}
+		}
+		if count != 1 {
+			return nil, fmt.Errorf("could not find unique device matching hostname %q (%d matches)", hostname, count)
+		}
+	}
+
+	if req.Tag != "" {
+		for _, device := range devices.Devices {
+			if seen[device.ID] || !hasTag(device, req.Tag) {
+				continue
+			}
+			seen[device.ID] = true
+			matches = append(matches, device)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Hostname < matches[j].Hostname
+	})
+	cli.DebugPrintf(ctx, "Matched %d device(s)\n", len(matches))
+	return matches, nil
+}
+
+// DeviceIPv4 returns the Tailscale IPv4 address of a device.
+func DeviceIPv4(device cloud.Device) (netaddr.IP, error) {
+	for _, address := range device.Addresses {
+		ip, err := netaddr.ParseIP(address)
+		if err != nil {
+			return netaddr.IP{}, fmt.Errorf("invalid address for device %s: %w", device.ID, err)
+		}
+		if ip.Is4() {
+			return ip, nil
+		}
+	}
+	return netaddr.IP{}, fmt.Errorf("device %s (%s) has no IPv4 address", device.ID, device.Hostname)
+}
+
+// matchHostname returns the devices matching `hostname` directly **OR** with
+// a machine name equal to `{hostname}.{tailnet}`.
+func matchHostname(devices []cloud.Device, hostname, tailnet string) []cloud.Device {
+	deviceName := fmt.Sprintf("%s.%s", hostname, tailnet)
+	matches := []cloud.Device{}
+	for _, device := range devices {
+		if device.Hostname == hostname || device.Name == deviceName {
+			matches = append(matches, device)
+		}
+	}
+	return matches
+}
+
+func hasTag(device cloud.Device, tag string) bool {
+	for _, t := range device.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
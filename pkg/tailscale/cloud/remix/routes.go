@@ -0,0 +1,177 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remix
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	// UpdateRoutesAttempts is the number of times `UpdateRoutes()` will
+	// merge and write routes before giving up.
+	UpdateRoutesAttempts = 5
+	// UpdateRoutesBackoff is the base delay between attempts in
+	// `UpdateRoutes()`; the delay grows linearly with each attempt and is
+	// jittered so that concurrent writers don't retry in lockstep.
+	UpdateRoutesBackoff = 500 * time.Millisecond
+)
+
+// UpdateRoutes enables and disables specific routes for a device. Since the
+// cloud API can only replace the **full** list of enabled routes, this is a
+// read-modify-write; two concurrent writers can drop each other's changes.
+// To detect this, the routes are re-read after each write and if the
+// requested changes were lost, the merge is retried (up to
+// `UpdateRoutesAttempts` times). Within a single process, updates for the
+// same device are also serialized; this does nothing for writers in other
+// processes (e.g. a join script racing the route controller), which rely
+// entirely on the re-read and retry.
+//
+// Returns the routes as verified after the final write.
+func UpdateRoutes(ctx context.Context, c cloud.Config, req UpdateRoutesRequest) (*cloud.RoutesResponse, error) {
+	unlock := lockDevice(req.DeviceID)
+	defer unlock()
+
+	grr := cloud.GetRoutesRequest{DeviceID: req.DeviceID}
+	for attempt := 1; attempt <= UpdateRoutesAttempts; attempt++ {
+		rr, err := cloud.GetRoutes(ctx, c, grr)
+		if err != nil {
+			return nil, err
+		}
+		if routesApplied(rr.EnabledRoutes, req) {
+			return rr, nil
+		}
+
+		routes := MergeRoutes(rr.EnabledRoutes, req.Enable, req.Disable)
+		srr := cloud.SetRoutesRequest{DeviceID: req.DeviceID, Routes: routes}
+		_, err = cloud.SetRoutes(ctx, c, srr)
+		if err != nil {
+			return nil, err
+		}
+
+		// Verify the write was not clobbered by a concurrent writer.
+		rr, err = cloud.GetRoutes(ctx, c, grr)
+		if err != nil {
+			return nil, err
+		}
+		if routesApplied(rr.EnabledRoutes, req) {
+			return rr, nil
+		}
+
+		if attempt == UpdateRoutesAttempts {
+			break
+		}
+		cli.Printf(ctx, "Routes for device %s were changed concurrently; retrying (attempt %d of %d)\n", req.DeviceID, attempt+1, UpdateRoutesAttempts)
+		err = sleep(ctx, jitter(time.Duration(attempt)*UpdateRoutesBackoff))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("routes for device %s were changed concurrently %d times; giving up", req.DeviceID, UpdateRoutesAttempts)
+}
+
+// MergeRoutes adds `enable` to and removes `disable` from `routes`, keeping
+// the existing order and skipping duplicates.
+func MergeRoutes(routes, enable, disable []string) []string {
+	remove := map[string]bool{}
+	for _, r := range disable {
+		remove[r] = true
+	}
+
+	merged := make([]string, 0, len(routes)+len(enable))
+	seen := map[string]bool{}
+	for _, r := range append(append([]string{}, routes...), enable...) {
+		if remove[r] || seen[r] {
+			continue
+		}
+		seen[r] = true
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// routesApplied determines if all of the requested changes are reflected in
+// `enabled`.
+func routesApplied(enabled []string, req UpdateRoutesRequest) bool {
+	has := map[string]bool{}
+	for _, r := range enabled {
+		has[r] = true
+	}
+	for _, r := range req.Enable {
+		if !has[r] {
+			return false
+		}
+	}
+	for _, r := range req.Disable {
+		if has[r] {
+			return false
+		}
+	}
+	return true
+}
+
+// deviceLocks serializes `UpdateRoutes()` calls for the same device within
+// this process.
+var deviceLocks = struct {
+	sync.Mutex
+	locks map[string]*sync.Mutex
+}{locks: map[string]*sync.Mutex{}}
+
+// lockDevice acquires the process-wide lock for a device and returns a
+// function that releases it.
+func lockDevice(deviceID string) func() {
+	deviceLocks.Lock()
+	mu, ok := deviceLocks.locks[deviceID]
+	if !ok {
+		mu = &sync.Mutex{}
+		deviceLocks.locks[deviceID] = mu
+	}
+	deviceLocks.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// jitterRand is seeded per process (vs. the deterministically seeded global
+// source) so that writers in **different** processes get different delays.
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+
+// jitter returns a random duration in `[d/2, 3d/2)`.
+func jitter(d time.Duration) time.Duration {
+	jitterRand.Lock()
+	defer jitterRand.Unlock()
+	return d/2 + time.Duration(jitterRand.Int63n(int64(d)))
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remix_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/cloudtest"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/remix"
+)
+
+func TestUpdateRoutesConcurrent(t *testing.T) {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	server := cloudtest.NewServer()
+	defer server.Close()
+
+	const writers = 16
+	const foreignWrites = 3
+	advertised := []string{}
+	initial := []string{}
+	for i := 0; i < writers; i++ {
+		advertised = append(advertised, fmt.Sprintf("10.100.%d.0/24", i), fmt.Sprintf("10.200.%d.0/24", i))
+		initial = append(initial, fmt.Sprintf("10.200.%d.0/24", i))
+	}
+	for i := 0; i < foreignWrites; i++ {
+		advertised = append(advertised, fmt.Sprintf("10.50.%d.0/24", i))
+	}
+	server.AddDevice(cloudtest.Device{
+		Device:           cloud.Device{ID: "dev-a", Hostname: "node-a", Authorized: true},
+		AdvertisedRoutes: advertised,
+		EnabledRoutes:    initial,
+	})
+
+	// Writers in this process are serialized by `UpdateRoutes()`, so simulate
+	// another process (e.g. a join script) doing its own read-modify-write:
+	// it reads the routes alongside one of our reads and writes its (now
+	// stale) merge right after our next write, dropping our change. Each of
+	// these lost updates must be caught by the re-read and retried.
+	var stale []string
+	foreign := 0
+	server.AfterGetRoutes = func(d *cloudtest.Device) {
+		if stale == nil && foreign < foreignWrites {
+			stale = append([]string{}, d.EnabledRoutes...)
+		}
+	}
+	server.AfterSetRoutes = func(d *cloudtest.Device) {
+		if stale == nil {
+			return
+		}
+		d.EnabledRoutes = remix.MergeRoutes(stale, []string{fmt.Sprintf("10.50.%d.0/24", foreign)}, nil)
+		stale = nil
+		foreign++
+	}
+
+	// Each writer enables one `10.100.*` route and disables one `10.200.*`
+	// route, all at the same time.
+	errs := make([]error, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := remix.UpdateRoutesRequest{
+				DeviceID: "dev-a",
+				Enable:   []string{fmt.Sprintf("10.100.%d.0/24", i)},
+				Disable:  []string{fmt.Sprintf("10.200.%d.0/24", i)},
+			}
+			_, errs[i] = remix.UpdateRoutes(ctx, server.Config(), req)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("writer %d: unexpected error: %v", i, err)
+		}
+	}
+
+	d, _ := server.Device("dev-a")
+	expected := []string{}
+	for i := 0; i < writers; i++ {
+		expected = append(expected, fmt.Sprintf("10.100.%d.0/24", i))
+	}
+	for i := 0; i < foreignWrites; i++ {
+		expected = append(expected, fmt.Sprintf("10.50.%d.0/24", i))
+	}
+	sort.Strings(expected)
+	if fmt.Sprint(d.EnabledRoutes) != fmt.Sprint(expected) {
+		t.Fatalf("expected enabled routes %v, got %v", expected, d.EnabledRoutes)
+	}
+	// Every foreign write clobbered exactly one of our writes, which must have
+	// been detected and retried.
+	if posts := server.Requests("POST /api/v2/device/dev-a/routes"); posts != writers+foreignWrites {
+		t.Fatalf("expected %d writes, got %d", writers+foreignWrites, posts)
+	}
+}
+
+func TestUpdateRoutesRetriesAfterClobber(t *testing.T) {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	server := cloudtest.NewServer()
+	defer server.Close()
+	server.AddDevice(cloudtest.Device{
+		Device:           cloud.Device{ID: "dev-a", Hostname: "node-a", Authorized: true},
+		AdvertisedRoutes: []string{"10.100.1.0/24", "10.100.2.0/24"},
+		EnabledRoutes:    []string{"10.100.2.0/24"},
+	})
+
+	// Simulate another process writing a stale list of routes right after
+	// the first write (which drops the newly enabled route).
+	clobbered := false
+	server.AfterSetRoutes = func(d *cloudtest.Device) {
+		if clobbered {
+			return
+		}
+		clobbered = true
+		d.EnabledRoutes = []string{"10.100.2.0/24"}
+	}
+
+	req := remix.UpdateRoutesRequest{DeviceID: "dev-a", Enable: []string{"10.100.1.0/24"}}
+	rr, err := remix.UpdateRoutes(ctx, server.Config(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{"10.100.1.0/24", "10.100.2.0/24"}
+	if fmt.Sprint(rr.EnabledRoutes) != fmt.Sprint(expected) {
+		t.Fatalf("expected enabled routes %v, got %v", expected, rr.EnabledRoutes)
+	}
+	if posts := server.Requests("POST /api/v2/device/dev-a/routes"); posts != 2 {
+		t.Fatalf("expected 2 writes, got %d", posts)
+	}
+}
+
+func TestMergeRoutes(t *testing.T) {
+	merged := remix.MergeRoutes(
+		[]string{"10.100.1.0/24", "10.100.2.0/24", "10.100.1.0/24"},
+		[]string{"10.100.3.0/24", "10.100.2.0/24"},
+		[]string{"10.100.1.0/24"},
+	)
+	expected := []string{"10.100.2.0/24", "10.100.3.0/24"}
+	if fmt.Sprint(merged) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, merged)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+const (
+	// WaitForDeviceInterval is the default time between polls in
+	// `WaitForDevice()`.
+	WaitForDeviceInterval = 2 * time.Second
+)
+
+// WaitForDevice polls the devices in the Tailnet until a device matches the
+// hostname (in the same way as `GetDeviceByHostname()`) or `Timeout` elapses.
+// This handles the window just after `tailscale up` where a new device is not
+// yet listed.
+//
+// While a machine re-joins, the Tailnet may briefly list **multiple** devices
+// with the same hostname (e.g. the stale device and the new one). In that
+// case, if exactly one of the matches is unauthorized it is assumed to be the
+// new device and is returned. Otherwise, authorized matches created before
+// the wait started are assumed to be stale and are skipped, as long as a newer
+// match exists (e.g. a device brought up with a pre-authorized auth key). If
+// no single device stands out, polling continues until only one device
+// matches. A **single** match is always returned, even if it is authorized
+// and was created before the wait started (e.g. when re-running a
+// provisioning script), so the caller can treat it as already authorized.
+//
+// Failures to list devices are retried until `Timeout` elapses.
+func WaitForDevice(ctx context.Context, c cloud.Config, req WaitForDeviceRequest) (*cloud.Device, error) {
+	interval := req.Interval
+	if interval <= 0 {
+		interval = WaitForDeviceInterval
+	}
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, req.Timeout)
+	defer cancel()
+
+	status := ""
+	for {
+		current := ""
+		devices, err := cloud.GetDevices(ctx, c, cloud.Empty{})
+		if err == nil {
+			matches := matchHostname(devices.Devices, req.Hostname, c.Tailnet)
+			var device *cloud.Device
+			device, current = pickDevice(matches, start)
+			if device != nil {
+				if len(matches) > 1 {
+					cli.Printf(ctx, "Using device %s; %s\n", device.ID, current)
+				}
+				return device, nil
+			}
+		} else if ctx.Err() == nil {
+			current = fmt.Sprintf("failed to list devices: %v", err)
+		}
+		if current != "" && current != status {
+			cli.Printf(ctx, "Waiting for device %q: %s\n", req.Hostname, current)
+			status = current
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("timed out after %s waiting for a unique device matching hostname %q (%s)", req.Timeout, req.Hostname, status)
+			}
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// pickDevice chooses the device from the matches, if possible, and describes
+// the matches. When there are multiple matches, authorized devices created
+// before `start` are considered stale.
+func pickDevice(matches []cloud.Device, start time.Time) (*cloud.Device, string) {
+	if len(matches) == 0 {
+		return nil, "no matching devices yet"
+	}
+	if len(matches) == 1 {
+		return &matches[0], "1 matching device"
+	}
+
+	ids := make([]string, 0, len(matches))
+	unauthorized := []cloud.Device{}
+	fresh := []cloud.Device{}
+	for _, device := range matches {
+		ids = append(ids, device.ID)
+		if !device.Authorized {
+			unauthorized = append(unauthorized, device)
+		}
+		if !isStale(device, start) {
+			fresh = append(fresh, device)
+		}
+	}
+	description := fmt.Sprintf("%d devices share the hostname (%s)", len(matches), strings.Join(ids, ", "))
+	if len(unauthorized) == 1 {
+		return &unauthorized[0], description
+	}
+	if len(fresh) == 1 {
+		return &fresh[0], description
+	}
+	return nil, description
+}
+
+// isStale determines if an authorized device was created before `start`. If
+// the creation time is missing or can't be parsed, the device is assumed to
+// not be stale.
+func isStale(device cloud.Device, start time.Time) bool {
+	if !device.Authorized {
+		return false
+	}
+	created, err := time.Parse(time.RFC3339, device.Created)
+	if err != nil {
+		return false
+	}
+	return created.Before(start)
+}
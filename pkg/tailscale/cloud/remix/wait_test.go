@@ -0,0 +1,110 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remix_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/cloudtest"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/remix"
+)
+
+func TestWaitForDeviceSkipsStale(t *testing.T) {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	server := cloudtest.NewServer()
+	defer server.Close()
+	created := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	server.AddDevice(cloudtest.Device{
+		Device: cloud.Device{ID: "dev-old", Hostname: "node-a", Authorized: true, Created: created},
+	})
+	// The re-joined device was brought up with a pre-authorized auth key.
+	server.AddDevice(cloudtest.Device{
+		Device: cloud.Device{ID: "dev-new", Hostname: "node-a", Authorized: true, Created: time.Now().Add(time.Minute).UTC().Format(time.RFC3339)},
+	})
+
+	req := remix.WaitForDeviceRequest{Hostname: "node-a", Timeout: 5 * time.Second, Interval: 10 * time.Millisecond}
+	device, err := remix.WaitForDevice(ctx, server.Config(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if device.ID != "dev-new" {
+		t.Fatalf("expected device %q, got %q", "dev-new", device.ID)
+	}
+}
+
+func TestWaitForDeviceSingleStaleMatch(t *testing.T) {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	server := cloudtest.NewServer()
+	defer server.Close()
+	created := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	server.AddDevice(cloudtest.Device{
+		Device: cloud.Device{ID: "dev-old", Hostname: "node-a", Authorized: true, Created: created},
+	})
+
+	// E.g. a re-run of a provisioning script; the only match must be returned
+	// (not waited out) so it can be reported as already authorized.
+	req := remix.WaitForDeviceRequest{Hostname: "node-a", Timeout: 5 * time.Second, Interval: 10 * time.Millisecond}
+	device, err := remix.WaitForDevice(ctx, server.Config(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if device.ID != "dev-old" || !device.Authorized {
+		t.Fatalf("expected authorized device %q, got %+v", "dev-old", device)
+	}
+	if gets := server.Requests("GET /api/v2/tailnet/"); gets != 1 {
+		t.Fatalf("expected 1 attempt, got %d", gets)
+	}
+}
+
+func TestWaitForDeviceRetriesErrors(t *testing.T) {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	server := cloudtest.NewServer()
+	defer server.Close()
+	server.AddDevice(cloudtest.Device{
+		Device: cloud.Device{ID: "dev-a", Hostname: "node-a"},
+	})
+	server.Fail("GET /api/v2/tailnet/", 2)
+
+	req := remix.WaitForDeviceRequest{Hostname: "node-a", Timeout: 5 * time.Second, Interval: 10 * time.Millisecond}
+	device, err := remix.WaitForDevice(ctx, server.Config(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if device.ID != "dev-a" {
+		t.Fatalf("expected device %q, got %q", "dev-a", device.ID)
+	}
+	if gets := server.Requests("GET /api/v2/tailnet/"); gets != 3 {
+		t.Fatalf("expected 3 attempts, got %d", gets)
+	}
+}
+
+func TestWaitForDeviceErrorTimeout(t *testing.T) {
+	ctx := cli.WithStdout(context.Background(), io.Discard)
+	server := cloudtest.NewServer()
+	defer server.Close()
+	server.Fail("GET /api/v2/tailnet/", 1000)
+
+	req := remix.WaitForDeviceRequest{Hostname: "node-a", Timeout: 100 * time.Millisecond, Interval: 10 * time.Millisecond}
+	_, err := remix.WaitForDevice(ctx, server.Config(), req)
+	if err == nil || !strings.Contains(err.Error(), "failed to list devices") {
+		t.Fatalf("expected timeout with the last error, got %v", err)
+	}
+}
@@ -30,7 +30,7 @@ const (
 	debugCurlGetRoutes = `Calling "get routes" cloud API route:
 > curl \
 >   --include \
->   --user "...redacted API Key...:" \
+>   %s \
 >   %s
 `
 	// debugCurlSetRoutes is a template to print (in debug mode) the
@@ -39,7 +39,7 @@ const (
 	debugCurlSetRoutes = `Calling "set routes" cloud API route:
 > curl \
 >   --include \
->   --user "...redacted API Key...:" \
+>   %s \
 >   --data-binary '%s'
 >   %s
 `
@@ -52,12 +52,15 @@ func GetRoutes(ctx context.Context, c Config, grr GetRoutesRequest) (*RoutesResp
 		c.Addr,
 		url.PathEscape(grr.DeviceID),
 	)
-	cli.DebugPrintf(ctx, debugCurlGetRoutes, url)
+	cli.DebugPrintf(ctx, debugCurlGetRoutes, c.DebugCurlAuth(), url)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.APIKey, "")
+	err = c.SetAuth(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := c.HTTPClient().Do(req)
 	if err != nil {
 		return nil, err
@@ -93,12 +96,15 @@ func SetRoutes(ctx context.Context, c Config, srr SetRoutesRequest) (*RoutesResp
 		return nil, err
 	}
 
-	cli.DebugPrintf(ctx, debugCurlSetRoutes, string(asJSON), url)
+	cli.DebugPrintf(ctx, debugCurlSetRoutes, c.DebugCurlAuth(), string(asJSON), url)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(asJSON))
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.APIKey, "")
+	err = c.SetAuth(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.HTTPClient().Do(req)
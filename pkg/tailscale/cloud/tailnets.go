@@ -29,7 +29,7 @@ const (
 	debugCurlGetDevices = `Calling "get devices in Tailnet" cloud API route:
 > curl \
 >   --include \
->   --user "...redacted API Key...:" \
+>   %s \
 >   %s
 `
 )
@@ -41,12 +41,15 @@ func GetDevices(ctx context.Context, c Config, _ Empty) (*GetDevicesResponse, er
 		c.Addr,
 		url.PathEscape(c.Tailnet),
 	)
-	cli.DebugPrintf(ctx, debugCurlGetDevices, url)
+	cli.DebugPrintf(ctx, debugCurlGetDevices, c.DebugCurlAuth(), url)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.APIKey, "")
+	err = c.SetAuth(ctx, req)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := c.HTTPClient().Do(req)
 	if err != nil {
 		return nil, err
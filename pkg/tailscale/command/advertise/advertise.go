@@ -26,7 +26,8 @@ import (
 
 // AdvertiseAndAccept first uses the local `tailscaled` API to advertise a
 // new CIDR to the Tailnet and then uses the cloud API to accept the newly
-// added CIDR.
+// added CIDR. If accepting fails, the local change is rolled back (unless
+// `NoRollback` is set).
 func AdvertiseAndAccept(ctx context.Context, c Config) error {
 	err := c.APIConfig.Resolve(ctx)
 	if err != nil {
@@ -37,8 +38,7 @@ func AdvertiseAndAccept(ctx context.Context, c Config) error {
 	if err != nil {
 		return err
 	}
-
-	err = EditPrefsAdvertiseCIDR(ctx, cidr)
+	err = ValidateCIDR(cidr, c)
 	if err != nil {
 		return err
 	}
@@ -50,9 +50,25 @@ func AdvertiseAndAccept(ctx context.Context, c Config) error {
 	}
 	cli.Printf(ctx, "Using hostname: %s\n", hostname)
 
+	// Check for overlaps **before** advertising, so a conflicting CIDR is
+	// never advertised.
+	err = CheckRouteOverlaps(ctx, c.APIConfig, cidr, hostname, c.AllowOverlap)
+	if err != nil {
+		return err
+	}
+
+	edit, err := EditPrefsAdvertiseCIDR(ctx, cidr)
+	if err != nil {
+		return err
+	}
+
 	// TODO: Remove this sleep once a more sane way of handling the race
 	//       condition between the local change above and the remote change below.
 	time.Sleep(5 * time.Second)
 
-	return AcceptNewCIDR(ctx, c.APIConfig, cidr, hostname)
+	err = AcceptNewCIDR(ctx, c.APIConfig, cidr, hostname)
+	if err != nil {
+		return Rollback(ctx, edit, c.NoRollback, err)
+	}
+	return nil
 }
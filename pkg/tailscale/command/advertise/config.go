@@ -18,11 +18,40 @@ import (
 	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
 )
 
+const (
+	// DefaultBridge is the name of the Linux bridge created on the node by
+	// the CNI plugin; this must agree with `cni.DefaultBridge`.
+	DefaultBridge = "cnio0"
+)
+
 // Config provides the core set of (CLI) inputs needed to authorize a new
 // device in a Tailnet.
 type Config struct {
 	APIConfig cloud.Config
 	IPv4CIDR  string
+	// AllowOverlap allows advertising a CIDR that overlaps a route enabled
+	// for another device.
+	AllowOverlap bool
+	// PodSubnet (e.g. the cluster `podSubnet`) is the supernet all advertised
+	// CIDRs must be contained in; if empty, any CIDR is allowed.
+	PodSubnet string
+	// Bridge is the CNI bridge; its addresses are expected to be in the
+	// advertised CIDR so it is ignored when checking local interfaces.
+	Bridge string
+	// AllowReserved allows advertising a CIDR that overlaps the ranges
+	// Tailscale allocates device addresses from.
+	AllowReserved bool
+	// AllowDefaultRoute allows advertising a default route (e.g. `0.0.0.0/0`).
+	AllowDefaultRoute bool
+	// AllowLocalOverlap allows advertising a CIDR that overlaps an address on
+	// a local network interface.
+	AllowLocalOverlap bool
+	// AllowOutsidePodSubnet allows advertising a CIDR that is not contained
+	// in `PodSubnet`.
+	AllowOutsidePodSubnet bool
+	// NoRollback leaves local preferences modified if enabling the route in
+	// the cloud API fails.
+	NoRollback bool
 }
 
 // NewConfig returns a new `Config` with all relevant defaults provided and
@@ -33,7 +62,7 @@ func NewConfig(opts ...Option) (Config, error) {
 		return Config{}, err
 	}
 
-	c := Config{APIConfig: ac}
+	c := Config{APIConfig: ac, Bridge: DefaultBridge}
 	for _, opt := range opts {
 		err := opt(&c)
 		if err != nil {
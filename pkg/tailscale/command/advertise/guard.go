@@ -0,0 +1,152 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advertise
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"inet.af/netaddr"
+)
+
+var (
+	// TailscaleCGNATRange is the range Tailscale allocates IPv4 addresses
+	// for devices from.
+	TailscaleCGNATRange = netaddr.MustParseIPPrefix("100.64.0.0/10")
+	// TailscaleULARange is the range Tailscale allocates IPv6 addresses for
+	// devices from.
+	TailscaleULARange = netaddr.MustParseIPPrefix("fd7a:115c:a1e0::/48")
+)
+
+// ValidateCIDR applies guard rails to a CIDR before it is advertised. The
+// CIDR is rejected if it
+//
+//   - overlaps a range reserved by Tailscale (unless `AllowReserved`)
+//   - is a default route (unless `AllowDefaultRoute`); exit nodes are
+//     advertised via the `exitnode` package instead
+//   - overlaps an address on a local interface (unless `AllowLocalOverlap`)
+//   - is not contained in `PodSubnet`, if set (unless `AllowOutsidePodSubnet`)
+func ValidateCIDR(cidr netaddr.IPPrefix, c Config) error {
+	if !cidr.IsValid() {
+		return fmt.Errorf("invalid CIDR %q", cidr)
+	}
+	if cidr != cidr.Masked() {
+		return fmt.Errorf("CIDR %s has host bits set; did you mean %s", cidr, cidr.Masked())
+	}
+
+	if cidr.Bits() == 0 {
+		if c.AllowDefaultRoute {
+			return nil
+		}
+		return fmt.Errorf("CIDR %s is a default route; advertise an exit node instead", cidr)
+	}
+
+	if !c.AllowReserved {
+		for _, reserved := range []netaddr.IPPrefix{TailscaleCGNATRange, TailscaleULARange} {
+			if cidr.Overlaps(reserved) {
+				return fmt.Errorf("CIDR %s overlaps %s, which is reserved by Tailscale", cidr, reserved)
+			}
+		}
+	}
+
+	if c.PodSubnet != "" && !c.AllowOutsidePodSubnet {
+		supernet, err := netaddr.ParseIPPrefix(c.PodSubnet)
+		if err != nil {
+			return err
+		}
+		if !ContainsPrefix(supernet, cidr) {
+			return fmt.Errorf("CIDR %s is not contained in the pod subnet %s", cidr, supernet)
+		}
+	}
+
+	if !c.AllowLocalOverlap {
+		addrs, err := LocalAddresses(c.Bridge)
+		if err != nil {
+			return err
+		}
+		conflicts := []string{}
+		for _, la := range addrs {
+			if cidr.Overlaps(la.Prefix) {
+				conflicts = append(conflicts, la.String())
+			}
+		}
+		if len(conflicts) > 0 {
+			return fmt.Errorf("CIDR %s overlaps local interface address(es): %s", cidr, strings.Join(conflicts, ", "))
+		}
+	}
+
+	return nil
+}
+
+// ContainsPrefix determines if `inner` is entirely contained in `outer`.
+func ContainsPrefix(outer, inner netaddr.IPPrefix) bool {
+	return outer.Bits() <= inner.Bits() && outer.Contains(inner.IP())
+}
+
+// LocalAddress is an address assigned to a local network interface, e.g.
+// `192.168.7.131/24` on `eth0`.
+type LocalAddress struct {
+	Interface string
+	Prefix    netaddr.IPPrefix
+}
+
+// String describes the address and the interface it is assigned to.
+func (la LocalAddress) String() string {
+	return fmt.Sprintf("%s (%s)", la.Prefix, la.Interface)
+}
+
+// LocalAddresses returns the (masked) subnets of addresses assigned to local
+// network interfaces. Loopback interfaces, Tailscale interfaces and the
+// interfaces in `skip` (e.g. the CNI bridge, which is expected to have an
+// address in the advertised CIDR) are ignored.
+func LocalAddresses(skip ...string) ([]LocalAddress, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := []LocalAddress{}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 || strings.HasPrefix(iface.Name, "tailscale") || contains(skip, iface.Name) {
+			continue
+		}
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range ifaceAddrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			p, ok := netaddr.FromStdIPNet(ipNet)
+			if !ok || p.IP().IsLinkLocalUnicast() {
+				continue
+			}
+			addrs = append(addrs, LocalAddress{Interface: iface.Name, Prefix: p.Masked()})
+		}
+	}
+	return addrs, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
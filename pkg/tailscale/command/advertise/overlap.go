@@ -0,0 +1,146 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advertise
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"inet.af/netaddr"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+// RouteOverlap describes a route for another device that overlaps a CIDR.
+type RouteOverlap struct {
+	DeviceID string
+	Hostname string
+	Route    netaddr.IPPrefix
+	// Enabled indicates the route is enabled (vs. only advertised); only
+	// enabled routes are used by the Tailnet.
+	Enabled bool
+}
+
+// String describes the overlap relative to `cidr`, e.g.
+// "10.100.0.0/16 (enabled for device 123 (eager-jennings))".
+func (ro RouteOverlap) String() string {
+	state := "advertised"
+	if ro.Enabled {
+		state = "enabled"
+	}
+	return fmt.Sprintf("%s (%s for device %s (%s))", ro.Route, state, ro.DeviceID, ro.Hostname)
+}
+
+// FindRouteOverlaps checks `cidr` against the advertised and enabled routes
+// of every device in the Tailnet other than `hostname`. Default routes (i.e.
+// exit nodes) are ignored.
+func FindRouteOverlaps(ctx context.Context, c cloud.Config, cidr netaddr.IPPrefix, hostname string) ([]RouteOverlap, error) {
+	devices, err := cloud.GetDevices(ctx, c, cloud.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	overlaps := []RouteOverlap{}
+	deviceName := fmt.Sprintf("%s.%s", hostname, c.Tailnet)
+	for _, device := range devices.Devices {
+		if device.Hostname == hostname || device.Name == deviceName {
+			continue
+		}
+		grr := cloud.GetRoutesRequest{DeviceID: device.ID}
+		rr, err := cloud.GetRoutes(ctx, c, grr)
+		if err != nil {
+			return nil, err
+		}
+
+		enabled, err := routeSet(rr.EnabledRoutes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route enabled for device %s: %w", device.ID, err)
+		}
+		advertised, err := routeSet(rr.AdvertisedRoutes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid route advertised by device %s: %w", device.ID, err)
+		}
+		if !enabled.OverlapsPrefix(cidr) && !advertised.OverlapsPrefix(cidr) {
+			continue
+		}
+
+		// Report the specific routes (each route once, enabled takes
+		// precedence over advertised).
+		seen := map[netaddr.IPPrefix]bool{}
+		for _, routes := range [][]string{rr.EnabledRoutes, rr.AdvertisedRoutes} {
+			for _, r := range routes {
+				p, err := netaddr.ParseIPPrefix(r)
+				if err != nil || p.Bits() == 0 || seen[p] || !p.Overlaps(cidr) {
+					continue
+				}
+				seen[p] = true
+				overlaps = append(overlaps, RouteOverlap{
+					DeviceID: device.ID,
+					Hostname: device.Hostname,
+					Route:    p,
+					Enabled:  RoutesContain(rr.EnabledRoutes, p),
+				})
+			}
+		}
+	}
+	return overlaps, nil
+}
+
+// CheckRouteOverlaps fails if `cidr` overlaps (exactly or partially) a route
+// enabled for another device in the Tailnet, since traffic for the overlap
+// would be routed to only one of the devices. Overlaps with routes that are
+// only advertised, or any overlap when `allowOverlap` is set, are printed as
+// warnings.
+func CheckRouteOverlaps(ctx context.Context, c cloud.Config, cidr netaddr.IPPrefix, hostname string, allowOverlap bool) error {
+	overlaps, err := FindRouteOverlaps(ctx, c, cidr, hostname)
+	if err != nil {
+		return err
+	}
+
+	conflicts := []string{}
+	for _, o := range overlaps {
+		if o.Enabled && !allowOverlap {
+			conflicts = append(conflicts, o.String())
+			continue
+		}
+		cli.Printf(ctx, "WARNING: route %s overlaps %s\n", cidr, o)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf(
+			"route %s overlaps route(s) enabled for other devices: %s; use a different CIDR or allow the overlap",
+			cidr, strings.Join(conflicts, ", "),
+		)
+	}
+	return nil
+}
+
+// routeSet parses routes from the Tailscale cloud API into an `IPSet`,
+// ignoring default routes.
+func routeSet(routes []string) (*netaddr.IPSet, error) {
+	var b netaddr.IPSetBuilder
+	for _, r := range routes {
+		p, err := netaddr.ParseIPPrefix(r)
+		if err != nil {
+			return nil, err
+		}
+		if p.Bits() == 0 {
+			continue
+		}
+		b.AddPrefix(p)
+	}
+	return b.IPSet()
+}
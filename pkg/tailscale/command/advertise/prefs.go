@@ -15,41 +15,13 @@
 package advertise
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"os"
-	"os/exec"
-	"path/filepath"
 
 	"inet.af/netaddr"
-	"tailscale.com/client/tailscale"
 	"tailscale.com/ipn"
-	"tailscale.com/types/key"
 
 	"github.com/dhermes/tailsk8s/pkg/cli"
-)
-
-const (
-	// DebugCurlGetPrefs is a debug mode representation of the "get prefs"
-	// curl command.
-	DebugCurlGetPrefs = `Calling "get prefs" local API route:
-> curl \
->   --include \
->   --unix-socket /var/run/tailscale/tailscaled.sock \
->   http://no-op-host.invalid/localapi/v0/prefs
-`
-	// DebugCurlEditPrefs is a template to print (in debug mode) the
-	// equivalent curl command to the outgoing request. The PATCH body is
-	// not expected to be `shlex` quoted by the template user, but it should be.
-	DebugCurlEditPrefs = `Calling "edit prefs" local API route:
-> curl \
->   --include \
->   --request PATCH \
->   --data-binary '%s' \
->   --unix-socket /var/run/tailscale/tailscaled.sock \
->   http://no-op-host.invalid/localapi/v0/prefs
-`
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
 )
 
 // EditPrefsAdvertiseCIDR updates existing Tailscale preferences to
@@ -60,18 +32,24 @@ const (
 //   but also ensures existing routes are not clobbered)
 //
 // If the accept routes flag and the advertised CIDR are both present, this
-// will make no changes.
-func EditPrefsAdvertiseCIDR(ctx context.Context, cidr netaddr.IPPrefix) error {
-	cli.DebugPrintf(ctx, DebugCurlGetPrefs)
-	before, err := tailscale.GetPrefs(ctx)
+// will make no changes. Otherwise, a record of the changes that were made is
+// returned so they can be rolled back.
+func EditPrefsAdvertiseCIDR(ctx context.Context, cidr netaddr.IPPrefix) (*PrefsEdit, error) {
+	unlock, err := local.LockPrefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	before, err := local.GetPrefs(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	hasCIDR := IPPrefixesContain(before.AdvertiseRoutes, cidr)
 	if hasCIDR && before.RouteAll {
 		cli.Println(ctx, "Route already accepted and advertised")
-		return nil
+		return nil, nil
 	}
 
 	patch := &ipn.MaskedPrefs{}
@@ -85,22 +63,13 @@ func EditPrefsAdvertiseCIDR(ctx context.Context, cidr netaddr.IPPrefix) error {
 		patch.AdvertiseRoutesSet = true
 	}
 
-	if cli.GetDebug(ctx) {
-		asJSON, err := json.Marshal(patch)
-		// Ignore error: failure to marshal in debug mode can't break the regular flow.
-		if err != nil {
-			asJSON = []byte("...")
-		}
-		cli.DebugPrintf(ctx, DebugCurlEditPrefs, string(asJSON))
-	}
-
-	after, err := tailscale.EditPrefs(ctx, patch)
+	after, err := local.EditPrefs(ctx, patch)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	DiffBeforeAfter(ctx, before, after)
-	return nil
+	local.PrintPrefsDiff(ctx, before, after)
+	return NewPrefsEdit(before, after), nil
 }
 
 // IPPrefixesContain checks if a CIDR (`IPPrefix`) is contained in a slice of
@@ -114,70 +83,3 @@ func IPPrefixesContain(prefixes []netaddr.IPPrefix, cidr netaddr.IPPrefix) bool
 	}
 	return false
 }
-
-// DiffBeforeAfter writes `before` and `after` to JSON and execs out to
-// `diff` to compare them. If **any** of these steps errors, it will just exit
-// since this is meant to **aid** understanding during debug mode (vs. to
-// provide actual functionality).
-func DiffBeforeAfter(ctx context.Context, before, after *ipn.Prefs) {
-	if !cli.GetDebug(ctx) {
-		return
-	}
-
-	// Clean sensitive fields before writing
-	before.Persist.LegacyFrontendPrivateMachineKey = key.MachinePrivate{}
-	before.Persist.PrivateNodeKey = key.NodePrivate{}
-	before.Persist.OldPrivateNodeKey = key.NodePrivate{}
-	after.Persist.LegacyFrontendPrivateMachineKey = key.MachinePrivate{}
-	after.Persist.PrivateNodeKey = key.NodePrivate{}
-	after.Persist.OldPrivateNodeKey = key.NodePrivate{}
-
-	dir, err := os.MkdirTemp("", "")
-	if err != nil {
-		return
-	}
-
-	// Write `before` to a temp file
-	filenameBefore := filepath.Join(dir, "before.json")
-	asJSON, err := json.MarshalIndent(before, "", "    ")
-	if err != nil {
-		return
-	}
-	err = os.WriteFile(filenameBefore, asJSON, 0644)
-	if err != nil {
-		return
-	}
-
-	// Write `after` to a temp file
-	filenameAfter := filepath.Join(dir, "after.json")
-	asJSON, err = json.MarshalIndent(after, "", "    ")
-	if err != nil {
-		return
-	}
-	err = os.WriteFile(filenameAfter, asJSON, 0644)
-	if err != nil {
-		return
-	}
-
-	// Exec out to `diff` and capture STDOUT
-	cmd := exec.CommandContext(
-		ctx, "diff", "--report-identical-files", "--unified",
-		filenameBefore, filenameAfter,
-	)
-	b := bytes.NewBuffer(nil)
-	cmd.Stdout = b
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
-
-	err = cmd.Run()
-	if err != nil {
-		// If `diff` exits with a status code of 1 or 2, that's OK, it just
-		// means it found some differences.
-		_, ok := err.(*exec.ExitError)
-		if !ok {
-			return
-		}
-	}
-
-	cli.DebugPrintf(ctx, b.String())
-}
@@ -0,0 +1,148 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advertise
+
+import (
+	"context"
+	"fmt"
+
+	"inet.af/netaddr"
+	"tailscale.com/ipn"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+// PrefsEdit records the changes made to `tailscaled` preferences when
+// advertising or withdrawing routes, so that exactly those changes can be
+// undone if a later step fails. Only the changes are recorded (rather than a
+// snapshot of the preferences) so that undoing them can't clobber a route
+// that another process advertised or withdrew in the meantime.
+type PrefsEdit struct {
+	// RouteAllSet indicates that the accept routes flag was changed; it had
+	// the value `RouteAllBefore` prior to the change.
+	RouteAllSet    bool
+	RouteAllBefore bool
+	// Added are the routes that were added to the advertised routes.
+	Added []netaddr.IPPrefix
+	// Removed are the routes that were removed from the advertised routes.
+	Removed []netaddr.IPPrefix
+}
+
+// NewPrefsEdit records the changes between `before` and `after`. Returns
+// `nil` if none of the relevant fields changed.
+func NewPrefsEdit(before, after *ipn.Prefs) *PrefsEdit {
+	pe := &PrefsEdit{
+		RouteAllSet:    before.RouteAll != after.RouteAll,
+		RouteAllBefore: before.RouteAll,
+		Added:          routesDifference(after.AdvertiseRoutes, before.AdvertiseRoutes),
+		Removed:        routesDifference(before.AdvertiseRoutes, after.AdvertiseRoutes),
+	}
+	if !pe.RouteAllSet && len(pe.Added) == 0 && len(pe.Removed) == 0 {
+		return nil
+	}
+	return pe
+}
+
+// Undo applies a compensating patch to the **current** preferences that
+// reverses the recorded changes (and nothing else). A change that has since
+// been reverted by another process is skipped. Each change that is undone is
+// printed.
+func (pe *PrefsEdit) Undo(ctx context.Context) error {
+	unlock, err := local.LockPrefs(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := local.GetPrefs(ctx)
+	if err != nil {
+		return err
+	}
+
+	patch := &ipn.MaskedPrefs{}
+	patch.Prefs = *current.Clone()
+	undone := []string{}
+	if pe.RouteAllSet && current.RouteAll != pe.RouteAllBefore {
+		patch.Prefs.RouteAll = pe.RouteAllBefore
+		patch.RouteAllSet = true
+		undone = append(undone, fmt.Sprintf("restore accept routes to %t", pe.RouteAllBefore))
+	}
+	routes := []netaddr.IPPrefix{}
+	for _, r := range current.AdvertiseRoutes {
+		if IPPrefixesContain(pe.Added, r) {
+			patch.AdvertiseRoutesSet = true
+			undone = append(undone, fmt.Sprintf("stop advertising route %s", r))
+			continue
+		}
+		routes = append(routes, r)
+	}
+	for _, r := range pe.Removed {
+		if !IPPrefixesContain(current.AdvertiseRoutes, r) {
+			routes = append(routes, r)
+			patch.AdvertiseRoutesSet = true
+			undone = append(undone, fmt.Sprintf("advertise route %s again", r))
+		}
+	}
+	if len(undone) == 0 {
+		cli.Println(ctx, "Nothing to roll back in local preferences")
+		return nil
+	}
+	if patch.AdvertiseRoutesSet {
+		patch.Prefs.AdvertiseRoutes = routes
+	}
+
+	cli.Println(ctx, "Rolling back local preferences:")
+	for _, u := range undone {
+		cli.Printf(ctx, "- %s\n", u)
+	}
+	_, err = local.EditPrefs(ctx, patch)
+	if err != nil {
+		return err
+	}
+	cli.Println(ctx, "Rolled back local preferences")
+	return nil
+}
+
+// routesDifference returns the routes in `a` that are not in `b`.
+func routesDifference(a, b []netaddr.IPPrefix) []netaddr.IPPrefix {
+	difference := []netaddr.IPPrefix{}
+	for _, r := range a {
+		if !IPPrefixesContain(b, r) {
+			difference = append(difference, r)
+		}
+	}
+	return difference
+}
+
+// Rollback is intended to be called when a step fails **after** local
+// preferences have been modified. It undoes `edit` (unless `noRollback` is
+// set) and returns `cause`, annotated if the rollback itself also fails. A
+// `nil` edit means local preferences were not modified.
+func Rollback(ctx context.Context, edit *PrefsEdit, noRollback bool, cause error) error {
+	if edit == nil {
+		return cause
+	}
+	if noRollback {
+		cli.Println(ctx, "WARNING: rollback is disabled, local preferences have been left modified")
+		return cause
+	}
+
+	err := edit.Undo(ctx)
+	if err != nil {
+		return fmt.Errorf("%w (rollback of local preferences also failed: %v)", cause, err)
+	}
+	return cause
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package advertise_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"inet.af/netaddr"
+	"tailscale.com/ipn"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/advertise"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local/localtest"
+)
+
+var (
+	routeA = netaddr.MustParseIPPrefix("10.100.1.0/24")
+	routeB = netaddr.MustParseIPPrefix("10.100.2.0/24")
+	routeC = netaddr.MustParseIPPrefix("10.100.3.0/24")
+)
+
+func newLocalServer(t *testing.T, routeAll bool, routes ...netaddr.IPPrefix) (*localtest.Server, context.Context) {
+	t.Helper()
+	prefs := ipn.NewPrefs()
+	prefs.RouteAll = routeAll
+	prefs.AdvertiseRoutes = routes
+	server, err := localtest.NewServer(t.TempDir(), prefs)
+	if err != nil {
+		t.Fatalf("failed to start local API: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	ctx := server.Context(cli.WithStdout(context.Background(), io.Discard))
+	return server, ctx
+}
+
+// setRoutes simulates another process replacing the advertised routes.
+func setRoutes(server *localtest.Server, routes ...netaddr.IPPrefix) {
+	patch := &ipn.MaskedPrefs{AdvertiseRoutesSet: true}
+	patch.Prefs.AdvertiseRoutes = routes
+	server.EditPrefs(patch)
+}
+
+func assertPrefs(t *testing.T, server *localtest.Server, routeAll bool, routes ...netaddr.IPPrefix) {
+	t.Helper()
+	p := server.Prefs()
+	if p.RouteAll != routeAll {
+		t.Fatalf("expected accept routes %t, got %t", routeAll, p.RouteAll)
+	}
+	if len(routes) == 0 && len(p.AdvertiseRoutes) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(p.AdvertiseRoutes, routes) {
+		t.Fatalf("expected advertised routes %v, got %v", routes, p.AdvertiseRoutes)
+	}
+}
+
+func TestRollbackAdvertiseKeepsConcurrentRoute(t *testing.T) {
+	server, ctx := newLocalServer(t, false, routeC)
+
+	edit, err := advertise.EditPrefsAdvertiseCIDR(ctx, routeA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertPrefs(t, server, true, routeC, routeA)
+
+	// Another process advertises a route before the rollback.
+	setRoutes(server, routeC, routeA, routeB)
+
+	cause := errors.New("failed to enable route")
+	err = advertise.Rollback(ctx, edit, false, cause)
+	if err != cause {
+		t.Fatalf("expected the original error, got %v", err)
+	}
+	assertPrefs(t, server, false, routeC, routeB)
+}
+
+func TestRollbackWithdrawKeepsConcurrentWithdrawal(t *testing.T) {
+	server, ctx := newLocalServer(t, true, routeA, routeB)
+	before := server.Prefs()
+	setRoutes(server, routeB)
+	edit := advertise.NewPrefsEdit(before, server.Prefs())
+	if edit == nil || !reflect.DeepEqual(edit.Removed, []netaddr.IPPrefix{routeA}) || len(edit.Added) != 0 || edit.RouteAllSet {
+		t.Fatalf("unexpected edit: %+v", edit)
+	}
+
+	// Another process withdraws a different route before the rollback.
+	setRoutes(server)
+
+	err := edit.Undo(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertPrefs(t, server, true, routeA)
+}
+
+func TestRollbackAlreadyReverted(t *testing.T) {
+	server, ctx := newLocalServer(t, true, routeB)
+
+	edit, err := advertise.EditPrefsAdvertiseCIDR(ctx, routeA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	setRoutes(server, routeB)
+
+	err = edit.Undo(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	assertPrefs(t, server, true, routeB)
+}
+
+func TestRollbackDisabled(t *testing.T) {
+	server, ctx := newLocalServer(t, true)
+
+	edit, err := advertise.EditPrefsAdvertiseCIDR(ctx, routeA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cause := errors.New("failed to enable route")
+	err = advertise.Rollback(ctx, edit, true, cause)
+	if err != cause {
+		t.Fatalf("expected the original error, got %v", err)
+	}
+	assertPrefs(t, server, true, routeA)
+
+	if advertise.NewPrefsEdit(server.Prefs(), server.Prefs()) != nil {
+		t.Fatalf("expected no edit when nothing changed")
+	}
+}
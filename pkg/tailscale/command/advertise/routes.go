@@ -71,11 +71,10 @@ func AcceptNewCIDR(ctx context.Context, c cloud.Config, cidr netaddr.IPPrefix, h
 		return nil
 	}
 
-	// ...otherwise, append it and call `SetRoutes()`
-	routes := append(rr.EnabledRoutes, cidr.String())
-	srr := cloud.SetRoutesRequest{DeviceID: device.ID, Routes: routes}
+	// ...otherwise, enable it (verifying it is not lost to a concurrent write)
+	urr := remix.UpdateRoutesRequest{DeviceID: device.ID, Enable: []string{cidr.String()}}
 	cli.Printf(ctx, "Enabling route %s for device %s...\n", cidr, device.ID)
-	_, err = cloud.SetRoutes(ctx, c, srr)
+	_, err = remix.UpdateRoutes(ctx, c, urr)
 	if err != nil {
 		return err
 	}
@@ -16,6 +16,7 @@ package authorize
 
 import (
 	"context"
+	"errors"
 	"os"
 
 	"github.com/dhermes/tailsk8s/pkg/cli"
@@ -24,8 +25,16 @@ import (
 )
 
 // AuthorizeDevice retrieves a device by name / hostname and then uses the
-// device ID to authorize the device.
+// device ID to authorize the device. If `c.Bulk()`, this instead authorizes
+// all matching pending devices via `AuthorizePending()`.
 func AuthorizeDevice(ctx context.Context, c Config) error {
+	if c.Bulk() {
+		if c.Hostname != "" {
+			return errors.New("a hostname can't be combined with authorizing pending devices in bulk")
+		}
+		return AuthorizePending(ctx, c)
+	}
+
 	err := c.APIConfig.Resolve(ctx)
 	if err != nil {
 		return err
@@ -40,8 +49,7 @@ func AuthorizeDevice(ctx context.Context, c Config) error {
 	}
 	cli.Printf(ctx, "Using hostname: %s\n", hostname)
 
-	gdbhr := remix.GetDeviceByHostnameRequest{Hostname: hostname}
-	device, err := remix.GetDeviceByHostname(ctx, c.APIConfig, gdbhr)
+	device, err := findDevice(ctx, c, hostname)
 	if err != nil {
 		return err
 	}
@@ -61,3 +69,15 @@ func AuthorizeDevice(ctx context.Context, c Config) error {
 	cli.Printf(ctx, "Authorized device %s\n", device.ID)
 	return nil
 }
+
+// findDevice retrieves the device by name / hostname; if `c.Wait` is set, this
+// polls until the device is listed (vs. failing if it is not listed yet).
+func findDevice(ctx context.Context, c Config, hostname string) (*cloud.Device, error) {
+	if !c.Wait {
+		gdbhr := remix.GetDeviceByHostnameRequest{Hostname: hostname}
+		return remix.GetDeviceByHostname(ctx, c.APIConfig, gdbhr)
+	}
+
+	wfdr := remix.WaitForDeviceRequest{Hostname: hostname, Timeout: c.WaitTimeout}
+	return remix.WaitForDevice(ctx, c.APIConfig, wfdr)
+}
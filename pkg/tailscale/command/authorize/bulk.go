@@ -0,0 +1,163 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authorize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+// Result is the outcome of authorizing a single device in bulk.
+type Result struct {
+	Device cloud.Device
+	Err    error
+}
+
+// AuthorizePending authorizes every unauthorized device that matches the
+// selector. The matching devices are listed and (unless `Yes` is set) must be
+// confirmed before any are authorized. Devices are authorized concurrently,
+// at most `Parallelism` at a time, and a summary of successes and failures
+// is printed. An error is returned if **any** device failed.
+func AuthorizePending(ctx context.Context, c Config) error {
+	err := c.Selector.Validate()
+	if err != nil {
+		return err
+	}
+	if c.Parallelism < 1 {
+		return fmt.Errorf("parallelism must be at least 1, got %d", c.Parallelism)
+	}
+	err = c.APIConfig.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending, err := FindPending(ctx, c.APIConfig, c.Selector, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		cli.Println(ctx, "No unauthorized devices match")
+		return nil
+	}
+
+	err = printDevices(ctx, pending)
+	if err != nil {
+		return err
+	}
+	if !c.Yes {
+		ok, err := cli.Confirm(ctx, fmt.Sprintf("Authorize %d device(s)?", len(pending)))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errors.New("authorization not confirmed")
+		}
+	}
+
+	results := AuthorizeAll(ctx, c.APIConfig, pending, c.Parallelism)
+	return summarize(ctx, results)
+}
+
+// FindPending lists all unauthorized devices in the Tailnet that match the
+// selector, sorted by hostname.
+func FindPending(ctx context.Context, c cloud.Config, s Selector, now time.Time) ([]cloud.Device, error) {
+	devices, err := cloud.GetDevices(ctx, c, cloud.Empty{})
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []cloud.Device{}
+	for _, device := range devices.Devices {
+		if device.Authorized {
+			continue
+		}
+		ok, err := s.Matches(device, now)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			pending = append(pending, device)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Hostname < pending[j].Hostname
+	})
+	return pending, nil
+}
+
+// AuthorizeAll authorizes each device, at most `parallelism` at a time. The
+// results are in the same order as `devices`.
+func AuthorizeAll(ctx context.Context, c cloud.Config, devices []cloud.Device, parallelism int) []Result {
+	results := make([]Result, len(devices))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, device := range devices {
+		wg.Add(1)
+		go func(i int, device cloud.Device) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			adr := cloud.AuthorizeDeviceRequest{DeviceID: device.ID, Authorized: true}
+			_, err := cloud.AuthorizeDevice(ctx, c, adr)
+			results[i] = Result{Device: device, Err: err}
+		}(i, device)
+	}
+	wg.Wait()
+	return results
+}
+
+func printDevices(ctx context.Context, devices []cloud.Device) error {
+	w := tabwriter.NewWriter(cli.GetStdout(ctx), 0, 4, 2, ' ', 0)
+	_, err := w.Write([]byte("ID\tHOSTNAME\tOS\tCREATED\tTAGS\n"))
+	if err != nil {
+		return err
+	}
+	for _, d := range devices {
+		row := []string{d.ID, d.Hostname, d.OS, d.Created, strings.Join(d.Tags, ",")}
+		_, err = w.Write([]byte(strings.Join(row, "\t") + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func summarize(ctx context.Context, results []Result) error {
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			cli.Printf(ctx, "Failed to authorize device %s (%s): %v\n", r.Device.ID, r.Device.Hostname, r.Err)
+			continue
+		}
+		cli.Printf(ctx, "Authorized device %s (%s)\n", r.Device.ID, r.Device.Hostname)
+	}
+
+	cli.Printf(ctx, "Authorized %d device(s), %d failed\n", len(results)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("failed to authorize %d of %d device(s)", failed, len(results))
+	}
+	return nil
+}
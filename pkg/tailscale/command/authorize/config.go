@@ -15,14 +15,43 @@
 package authorize
 
 import (
+	"time"
+
 	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
 )
 
+const (
+	// DefaultParallelism is the default number of devices authorized
+	// concurrently in bulk.
+	DefaultParallelism = 4
+	// DefaultWaitTimeout is the default time to wait for a device to be
+	// listed in the Tailnet.
+	DefaultWaitTimeout = 2 * time.Minute
+)
+
 // Config provides the core set of (CLI) inputs needed to authorize a new
 // device in a Tailnet.
 type Config struct {
 	APIConfig cloud.Config
 	Hostname  string
+	// AllPending authorizes **every** unauthorized device matching `Selector`
+	// (vs. a single device by `Hostname`).
+	AllPending bool
+	Selector   Selector
+	// Yes skips confirmation before authorizing devices in bulk.
+	Yes bool
+	// Parallelism is the maximum number of devices authorized concurrently.
+	Parallelism int
+	// Wait polls until a device matching `Hostname` is listed in the Tailnet
+	// (for up to `WaitTimeout`) rather than failing immediately.
+	Wait        bool
+	WaitTimeout time.Duration
+}
+
+// Bulk determines if devices should be authorized in bulk, i.e. if
+// `AllPending` is set or any selector is provided.
+func (c Config) Bulk() bool {
+	return c.AllPending || !c.Selector.Empty()
 }
 
 // NewConfig returns a new `Config` with all relevant defaults provided and
@@ -33,7 +62,11 @@ func NewConfig(opts ...Option) (Config, error) {
 		return Config{}, err
 	}
 
-	c := Config{APIConfig: ac}
+	c := Config{
+		APIConfig:   ac,
+		Parallelism: DefaultParallelism,
+		WaitTimeout: DefaultWaitTimeout,
+	}
 	for _, opt := range opts {
 		err := opt(&c)
 		if err != nil {
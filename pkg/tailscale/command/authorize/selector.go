@@ -0,0 +1,119 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authorize
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+)
+
+// Selector matches devices in a Tailnet; a device must match **all** of the
+// provided fields.
+type Selector struct {
+	// HostnameGlob is a `path.Match()` pattern, e.g. `k8s-worker-*`, matched
+	// against the hostname or machine name of a device.
+	HostnameGlob string
+	// Tag is a tag the device must have, e.g. `tag:k8s-worker`.
+	Tag string
+	// OS is the operating system of the device, e.g. `linux`.
+	OS string
+	// CreatedAfter is either an RFC 3339 time or a duration (e.g. `2h`)
+	// relative to now.
+	CreatedAfter string
+}
+
+// Empty determines if no fields are set on the selector.
+func (s Selector) Empty() bool {
+	return s.HostnameGlob == "" && s.Tag == "" && s.OS == "" && s.CreatedAfter == ""
+}
+
+// Validate checks that the glob and created after values can be parsed.
+func (s Selector) Validate() error {
+	if s.HostnameGlob != "" {
+		_, err := path.Match(s.HostnameGlob, "")
+		if err != nil {
+			return fmt.Errorf("invalid hostname glob %q: %w", s.HostnameGlob, err)
+		}
+	}
+	_, err := s.createdAfter(time.Now())
+	return err
+}
+
+// Matches determines if `device` matches all of the fields in the selector.
+// A device with a missing or invalid creation time never matches
+// `CreatedAfter`.
+func (s Selector) Matches(device cloud.Device, now time.Time) (bool, error) {
+	if s.HostnameGlob != "" {
+		name := strings.SplitN(device.Name, ".", 2)[0]
+		byHostname, err := path.Match(s.HostnameGlob, device.Hostname)
+		if err != nil {
+			return false, err
+		}
+		byName, err := path.Match(s.HostnameGlob, name)
+		if err != nil {
+			return false, err
+		}
+		if !byHostname && !byName {
+			return false, nil
+		}
+	}
+	if s.Tag != "" && !hasTag(device, s.Tag) {
+		return false, nil
+	}
+	if s.OS != "" && !strings.EqualFold(device.OS, s.OS) {
+		return false, nil
+	}
+
+	after, err := s.createdAfter(now)
+	if err != nil {
+		return false, err
+	}
+	if !after.IsZero() {
+		created, err := time.Parse(time.RFC3339, device.Created)
+		if err != nil || !created.After(after) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// createdAfter parses `CreatedAfter`; the zero time is returned if unset.
+func (s Selector) createdAfter(now time.Time) (time.Time, error) {
+	if s.CreatedAfter == "" {
+		return time.Time{}, nil
+	}
+	d, err := time.ParseDuration(s.CreatedAfter)
+	if err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s.CreatedAfter)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid created after %q, expected an RFC 3339 time or a duration", s.CreatedAfter)
+	}
+	return t, nil
+}
+
+func hasTag(device cloud.Device, tag string) bool {
+	for _, t := range device.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deauthorize
+
+import (
+	"context"
+	"errors"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/remix"
+)
+
+// DeauthorizeDevice retrieves a device by name / hostname and then uses the
+// device ID to mark the device as unauthorized.
+func DeauthorizeDevice(ctx context.Context, c Config) error {
+	if c.Hostname == "" {
+		return errors.New("the hostname of the device to deauthorize is required")
+	}
+	err := c.APIConfig.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	gdbhr := remix.GetDeviceByHostnameRequest{Hostname: c.Hostname}
+	device, err := remix.GetDeviceByHostname(ctx, c.APIConfig, gdbhr)
+	if err != nil {
+		return err
+	}
+
+	if !device.Authorized && !c.DisableRoutes {
+		cli.Printf(ctx, "Device %s is already unauthorized\n", device.ID)
+		return nil
+	}
+
+	cli.Printf(ctx, "Deauthorizing device %s (%s)...\n", device.ID, device.Hostname)
+	ddr := remix.DeauthorizeDeviceRequest{DeviceID: device.ID, DisableRoutes: c.DisableRoutes}
+	disabled, err := remix.DeauthorizeDevice(ctx, c.APIConfig, ddr)
+	for _, r := range disabled {
+		cli.Printf(ctx, "Disabled route %s for device %s\n", r, device.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	cli.Printf(ctx, "Deauthorized device %s\n", device.ID)
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exitnode
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/advertise"
+)
+
+// AdvertiseAndEnable first uses the local `tailscaled` API to advertise the
+// local device as an exit node and then uses the cloud API to enable both
+// default routes. If enabling fails, the local change is rolled back (unless
+// `NoRollback` is set).
+func AdvertiseAndEnable(ctx context.Context, c Config) error {
+	err := c.APIConfig.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Use the local hostname to determine the Tailscale node ID
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "Using hostname: %s\n", hostname)
+
+	edit, err := EditPrefsAdvertiseExitNode(ctx)
+	if err != nil {
+		return err
+	}
+
+	// TODO: Remove this sleep once a more sane way of handling the race
+	//       condition between the local change above and the remote change below.
+	time.Sleep(5 * time.Second)
+
+	err = EnableExitNode(ctx, c.APIConfig, hostname)
+	if err != nil {
+		return advertise.Rollback(ctx, edit, c.NoRollback, err)
+	}
+	return nil
+}
+
+// WithdrawAndDisable first uses the local `tailscaled` API to stop
+// advertising the local device as an exit node and then uses the cloud API to
+// disable both default routes. If disabling fails, the local change is rolled
+// back (unless `NoRollback` is set).
+func WithdrawAndDisable(ctx context.Context, c Config) error {
+	err := c.APIConfig.Resolve(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Use the local hostname to determine the Tailscale node ID
+	hostname, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "Using hostname: %s\n", hostname)
+
+	edit, err := EditPrefsWithdrawExitNode(ctx)
+	if err != nil {
+		return err
+	}
+
+	// TODO: Remove this sleep once a more sane way of handling the race
+	//       condition between the local change above and the remote change below.
+	time.Sleep(5 * time.Second)
+
+	err = DisableExitNode(ctx, c.APIConfig, hostname)
+	if err != nil {
+		return advertise.Rollback(ctx, edit, c.NoRollback, err)
+	}
+	return nil
+}
+
+// Use selects `c.Device` as the exit node for the local device.
+func Use(ctx context.Context, c Config) error {
+	peer, err := FindPeer(ctx, c.Device)
+	if err != nil {
+		return err
+	}
+	cli.Printf(ctx, "Found peer %s (%s)\n", peer.HostName, peer.ID)
+	return EditPrefsUseExitNode(ctx, peer.ID)
+}
@@ -0,0 +1,163 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exitnode
+
+import (
+	"context"
+
+	"inet.af/netaddr"
+	"tailscale.com/ipn"
+	"tailscale.com/tailcfg"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/advertise"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+var (
+	// DefaultRoutes are the routes advertised by an exit node. Tailscale only
+	// treats a device as an exit node if **both** are advertised.
+	DefaultRoutes = []netaddr.IPPrefix{
+		netaddr.MustParseIPPrefix("0.0.0.0/0"),
+		netaddr.MustParseIPPrefix("::/0"),
+	}
+)
+
+// EditPrefsAdvertiseExitNode updates existing Tailscale preferences to
+// advertise both default routes in addition to any existing routes (the
+// equivalent of the `--advertise-exit-node` flag for `tailscale up`).
+//
+// If both default routes are present, this will make no changes. Otherwise, a
+// record of the changes that were made is returned so they can be rolled
+// back.
+func EditPrefsAdvertiseExitNode(ctx context.Context) (*advertise.PrefsEdit, error) {
+	unlock, err := local.LockPrefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	before, err := local.GetPrefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := before.AdvertiseRoutes
+	for _, dr := range DefaultRoutes {
+		if !advertise.IPPrefixesContain(routes, dr) {
+			routes = append(routes, dr)
+		}
+	}
+	if len(routes) == len(before.AdvertiseRoutes) {
+		cli.Println(ctx, "Exit node already advertised")
+		return nil, nil
+	}
+
+	patch := &ipn.MaskedPrefs{}
+	patch.Prefs = *before.Clone()
+	patch.Prefs.AdvertiseRoutes = routes
+	patch.AdvertiseRoutesSet = true
+
+	after, err := local.EditPrefs(ctx, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	local.PrintPrefsDiff(ctx, before, after)
+	return advertise.NewPrefsEdit(before, after), nil
+}
+
+// EditPrefsWithdrawExitNode updates existing Tailscale preferences to stop
+// advertising both default routes; other advertised routes are kept.
+//
+// If neither default route is present, this will make no changes. Otherwise,
+// the changes that were made are returned so they can be rolled back.
+func EditPrefsWithdrawExitNode(ctx context.Context) (*advertise.PrefsEdit, error) {
+	unlock, err := local.LockPrefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	before, err := local.GetPrefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]netaddr.IPPrefix, 0, len(before.AdvertiseRoutes))
+	for _, r := range before.AdvertiseRoutes {
+		if !advertise.IPPrefixesContain(DefaultRoutes, r) {
+			routes = append(routes, r)
+		}
+	}
+	if len(routes) == len(before.AdvertiseRoutes) {
+		cli.Println(ctx, "Exit node already withdrawn")
+		return nil, nil
+	}
+
+	patch := &ipn.MaskedPrefs{}
+	patch.Prefs = *before.Clone()
+	patch.Prefs.AdvertiseRoutes = routes
+	patch.AdvertiseRoutesSet = true
+
+	after, err := local.EditPrefs(ctx, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	local.PrintPrefsDiff(ctx, before, after)
+	return advertise.NewPrefsEdit(before, after), nil
+}
+
+// EditPrefsUseExitNode updates existing Tailscale preferences to route
+// Internet traffic through the exit node `id` (the equivalent of the
+// `--exit-node` flag for `tailscale up`).
+//
+// If `id` is already the exit node, this will make no changes.
+func EditPrefsUseExitNode(ctx context.Context, id tailcfg.StableNodeID) error {
+	unlock, err := local.LockPrefs(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	before, err := local.GetPrefs(ctx)
+	if err != nil {
+		return err
+	}
+
+	if before.ExitNodeID == id {
+		cli.Printf(ctx, "Already using exit node %s\n", id)
+		return nil
+	}
+
+	// NOTE: The exit node IP is cleared since `tailscaled` prefers the
+	//       (stable) node ID when both are set.
+	patch := &ipn.MaskedPrefs{}
+	patch.Prefs = *before.Clone()
+	patch.Prefs.ExitNodeID = id
+	patch.Prefs.ExitNodeIP = netaddr.IP{}
+	patch.ExitNodeIDSet = true
+	patch.ExitNodeIPSet = true
+
+	after, err := local.EditPrefs(ctx, patch)
+	if err != nil {
+		return err
+	}
+
+	local.PrintPrefsDiff(ctx, before, after)
+	cli.Printf(ctx, "Using exit node %s\n", id)
+	return nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exitnode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"tailscale.com/ipn/ipnstate"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/cloud/remix"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/advertise"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+// EnableExitNode ensures that both (newly advertised) default routes are
+// enabled in the Tailscale cloud API. The routes are enabled together with a
+// single (verified) update.
+func EnableExitNode(ctx context.Context, c cloud.Config, hostname string) error {
+	device, rr, err := deviceRoutes(ctx, c, hostname)
+	if err != nil {
+		return err
+	}
+
+	// Ensure both default routes are advertised; see `AcceptNewCIDR()` for
+	// more on the race condition this may encounter.
+	enable := []string{}
+	for _, dr := range DefaultRoutes {
+		if !advertise.RoutesContain(rr.AdvertisedRoutes, dr) {
+			return fmt.Errorf("default route (%s) is not among list of advertised routes", dr)
+		}
+		if !advertise.RoutesContain(rr.EnabledRoutes, dr) {
+			enable = append(enable, dr.String())
+		}
+	}
+
+	if len(enable) == 0 {
+		cli.Printf(ctx, "Device %s has already enabled exit node routes\n", device.ID)
+		return nil
+	}
+
+	urr := remix.UpdateRoutesRequest{DeviceID: device.ID, Enable: enable}
+	cli.Printf(ctx, "Enabling exit node routes for device %s...\n", device.ID)
+	_, err = remix.UpdateRoutes(ctx, c, urr)
+	if err != nil {
+		return err
+	}
+
+	cli.Printf(ctx, "Enabled exit node routes for device %s\n", device.ID)
+	return nil
+}
+
+// DisableExitNode ensures that both (recently withdrawn) default routes are
+// removed from the set of enabled routes in the Tailscale cloud API.
+func DisableExitNode(ctx context.Context, c cloud.Config, hostname string) error {
+	device, rr, err := deviceRoutes(ctx, c, hostname)
+	if err != nil {
+		return err
+	}
+
+	// Ensure neither default route is advertised; see
+	// `DisableWithdrawnCIDR()` for more on the race condition this may
+	// encounter.
+	for _, dr := range DefaultRoutes {
+		if advertise.RoutesContain(rr.AdvertisedRoutes, dr) {
+			return fmt.Errorf("withdrawn default route (%s) is still among list of advertised routes", dr)
+		}
+	}
+
+	disable := []string{}
+	for _, r := range rr.EnabledRoutes {
+		if isDefaultRoute(r) {
+			disable = append(disable, r)
+		}
+	}
+	if len(disable) == 0 {
+		cli.Printf(ctx, "Device %s has already disabled exit node routes\n", device.ID)
+		return nil
+	}
+
+	urr := remix.UpdateRoutesRequest{DeviceID: device.ID, Disable: disable}
+	cli.Printf(ctx, "Disabling exit node routes for device %s...\n", device.ID)
+	_, err = remix.UpdateRoutes(ctx, c, urr)
+	if err != nil {
+		return err
+	}
+
+	cli.Printf(ctx, "Disabled exit node routes for device %s\n", device.ID)
+	return nil
+}
+
+// FindPeer finds the peer of the local device identified by `device`, which
+// can be a hostname, a MagicDNS name, a Tailscale IP or a (stable) node ID.
+func FindPeer(ctx context.Context, device string) (*ipnstate.PeerStatus, error) {
+	if device == "" {
+		return nil, errors.New("exit node device is required")
+	}
+
+	status, err := local.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*ipnstate.PeerStatus{}
+	for _, peer := range status.Peer {
+		if peerMatches(peer, device) {
+			matches = append(matches, peer)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no peer matches exit node %q", device)
+	}
+	if len(matches) > 1 {
+		ids := make([]string, 0, len(matches))
+		for _, peer := range matches {
+			ids = append(ids, string(peer.ID))
+		}
+		return nil, fmt.Errorf("exit node %q matches multiple peers (%s); use a node ID instead", device, strings.Join(ids, ", "))
+	}
+	return matches[0], nil
+}
+
+func peerMatches(peer *ipnstate.PeerStatus, device string) bool {
+	if string(peer.ID) == device || strings.EqualFold(peer.HostName, device) {
+		return true
+	}
+	dnsName := strings.TrimSuffix(peer.DNSName, ".")
+	if strings.EqualFold(dnsName, device) {
+		return true
+	}
+	for _, ip := range peer.TailscaleIPs {
+		if ip.String() == device {
+			return true
+		}
+	}
+	return false
+}
+
+// deviceRoutes retrieves the device corresponding to `hostname` and prints
+// its **current** routes.
+func deviceRoutes(ctx context.Context, c cloud.Config, hostname string) (*cloud.Device, *cloud.RoutesResponse, error) {
+	gdbhr := remix.GetDeviceByHostnameRequest{Hostname: hostname}
+	device, err := remix.GetDeviceByHostname(ctx, c, gdbhr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	grr := cloud.GetRoutesRequest{DeviceID: device.ID}
+	rr, err := cloud.GetRoutes(ctx, c, grr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rr.AdvertisedRoutes) > 0 {
+		cli.Printf(ctx, "Advertised routes for device %s:\n", device.ID)
+		for _, ar := range rr.AdvertisedRoutes {
+			cli.Printf(ctx, "- %s\n", ar)
+		}
+	}
+	if len(rr.EnabledRoutes) > 0 {
+		cli.Printf(ctx, "Enabled routes for device %s:\n", device.ID)
+		for _, ar := range rr.EnabledRoutes {
+			cli.Printf(ctx, "- %s\n", ar)
+		}
+	}
+	return device, rr, nil
+}
+
+func isDefaultRoute(route string) bool {
+	for _, dr := range DefaultRoutes {
+		if route == dr.String() {
+			return true
+		}
+	}
+	return false
+}
@@ -23,6 +23,9 @@ import (
 type Config struct {
 	APIConfig cloud.Config
 	IPv4CIDR  string
+	// NoRollback leaves local preferences modified if disabling the route in
+	// the cloud API fails.
+	NoRollback bool
 }
 
 // NewConfig returns a new `Config` with all relevant defaults provided and
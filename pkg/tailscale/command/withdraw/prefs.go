@@ -16,32 +16,37 @@ package withdraw
 
 import (
 	"context"
-	"encoding/json"
 
 	"inet.af/netaddr"
-	"tailscale.com/client/tailscale"
 	"tailscale.com/ipn"
 
 	"github.com/dhermes/tailsk8s/pkg/cli"
 	"github.com/dhermes/tailsk8s/pkg/tailscale/command/advertise"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
 )
 
 // EditPrefsWithdrawCIDR updates existing Tailscale preferences to withdraw
 // a routes advertised by the current Tailscale node.
 //
-// If the accept routes flag and the advertised CIDR are both present, this
-// will make no changes.
-func EditPrefsWithdrawCIDR(ctx context.Context, cidr netaddr.IPPrefix) error {
-	cli.DebugPrintf(ctx, advertise.DebugCurlGetPrefs)
-	before, err := tailscale.GetPrefs(ctx)
+// If the CIDR is not advertised, this will make no changes. Otherwise, a
+// record of the changes that were made is returned so they can be rolled
+// back.
+func EditPrefsWithdrawCIDR(ctx context.Context, cidr netaddr.IPPrefix) (*advertise.PrefsEdit, error) {
+	unlock, err := local.LockPrefs(ctx)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	defer unlock()
+
+	before, err := local.GetPrefs(ctx)
+	if err != nil {
+		return nil, err
 	}
 
 	hasCIDR := advertise.IPPrefixesContain(before.AdvertiseRoutes, cidr)
 	if !hasCIDR {
 		cli.Println(ctx, "Route already withdrawn")
-		return nil
+		return nil, nil
 	}
 
 	patch := &ipn.MaskedPrefs{}
@@ -49,22 +54,13 @@ func EditPrefsWithdrawCIDR(ctx context.Context, cidr netaddr.IPPrefix) error {
 	patch.Prefs.AdvertiseRoutes = ipPrefixesRemove(patch.Prefs.AdvertiseRoutes, cidr)
 	patch.AdvertiseRoutesSet = true
 
-	if cli.GetDebug(ctx) {
-		asJSON, err := json.Marshal(patch)
-		// Ignore error: failure to marshal in debug mode can't break the regular flow.
-		if err != nil {
-			asJSON = []byte("...")
-		}
-		cli.DebugPrintf(ctx, advertise.DebugCurlEditPrefs, string(asJSON))
-	}
-
-	after, err := tailscale.EditPrefs(ctx, patch)
+	after, err := local.EditPrefs(ctx, patch)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	advertise.DiffBeforeAfter(ctx, before, after)
-	return nil
+	local.PrintPrefsDiff(ctx, before, after)
+	return advertise.NewPrefsEdit(before, after), nil
 }
 
 func ipPrefixesRemove(prefixes []netaddr.IPPrefix, cidr netaddr.IPPrefix) []netaddr.IPPrefix {
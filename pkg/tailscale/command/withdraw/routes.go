@@ -72,11 +72,10 @@ func DisableWithdrawnCIDR(ctx context.Context, c cloud.Config, cidr netaddr.IPPr
 		return nil
 	}
 
-	// ...otherwise, remove it and call `SetRoutes()`
-	routes := routesRemove(rr.EnabledRoutes, cidr)
-	srr := cloud.SetRoutesRequest{DeviceID: device.ID, Routes: routes}
+	// ...otherwise, disable it (verifying it is not lost to a concurrent write)
+	urr := remix.UpdateRoutesRequest{DeviceID: device.ID, Disable: []string{cidr.String()}}
 	cli.Printf(ctx, "Disabling route %s for device %s...\n", cidr, device.ID)
-	_, err = cloud.SetRoutes(ctx, c, srr)
+	_, err = remix.UpdateRoutes(ctx, c, urr)
 	if err != nil {
 		return err
 	}
@@ -84,14 +83,3 @@ func DisableWithdrawnCIDR(ctx context.Context, c cloud.Config, cidr netaddr.IPPr
 	cli.Printf(ctx, "Disabled route %s for device %s\n", cidr, device.ID)
 	return nil
 }
-
-func routesRemove(routes []string, cidr netaddr.IPPrefix) []string {
-	keep := make([]string, 0, len(routes))
-	cidrString := cidr.String()
-	for _, r := range routes {
-		if r != cidrString {
-			keep = append(keep, r)
-		}
-	}
-	return keep
-}
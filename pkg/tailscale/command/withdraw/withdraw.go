@@ -22,11 +22,13 @@ import (
 	"inet.af/netaddr"
 
 	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/command/advertise"
 )
 
 // WithdrawAndDisable first uses the local `tailscaled` API to withdraw a
 // CIDR from the Tailnet and then uses the cloud API to disable the withdrawn
-// CIDR.
+// CIDR. If disabling fails, the local change is rolled back (unless
+// `NoRollback` is set).
 func WithdrawAndDisable(ctx context.Context, c Config) error {
 	err := c.APIConfig.Resolve(ctx)
 	if err != nil {
@@ -38,21 +40,25 @@ func WithdrawAndDisable(ctx context.Context, c Config) error {
 		return err
 	}
 
-	err = EditPrefsWithdrawCIDR(ctx, cidr)
+	// Use the local hostname to determine the Tailscale node ID
+	hostname, err := os.Hostname()
 	if err != nil {
 		return err
 	}
+	cli.Printf(ctx, "Using hostname: %s\n", hostname)
 
-	// Use the local hostname to determine the Tailscale node ID
-	hostname, err := os.Hostname()
+	edit, err := EditPrefsWithdrawCIDR(ctx, cidr)
 	if err != nil {
 		return err
 	}
-	cli.Printf(ctx, "Using hostname: %s\n", hostname)
 
 	// TODO: Remove this sleep once a more sane way of handling the race
 	//       condition between the local change above and the remote change below.
 	time.Sleep(5 * time.Second)
 
-	return DisableWithdrawnCIDR(ctx, c.APIConfig, cidr, hostname)
+	err = DisableWithdrawnCIDR(ctx, c.APIConfig, cidr, hostname)
+	if err != nil {
+		return advertise.Rollback(ctx, edit, c.NoRollback, err)
+	}
+	return nil
 }
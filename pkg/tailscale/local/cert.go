@@ -0,0 +1,89 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+const (
+	// debugCurlCert is a template to print (in debug mode) the "cert" curl
+	// command.
+	debugCurlCert = `Calling "cert" local API route:
+> curl \
+>   --include \
+>   --unix-socket %s \
+>   'http://no-op-host.invalid/localapi/v0/cert/%s?type=pair'
+`
+)
+
+// CertPair gets a TLS certificate (issued via the Tailnet's HTTPS support)
+// and private key for a domain in the Tailnet, e.g.
+// `pedantic-yonath.tailnet-abcd.ts.net`. This is the equivalent of
+// `tailscale cert`.
+func CertPair(ctx context.Context, domain string) (certPEM, keyPEM []byte, err error) {
+	path := "/localapi/v0/cert/" + url.PathEscape(domain) + "?type=pair"
+	cli.DebugPrintf(ctx, debugCurlCert, GetSocket(ctx), url.PathEscape(domain))
+	pair, err := sendRaw(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// With `type=pair`, the response is the private key PEM block followed by
+	// the certificate PEM blocks.
+	i := bytes.Index(pair, []byte("--\n--"))
+	if i == -1 {
+		return nil, nil, errors.New("unexpected certificate pair, no delimiter between key and certificate")
+	}
+	i += len("--\n")
+	keyPEM, certPEM = pair[:i], pair[i:]
+	if bytes.Contains(certPEM, []byte(" PRIVATE KEY-----")) {
+		return nil, nil, errors.New("unexpected certificate pair, private key found among certificates")
+	}
+	return certPEM, keyPEM, nil
+}
+
+// CertDomain returns the (first) domain that the local device can get a TLS
+// certificate for. This requires HTTPS to be enabled for the Tailnet.
+func CertDomain(ctx context.Context) (string, error) {
+	status, err := StatusWithoutPeers(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(status.CertDomains) == 0 {
+		return "", errors.New("no certificate domains available (is HTTPS enabled for the Tailnet)")
+	}
+	return status.CertDomains[0], nil
+}
+
+// Certificate gets a TLS certificate for `domain` (see `CertPair()`).
+func Certificate(ctx context.Context, domain string) (*tls.Certificate, error) {
+	certPEM, keyPEM, err := CertPair(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
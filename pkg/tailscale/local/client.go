@@ -0,0 +1,113 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+
+	"tailscale.com/client/tailscale"
+	"tailscale.com/safesocket"
+)
+
+const (
+	// baseURL is the base URL for all local API requests; the host is
+	// ignored since requests are sent over the `tailscaled` socket.
+	baseURL = "http://local-tailscaled.sock"
+)
+
+var (
+	clientsMu sync.Mutex
+	clients   = map[string]*http.Client{}
+)
+
+// httpClient returns an HTTP client that sends requests over a given
+// `tailscaled` socket. Clients are cached per socket.
+func httpClient(socket string) *http.Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	hc, ok := clients[socket]
+	if ok {
+		return hc
+	}
+	hc = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return safesocket.Connect(socket, safesocket.WindowsLocalPort)
+			},
+		},
+	}
+	clients[socket] = hc
+	return hc
+}
+
+// Do sends a request to the local API over the socket specified on the
+// context. For the default socket, the request is delegated to the Tailscale
+// client library (which handles platform specific quirks, e.g. the macOS
+// sandboxed GUI).
+func Do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	socket := GetSocket(ctx)
+	if socket == DefaultSocket() {
+		return tailscale.DoLocalRequest(req)
+	}
+
+	resp, err := httpClient(socket).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to tailscaled via %s: %w", socket, err)
+	}
+	return resp, nil
+}
+
+// send makes a local API request and decodes a JSON response into `v`.
+func send(ctx context.Context, method, path string, body []byte, v interface{}) error {
+	b, err := sendRaw(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// sendRaw makes a local API request and returns the response body.
+func sendRaw(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, baseURL+path, r)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("local API request %s %s failed (status %d, body %q)", method, path, resp.StatusCode, b)
+	}
+	return b, nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+
+	"tailscale.com/paths"
+)
+
+type socketKey struct{}
+
+// DefaultSocket returns the default path to the `tailscaled` socket for the
+// current platform, e.g. `/var/run/tailscale/tailscaled.sock` on Linux.
+func DefaultSocket() string {
+	return paths.DefaultTailscaledSocket()
+}
+
+// WithSocket sets the `tailscaled` socket to be used for local API calls
+// on a context.
+func WithSocket(ctx context.Context, socket string) context.Context {
+	return context.WithValue(ctx, socketKey{}, socket)
+}
+
+// GetSocket gets the `tailscaled` socket from a context; if not provided,
+// falls back to `DefaultSocket()`.
+func GetSocket(ctx context.Context) string {
+	s, ok := ctx.Value(socketKey{}).(string)
+	if ok && s != "" {
+		return s
+	}
+	return DefaultSocket()
+}
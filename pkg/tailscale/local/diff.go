@@ -0,0 +1,233 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"tailscale.com/ipn"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// PrefsChange is a single changed field in `tailscaled` preferences. For
+// slices of scalar values (e.g. `AdvertiseRoutes`), the change is described
+// as elements added and removed (i.e. the slice is treated as a set).
+type PrefsChange struct {
+	// Path is the path to the field, e.g. `RouteAll` or `Persist.LoginName`.
+	Path    string
+	Before  interface{}
+	After   interface{}
+	Added   []interface{}
+	Removed []interface{}
+}
+
+// IsSetChange indicates if the change is described as elements added to
+// and removed from a set.
+func (pc PrefsChange) IsSetChange() bool {
+	return pc.Added != nil || pc.Removed != nil
+}
+
+// String describes the change, e.g. "RouteAll: false -> true" or
+// "AdvertiseRoutes: +10.100.0.0/24".
+func (pc PrefsChange) String() string {
+	if !pc.IsSetChange() {
+		return fmt.Sprintf("%s: %s -> %s", pc.Path, describeValue(pc.Before), describeValue(pc.After))
+	}
+
+	parts := []string{}
+	for _, a := range pc.Added {
+		parts = append(parts, fmt.Sprintf("+%v", a))
+	}
+	for _, r := range pc.Removed {
+		parts = append(parts, fmt.Sprintf("-%v", r))
+	}
+	return fmt.Sprintf("%s: %s", pc.Path, strings.Join(parts, " "))
+}
+
+// sensitivePrefsFields are removed from `Persist` before comparing since they
+// contain private keys.
+var sensitivePrefsFields = []string{
+	"LegacyFrontendPrivateMachineKey",
+	"PrivateNodeKey",
+	"OldPrivateNodeKey",
+}
+
+// DiffPrefs compares `before` and `after` field by field and returns the
+// changed fields, sorted by path. Fields containing private keys are never
+// compared.
+func DiffPrefs(before, after *ipn.Prefs) ([]PrefsChange, error) {
+	b, err := prefsTree(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := prefsTree(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []PrefsChange{}
+	diffValues("", b, a, &changes)
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// PrintPrefsDiff prints the fields changed between `before` and `after`. If
+// the comparison itself fails, nothing is printed since the diff is meant
+// to **aid** understanding (vs. to provide actual functionality).
+func PrintPrefsDiff(ctx context.Context, before, after *ipn.Prefs) {
+	changes, err := DiffPrefs(before, after)
+	if err != nil {
+		cli.DebugPrintf(ctx, "Failed to compare preferences: %v\n", err)
+		return
+	}
+	if len(changes) == 0 {
+		cli.Println(ctx, "Local preferences unchanged")
+		return
+	}
+
+	cli.Println(ctx, "Local preferences changed:")
+	for _, change := range changes {
+		cli.Printf(ctx, "- %s\n", change)
+	}
+}
+
+// prefsTree converts preferences into a generic tree (via JSON) so that
+// field values can be compared without depending on the exact Go types.
+func prefsTree(p *ipn.Prefs) (interface{}, error) {
+	if p == nil {
+		return nil, nil
+	}
+	asJSON, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	err = json.Unmarshal(asJSON, &tree)
+	if err != nil {
+		return nil, err
+	}
+
+	persist, ok := tree["Persist"].(map[string]interface{})
+	if ok {
+		for _, field := range sensitivePrefsFields {
+			delete(persist, field)
+		}
+	}
+	return tree, nil
+}
+
+func diffValues(path string, before, after interface{}, changes *[]PrefsChange) {
+	bm, bIsMap := before.(map[string]interface{})
+	am, aIsMap := after.(map[string]interface{})
+	if bIsMap && aIsMap {
+		keys := map[string]bool{}
+		for k := range bm {
+			keys[k] = true
+		}
+		for k := range am {
+			keys[k] = true
+		}
+		for k := range keys {
+			diffValues(joinPath(path, k), bm[k], am[k], changes)
+		}
+		return
+	}
+
+	bs, bIsSlice := asSlice(before)
+	as, aIsSlice := asSlice(after)
+	if bIsSlice && aIsSlice && allScalars(bs) && allScalars(as) {
+		added := setDifference(as, bs)
+		removed := setDifference(bs, as)
+		if len(added) > 0 || len(removed) > 0 {
+			*changes = append(*changes, PrefsChange{
+				Path:    path,
+				Before:  before,
+				After:   after,
+				Added:   added,
+				Removed: removed,
+			})
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, PrefsChange{Path: path, Before: before, After: after})
+	}
+}
+
+// asSlice converts a JSON value into a slice; `null` is treated as an empty
+// slice so that `nil` and empty slices compare as equal.
+func asSlice(v interface{}) ([]interface{}, bool) {
+	if v == nil {
+		return []interface{}{}, true
+	}
+	s, ok := v.([]interface{})
+	return s, ok
+}
+
+func allScalars(values []interface{}) bool {
+	for _, v := range values {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+// setDifference returns the elements of `a` that are not in `b`, preserving
+// the order in `a`.
+func setDifference(a, b []interface{}) []interface{} {
+	inB := map[interface{}]bool{}
+	for _, v := range b {
+		inB[v] = true
+	}
+	diff := []interface{}{}
+	for _, v := range a {
+		if !inB[v] {
+			diff = append(diff, v)
+		}
+	}
+	return diff
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func describeValue(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	asJSON, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(asJSON)
+}
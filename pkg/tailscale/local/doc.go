@@ -16,5 +16,7 @@
 //
 // The "local API" is the the API provided by the locally running `tailscaled`.
 // This API is provided over a Unix Domain Socket typically present at
-// `/var/run/tailscale/tailscaled.sock`.
+// `/var/run/tailscale/tailscaled.sock`. A different socket (e.g. for a second
+// `tailscaled` instance, a userspace networking daemon or a containerized
+// `tailscaled` with a mounted socket) can be specified via `WithSocket()`.
 package local
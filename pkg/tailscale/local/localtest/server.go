@@ -0,0 +1,115 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package localtest
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+
+	"tailscale.com/ipn"
+
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+)
+
+// Server is a fake `tailscaled` local API. It is safe for concurrent use.
+type Server struct {
+	// Socket is the Unix socket the server listens on.
+	Socket string
+	// PrefsLock is the lock file used by `local.LockPrefs()` in contexts
+	// returned by `Context()`.
+	PrefsLock string
+
+	mu     sync.Mutex
+	prefs  *ipn.Prefs
+	server *http.Server
+}
+
+// NewServer starts a new fake local API in `dir` (e.g. from `t.TempDir()`)
+// with the given initial preferences. The caller is responsible for calling
+// `Close()`.
+func NewServer(dir string, prefs *ipn.Prefs) (*Server, error) {
+	s := &Server{
+		Socket:    filepath.Join(dir, "tailscaled.sock"),
+		PrefsLock: filepath.Join(dir, "tailsk8s-prefs.lock"),
+		prefs:     prefs.Clone(),
+	}
+	ln, err := net.Listen("unix", s.Socket)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/localapi/v0/prefs", s.servePrefs)
+	s.server = &http.Server{Handler: mux}
+	go func() {
+		// Ignore error: `Serve()` always returns an error once `Close()` is
+		//               called.
+		_ = s.server.Serve(ln)
+	}()
+	return s, nil
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// Context returns a context that directs local API calls (and the local
+// preferences lock) to this server.
+func (s *Server) Context(ctx context.Context) context.Context {
+	ctx = local.WithSocket(ctx, s.Socket)
+	return local.WithPrefsLock(ctx, s.PrefsLock)
+}
+
+// Prefs returns a copy of the current preferences.
+func (s *Server) Prefs() *ipn.Prefs {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prefs.Clone()
+}
+
+// EditPrefs applies a patch to the preferences directly, e.g. to simulate a
+// concurrent edit by another process.
+func (s *Server) EditPrefs(patch *ipn.MaskedPrefs) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prefs.ApplyEdits(patch)
+}
+
+func (s *Server) servePrefs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		var patch ipn.MaskedPrefs
+		err := json.NewDecoder(r.Body).Decode(&patch)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.prefs.ApplyEdits(&patch)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.prefs)
+}
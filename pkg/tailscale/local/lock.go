@@ -0,0 +1,50 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+// DefaultPrefsLock is the default path to the lock file that serializes
+// edits to `tailscaled` preferences across processes on a host.
+const DefaultPrefsLock = "/run/lock/tailsk8s-prefs.lock"
+
+type prefsLockKey struct{}
+
+// WithPrefsLock sets the lock file used by `LockPrefs()` on a context.
+func WithPrefsLock(ctx context.Context, filename string) context.Context {
+	return context.WithValue(ctx, prefsLockKey{}, filename)
+}
+
+// GetPrefsLock gets the lock file used by `LockPrefs()` from a context; if
+// not provided, falls back to `DefaultPrefsLock`.
+func GetPrefsLock(ctx context.Context) string {
+	filename, ok := ctx.Value(prefsLockKey{}).(string)
+	if ok && filename != "" {
+		return filename
+	}
+	return DefaultPrefsLock
+}
+
+// LockPrefs acquires a host-level (exclusive) lock so that a read-modify-write
+// of `tailscaled` preferences (i.e. `GetPrefs()` followed by `EditPrefs()`)
+// can't lose a concurrent edit made by another process. This blocks until the
+// lock is acquired or `ctx` is done. The returned function releases the lock.
+func LockPrefs(ctx context.Context) (func(), error) {
+	return cli.LockFile(ctx, "local preferences", GetPrefsLock(ctx))
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"inet.af/netaddr"
+	"tailscale.com/ipn"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local"
+	"github.com/dhermes/tailsk8s/pkg/tailscale/local/localtest"
+)
+
+func TestLockPrefsConcurrent(t *testing.T) {
+	server, err := localtest.NewServer(t.TempDir(), ipn.NewPrefs())
+	if err != nil {
+		t.Fatalf("failed to start local API: %v", err)
+	}
+	defer server.Close()
+	ctx := server.Context(cli.WithStdout(context.Background(), io.Discard))
+
+	// Each writer does a read-modify-write of the advertised routes with a
+	// pause in between, so without the lock writers would drop each other's
+	// routes.
+	const writers = 8
+	errs := make(chan error, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- addRoute(ctx, netaddr.MustParseIPPrefix(fmt.Sprintf("10.100.%d.0/24", i)))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	routes := server.Prefs().AdvertiseRoutes
+	if len(routes) != writers {
+		t.Fatalf("expected %d advertised routes, got %v", writers, routes)
+	}
+}
+
+func TestLockPrefsContextDone(t *testing.T) {
+	ctx := local.WithPrefsLock(context.Background(), filepath.Join(t.TempDir(), "prefs.lock"))
+	ctx = cli.WithStdout(ctx, io.Discard)
+	unlock, err := local.LockPrefs(ctx)
+	if err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, 250*time.Millisecond)
+	defer cancel()
+	_, err = local.LockPrefs(ctx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected %v while lock is held, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func addRoute(ctx context.Context, route netaddr.IPPrefix) error {
+	unlock, err := local.LockPrefs(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	before, err := local.GetPrefs(ctx)
+	if err != nil {
+		return err
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	patch := &ipn.MaskedPrefs{}
+	patch.Prefs = *before.Clone()
+	patch.Prefs.AdvertiseRoutes = append(patch.Prefs.AdvertiseRoutes, route)
+	patch.AdvertiseRoutesSet = true
+	_, err = local.EditPrefs(ctx, patch)
+	return err
+}
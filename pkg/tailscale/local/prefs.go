@@ -0,0 +1,75 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"tailscale.com/ipn"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+const (
+	// debugCurlGetPrefs is a template to print (in debug mode) the "get prefs"
+	// curl command.
+	debugCurlGetPrefs = `Calling "get prefs" local API route:
+> curl \
+>   --include \
+>   --unix-socket %s \
+>   http://no-op-host.invalid/localapi/v0/prefs
+`
+	// debugCurlEditPrefs is a template to print (in debug mode) the
+	// equivalent curl command to the outgoing request. The PATCH body is
+	// not expected to be `shlex` quoted by the template user, but it should be.
+	debugCurlEditPrefs = `Calling "edit prefs" local API route:
+> curl \
+>   --include \
+>   --request PATCH \
+>   --data-binary '%s' \
+>   --unix-socket %s \
+>   http://no-op-host.invalid/localapi/v0/prefs
+`
+)
+
+// GetPrefs gets the current preferences from `tailscaled`.
+func GetPrefs(ctx context.Context) (*ipn.Prefs, error) {
+	cli.DebugPrintf(ctx, debugCurlGetPrefs, GetSocket(ctx))
+	var p ipn.Prefs
+	err := send(ctx, http.MethodGet, "/localapi/v0/prefs", nil, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// EditPrefs applies a (masked) patch to the `tailscaled` preferences and
+// returns the updated preferences.
+func EditPrefs(ctx context.Context, patch *ipn.MaskedPrefs) (*ipn.Prefs, error) {
+	asJSON, err := json.Marshal(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	cli.DebugPrintf(ctx, debugCurlEditPrefs, string(asJSON), GetSocket(ctx))
+	var p ipn.Prefs
+	err = send(ctx, http.MethodPatch, "/localapi/v0/prefs", asJSON, &p)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
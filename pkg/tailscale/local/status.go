@@ -0,0 +1,89 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"inet.af/netaddr"
+	"tailscale.com/ipn/ipnstate"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+const (
+	// debugCurlStatus is a template to print (in debug mode) the "status"
+	// (or "status without peers") curl command.
+	debugCurlStatus = `Calling "%s" local API route:
+> curl \
+>   --include \
+>   --unix-socket %s \
+>   http://no-op-host.invalid/localapi/v0/status%s
+`
+)
+
+// StatusJSON decodes the `tailscaled` status into `v`; this allows callers
+// to decode fields that are not present in `ipnstate.Status` (e.g. fields
+// only provided by newer versions of `tailscaled`).
+func StatusJSON(ctx context.Context, peers bool, v interface{}) error {
+	name, query := "status without peers", "?peers=false"
+	if peers {
+		name, query = "status", ""
+	}
+	cli.DebugPrintf(ctx, debugCurlStatus, name, GetSocket(ctx), query)
+	return send(ctx, http.MethodGet, "/localapi/v0/status"+query, nil, v)
+}
+
+// Status gets the current status from `tailscaled`.
+func Status(ctx context.Context) (*ipnstate.Status, error) {
+	var s ipnstate.Status
+	err := StatusJSON(ctx, true, &s)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// StatusWithoutPeers gets the current status from `tailscaled`, without
+// information about peers.
+func StatusWithoutPeers(ctx context.Context) (*ipnstate.Status, error) {
+	var s ipnstate.Status
+	err := StatusJSON(ctx, false, &s)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SelfIPv4 gets the Tailscale IPv4 address of the local device (i.e. the
+// equivalent of `tailscale ip -4`).
+func SelfIPv4(ctx context.Context) (netaddr.IP, error) {
+	status, err := StatusWithoutPeers(ctx)
+	if err != nil {
+		return netaddr.IP{}, err
+	}
+	if status.Self == nil {
+		return netaddr.IP{}, errors.New("could not determine the local Tailscale device (is tailscaled logged in)")
+	}
+
+	for _, ip := range status.Self.TailscaleIPs {
+		if ip.Is4() {
+			return ip, nil
+		}
+	}
+	return netaddr.IP{}, errors.New("the local Tailscale device has no IPv4 address")
+}
@@ -0,0 +1,49 @@
+// Copyright 2021 Danny Hermes
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"tailscale.com/client/tailscale/apitype"
+
+	"github.com/dhermes/tailsk8s/pkg/cli"
+)
+
+const (
+	// debugCurlWhoIs is a template to print (in debug mode) the "whois"
+	// curl command.
+	debugCurlWhoIs = `Calling "whois" local API route:
+> curl \
+>   --include \
+>   --unix-socket %s \
+>   'http://no-op-host.invalid/localapi/v0/whois?addr=%s'
+`
+)
+
+// WhoIs identifies the Tailscale node (and user) that owns `remoteAddr`,
+// which must be an IP or `IP:port` in the Tailnet.
+func WhoIs(ctx context.Context, remoteAddr string) (*apitype.WhoIsResponse, error) {
+	query := url.QueryEscape(remoteAddr)
+	cli.DebugPrintf(ctx, debugCurlWhoIs, GetSocket(ctx), query)
+	var r apitype.WhoIsResponse
+	err := send(ctx, http.MethodGet, "/localapi/v0/whois?addr="+query, nil, &r)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}